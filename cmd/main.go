@@ -1,21 +1,30 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"io/fs"
 	"log"
-	"math/rand"
 	"os"
 	"runtime/pprof"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/pwiecz/command_series/atr"
+	"github.com/pwiecz/command_series/lib"
+	"github.com/pwiecz/command_series/sid"
 	"github.com/pwiecz/command_series/ui"
 )
 
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
+var host = flag.String("host", "", "host a network game, waiting for the other player on given address (e.g. :4000)")
+var join = flag.String("join", "", "join a network game hosted on given address (e.g. example.com:4000)")
+var pbem = flag.Int("pbem", 0, "start a play-by-email game, in which the game advances given number of hours after each turn")
+var turn = flag.String("turn", "", "continue a play-by-email game from given turn file")
+var turnKey = flag.String("turn-key", "", "key shared by the players of a play-by-email game, used to sign and encrypt turn files")
+var decisionLog = flag.String("decision-log", "", "write decisions of the computer commander to given file as JSON lines")
+var music = flag.String("music", "", "PSID tune to play on the intro and the ending screens")
 var seed = flag.Int64("seed", 0, "if specified, use given seed to initialize random number generator. Otherwise, a random seed will be used")
 
 func main() {
@@ -23,6 +32,21 @@ func main() {
 	if len(flag.Args()) != 1 {
 		log.Fatalf("Usage: %s <game_disk_image>\n", os.Args[0])
 	}
+	if *host != "" && *join != "" {
+		log.Fatal("Cannot both host and join a network game")
+	}
+	if *pbem < 0 || *pbem > 255 {
+		log.Fatal("Number of hours per turn must be between 1 and 255")
+	}
+	if *pbem > 0 && *turn != "" {
+		log.Fatal("Cannot both start a new play-by-email game and continue one")
+	}
+	if (*pbem > 0 || *turn != "") && (*host != "" || *join != "") {
+		log.Fatal("Play-by-email games cannot be played over the network")
+	}
+	if (*pbem > 0 || *turn != "") && *turnKey == "" {
+		log.Fatal("Play-by-email games require a key specified with -turn-key")
+	}
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
@@ -32,12 +56,12 @@ func main() {
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
-	source := rand.NewSource(time.Now().UnixNano())
+	source := lib.NewRandSource(time.Now().UnixNano())
 	// Using flag.Visit we can distinguish between flag being set to its default value
 	// from flag not being set by the user.
 	flag.Visit(func(f *flag.Flag) {
 		if f.Name == "seed" {
-			source = rand.NewSource(*seed)
+			source = lib.NewRandSource(*seed)
 		}
 	})
 
@@ -64,11 +88,35 @@ func main() {
 
 	ebiten.SetWindowSize(1008, 720)
 	ebiten.SetWindowTitle("Command Series Engine")
-	game, err := ui.NewGame(fsys, rand.New(source))
+	game, err := ui.NewGame(fsys, source, ui.NetworkOptions{HostAddress: *host, JoinAddress: *join},
+		ui.TurnOptions{Hours: *pbem, TurnFile: *turn, Key: *turnKey})
 	if err != nil {
 		fmt.Println(err.Error())
 		return
 	}
+	if *decisionLog != "" {
+		f, err := os.Create(*decisionLog)
+		if err != nil {
+			log.Fatalf("Cannot create decision log %s (%v)", *decisionLog, err)
+		}
+		defer f.Close()
+		writer := bufio.NewWriter(f)
+		defer writer.Flush()
+		game.SetDecisionLog(writer)
+	}
+	if *music != "" {
+		data, err := os.ReadFile(*music)
+		if err != nil {
+			log.Fatalf("Cannot read tune %s (%v)", *music, err)
+		}
+		tune, err := sid.ParseTune(data)
+		if err != nil {
+			log.Fatalf("Cannot parse tune %s (%v)", *music, err)
+		}
+		if err := game.SetMusic(tune); err != nil {
+			log.Fatalf("Cannot play tune %s (%v)", *music, err)
+		}
+	}
 	if err := ebiten.RunGame(game); err != nil {
 		fmt.Println(err.Error())
 	}
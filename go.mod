@@ -7,6 +7,7 @@ toolchain go1.24.0
 require (
 	github.com/ebitengine/oto/v3 v3.3.2
 	github.com/hajimehoshi/ebiten/v2 v2.8.6
+	go.starlark.net v0.0.0-20250205221240-492d3672b3f4
 	golang.org/x/exp v0.0.0-20250305212735-054e65f0b394
 	golang.org/x/image v0.25.0
 )
@@ -3,6 +3,7 @@ package lib
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 )
 
 type AI struct {
@@ -11,6 +12,7 @@ type AI struct {
 	game Game
 
 	commanderFlags *CommanderFlags
+	options        *Options
 	scenarioData   *Data
 	terrain        *Terrain
 	terrainTypes   *TerrainTypeMap
@@ -18,24 +20,81 @@ type AI struct {
 	hexes          *Hexes
 	units          *Units
 	score          *Score
+	// Local weather differences (nil if the weather is the same on the whole map).
+	weatherRegions *WeatherRegions
 
 	// Side of the most recently updated unit. Used for detecting moment when we switch analysing sides.
 	update          int
 	lastUpdatedUnit int
 
+	// Strategy level maps on a 16x16 grid, each square covering 4x4 map tiles.
 	map0 [2][16][16]int // Location of troops
 	map1 [2][16][16]int // Location of important objects (supply units, air wings, important cities...)
 	map3 [2][16][16]int
 	// Aggregated versions of map0, map1 to 4 times lower resolution.
 	map2_0, map2_1 [2][4][4]int // 0x400 - two byte values
+
+	// Most recent objective decisions for every unit (for debugging).
+	objectiveDecisions [2][]ObjectiveDecision
+	// Record of the unit update in progress (nil unless decisions are logged).
+	decision *UnitDecision
+	// Levels chosen by the lookahead computer commander.
+	plannedLevels [2]AILevel
+	// Events of the current day recorded for the flashback.
+	dayEvents FlashbackEvents
+}
+
+// ObjectiveCandidate is a location considered by the computer commander as an objective of a unit.
+type ObjectiveCandidate struct {
+	XY    UnitCoords
+	Score int
+}
+
+// ObjectiveDecision describes how the computer commander most recently chose an objective of a unit.
+type ObjectiveDecision struct {
+	// Attack or Defend.
+	Order OrderType
+	// Location of the unit when the decision was made.
+	XY UnitCoords
+	// Considered locations with their scores. When attacking the lowest score wins,
+	// when defending the highest one.
+	Candidates []ObjectiveCandidate
+	Objective  UnitCoords
+	Score      int
+}
+
+// SortedCandidates returns the candidates ordered from the most to the least preferred.
+func (d ObjectiveDecision) SortedCandidates() []ObjectiveCandidate {
+	candidates := append([]ObjectiveCandidate(nil), d.Candidates...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if d.Order == Attack {
+			return candidates[i].Score < candidates[j].Score
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// newObjectiveDecision returns the cleared record of the objective decision of the unit.
+func (s *AI) newObjectiveDecision(unit Unit, order OrderType) *ObjectiveDecision {
+	decisions := &s.objectiveDecisions[unit.Side]
+	if len(*decisions) <= unit.Index {
+		*decisions = append(*decisions, make([]ObjectiveDecision, len(s.units[unit.Side])-len(*decisions))...)
+	}
+	decision := &(*decisions)[unit.Index]
+	decision.Order = order
+	decision.XY = unit.XY
+	decision.Candidates = decision.Candidates[:0]
+	return decision
 }
 
-func newAI(rand *rand.Rand, commanderFlags *CommanderFlags, gameData *GameData, scenarioData *ScenarioData, score *Score) *AI {
+func newAI(rand *rand.Rand, commanderFlags *CommanderFlags, options *Options, gameData *GameData, scenarioData *ScenarioData, score *Score) *AI {
 	return &AI{
 		update:          3,
 		lastUpdatedUnit: 127,
 		rand:            rand,
 		commanderFlags:  commanderFlags,
+		options:         options,
 		game:            gameData.Game,
 		scenarioData:    scenarioData.Data,
 		terrain:         scenarioData.Terrain,
@@ -46,7 +105,40 @@ func newAI(rand *rand.Rand, commanderFlags *CommanderFlags, gameData *GameData,
 		score:           score}
 }
 
+// clone returns a copy of the AI playing the game state.
+// Objective decisions are not copied and decisions of the copy are not logged.
+func (s *AI) clone(game *GameState) *AI {
+	ai := *s
+	ai.rand = game.rand
+	ai.commanderFlags = game.commanderFlags
+	ai.options = game.options
+	ai.scenarioData = game.scenarioData
+	ai.terrain = game.terrain
+	ai.terrainTypes = game.terrainTypes
+	ai.units = game.units
+	ai.score = game.score
+	ai.weatherRegions = game.weatherRegions
+	ai.objectiveDecisions = [2][]ObjectiveDecision{}
+	ai.decision = nil
+	ai.dayEvents = append(FlashbackEvents(nil), s.dayEvents...)
+	return &ai
+}
+
+func (s *AI) recordEvent(eventType FlashbackEventType, side int, xy UnitCoords) {
+	s.dayEvents = append(s.dayEvents, FlashbackEvent{Type: eventType, Side: side, XY: xy})
+}
+
+// level returns the level of the computer commander of given side.
+// The lookahead commander plays at the level chosen by the most recent lookahead.
+func (s *AI) level(side int) AILevel {
+	if level := s.options.AILevel(side); level != LookaheadAI {
+		return level
+	}
+	return s.plannedLevels[side]
+}
+
 func (s *AI) UpdateUnit(weather int, isNight bool, sync *MessageSync) (message MessageFromUnit, quit bool) {
+	globalWeather := weather
 	if isNight {
 		weather += 8
 	}
@@ -59,10 +151,18 @@ nextUnit:
 	if !s.areUnitCoordsValid(unit.XY) {
 		panic(fmt.Errorf("%s@(%v):%v", unit.FullName(), unit.XY, unit))
 	}
+	if s.weatherRegions != nil {
+		weather = s.weatherRegions.weatherAt(unit.XY, globalWeather, len(s.scenarioData.Weather))
+		if isNight {
+			weather += 8
+		}
+	}
+	s.startDecision(unit)
 	var arg1 int
 	if unit.MenCount+unit.TankCount < 7 || unit.Fatigue == 255 {
 		s.terrainTypes.hideUnit(unit)
 		message = WeMustSurrender{unit}
+		s.recordEvent(FlashbackSurrender, unit.Side, unit.XY)
 		unit.ClearState()
 		unit.HalfDaysUntilAppear = 0
 		s.score.CitiesHeld[1-unit.Side] += s.scenarioData.UnitScores[unit.Type]
@@ -142,10 +242,18 @@ nextUnit:
 			goto end
 		}
 		// [53767] = 0
+		menCount, tankCount := unit.MenCount, unit.TankCount
+		enemy, _ := s.units.FindUnitOfSideAt(sxy, 1-unit.Side)
 		if s.performAttack(&unit, sxy, weather, &message, sync) {
 			quit = true
 			return
 		}
+		if s.decision != nil && s.decision.Attack != nil {
+			enemyAfter := s.units[enemy.Side][enemy.Index]
+			s.decision.Attack.EnemyTo = enemyAfter.XY
+			s.decision.Attack.MenLost, s.decision.Attack.TanksLost = menCount-unit.MenCount, tankCount-unit.TankCount
+			s.decision.Attack.EnemyMenLost, s.decision.Attack.EnemyTanksLost = enemy.MenCount-enemyAfter.MenCount, enemy.TankCount-enemyAfter.TankCount
+		}
 	}
 end: // l3
 	for unit.Formation != unit.TargetFormation {
@@ -187,6 +295,9 @@ func (s *AI) updateUnitObjectiveAux(unit *Unit, weather int) int {
 	if !needsObjective {
 		return 0 // goto l21
 	}
+	if s.decision != nil {
+		s.decision.Order = mode.String()
+	}
 	// l24:
 	var arg1 int
 	unit.TargetFormation = s.scenarioData.function10(unit.Order, 1)
@@ -258,6 +369,7 @@ func (s *AI) resetMaps() {
 // If the side is controlled by the computer create the strategy level maps aggregating locations and numbers of units, important locations and such.
 func (s *AI) reinitSmallMapsAndSuch(currentSide int) {
 	s.resetMaps()
+	view := newSideView(currentSide, s.units, nil, s.commanderFlags)
 	// Those variables in the original code do not seem to play any role
 	//v13 := 0
 	//v15 := 0
@@ -276,7 +388,7 @@ func (s *AI) reinitSmallMapsAndSuch(currentSide int) {
 				//v13 += 1
 			} else {
 				//v16 += unit.MenCount + unit.TankCount
-				if !s.commanderFlags.PlayerHasIntelligence[currentSide] && !unit.SeenByEnemy {
+				if !view.isUnitReported(unit) {
 					continue // goto l23
 				}
 			}
@@ -432,8 +544,7 @@ func (s *AI) bestOrder(unit *Unit, numEnemyNeighbours *int) (OrderType, bool) {
 				if !InRange(x, 0, 4) || !InRange(y, 0, 4) {
 					continue
 				}
-				// Coords are a good target if there are more high importance objects (supply units, air wings, cities with high vp), and less good target if there are already many friendly units.
-				val := (s.map2_1[unit.Side][x][y] + s.map2_1[1-unit.Side][x][y]) * 16 / Clamp(s.map2_0[unit.Side][x][y]-s.map2_0[1-unit.Side][x][y], 10, 9999)
+				val := s.tinyMapValue(unit.Side, x, y)
 				val = val * s.function26(UnitCoords{unit.XY.X / 4, unit.XY.Y / 4}, neighbourIx) / 8
 				if neighbourIx == 0 {
 					// Prioritize staying withing the same square.
@@ -612,6 +723,9 @@ func (s *AI) bestOrder(unit *Unit, numEnemyNeighbours *int) (OrderType, bool) {
 			if !unit.HasSupplyLine {
 				supplyUse *= 2
 			}
+			if s.level(unit.Side) == CautiousAI {
+				supplyUse *= 2
+			}
 			if unit.SupplyLevel < supplyUse {
 				supplyUnit := s.units[unit.Side][unit.SupplyUnit]
 				if !supplyUnit.IsInGame {
@@ -630,7 +744,15 @@ func (s *AI) bestOrder(unit *Unit, numEnemyNeighbours *int) (OrderType, bool) {
 			if s.game == Conflict && s.scenarioData.UnitMask0[unit.Type] {
 				bestDx, bestDy = 0, 0
 			}
-			if unit.Fatigue*4 > bestVal-v63 {
+			fatigueCoeff := 4
+			if s.level(unit.Side) == AggressiveAI {
+				fatigueCoeff = 2
+			}
+			if unit.Fatigue*fatigueCoeff > bestVal-v63 {
+				bestDx, bestDy = 0, 0
+			}
+			if s.level(unit.Side) == CautiousAI && !unit.HasSupplyLine {
+				// Don't advance without a supply line.
 				bestDx, bestDy = 0, 0
 			}
 			if bestDx == 0 && bestDy == 0 {
@@ -663,7 +785,19 @@ func (s *AI) bestOrder(unit *Unit, numEnemyNeighbours *int) (OrderType, bool) {
 	return mode, true
 }
 
+// tinyMapValue returns how good a target for units in the rear is given square of the tiny map.
+func (s *AI) tinyMapValue(side, x, y int) int {
+	if s.level(side) == AggressiveAI {
+		// Concentrate force: squares are a good target if there are many enemy troops and high importance objects,
+		// and even better if friendly units are already gathering there.
+		return (s.map2_1[side][x][y] + s.map2_1[1-side][x][y] + s.map2_0[1-side][x][y]) * (16 + s.map2_0[side][x][y]) / 16
+	}
+	// Coords are a good target if there are more high importance objects (supply units, air wings, cities with high vp), and less good target if there are already many friendly units.
+	return (s.map2_1[side][x][y] + s.map2_1[1-side][x][y]) * 16 / Clamp(s.map2_0[side][x][y]-s.map2_0[1-side][x][y], 10, 9999)
+}
+
 func (s *AI) bestAttackObjective(unit Unit, weather int, numEnemyNeighbours int) (UnitCoords, int) {
+	decision := s.newObjectiveDecision(unit, Attack)
 	var bestObjective UnitCoords
 	bestScore := 16000
 	terrainType := s.terrainTypes.terrainTypeAt(unit.XY)
@@ -731,15 +865,19 @@ func (s *AI) bestAttackObjective(unit Unit, weather int, numEnemyNeighbours int)
 				}
 			}
 		}
+		decision.Candidates = append(decision.Candidates, ObjectiveCandidate{XY: nxy, Score: score})
 		if score <= bestScore {
 			bestScore = score
 			bestObjective = nxy
 		}
 	}
+	decision.Objective, decision.Score = bestObjective, bestScore
+	s.logObjective(decision)
 	return bestObjective, bestScore
 }
 
 func (s *AI) bestDefenceObjective(unit Unit) (UnitCoords, int) {
+	decision := s.newObjectiveDecision(unit, Defend)
 	// temperarily hide the unit while we compute sth
 	s.units[unit.Side][unit.Index].IsInGame = false
 	score := -17536 // 48000
@@ -770,6 +908,7 @@ func (s *AI) bestDefenceObjective(unit Unit) (UnitCoords, int) {
 				}
 			}
 		}
+		decision.Candidates = append(decision.Candidates, ObjectiveCandidate{XY: nxy, Score: v})
 		if v >= score {
 			score = v
 			bestI = i
@@ -784,9 +923,14 @@ func (s *AI) bestDefenceObjective(unit Unit) (UnitCoords, int) {
 	if s.commanderFlags.PlayerControlled[unit.Side] {
 		v *= 2
 	}
+	chosenScore := score
 	if v+v_6 > score {
 		bestI = 6
+		chosenScore = v + v_6
 	}
+	decision.Objective, decision.Score = IthNeighbour(unit.XY, bestI), chosenScore
+	s.logObjective(decision)
+	// The original game returns the best score of the neighbourhood even if the unit stays in place.
 	return IthNeighbour(unit.XY, bestI), score
 }
 
@@ -939,6 +1083,9 @@ func (s *AI) performUnitMovement(unit *Unit, message *MessageFromUnit, arg1 *int
 			}
 		}
 		unit.XY = sxy
+		if s.decision != nil {
+			s.decision.Path = append(s.decision.Path, sxy)
+		}
 		s.function29_showUnit(*unit)
 		if unit.Function15_distanceToObjective() == 0 {
 			unit.Objective.X = 0
@@ -950,6 +1097,7 @@ func (s *AI) performUnitMovement(unit *Unit, message *MessageFromUnit, arg1 *int
 		unit.Fatigue = Clamp(unit.Fatigue+s.scenarioData.Data173, 0, 255)
 		if city, captured := s.function16(*unit); captured {
 			*message = WeHaveCaptured{*unit, *city}
+			s.recordEvent(FlashbackCapture, unit.Side, city.XY)
 			return // goto l2
 		}
 		if unitMoveBudget > 0 {
@@ -998,6 +1146,13 @@ func (s *AI) performAttack(unit *Unit, sxy UnitCoords, weather int, message *Mes
 	if !ok {
 		panic("")
 	}
+	if s.decision != nil {
+		s.decision.Attack = &AttackResult{
+			EnemySide:  unit2.Side,
+			EnemyIndex: unit2.Index,
+			Enemy:      unit2.FullName(),
+			EnemyFrom:  unit2.XY}
+	}
 	*message = WeAreAttacking{*unit, unit2, 0 /* placeholder value */, s.scenarioData.Formations}
 	var attackerScore int
 	{
@@ -1075,6 +1230,7 @@ func (s *AI) performAttack(unit *Unit, sxy UnitCoords, weather int, message *Mes
 	// function13(sx, sy)
 	// function4(arg1)
 	sync.SendUpdate(UnitAttack{sxy, arg1})
+	s.recordEvent(FlashbackBattle, unit.Side, sxy)
 
 	menLost2 := Clamp((Rand(unit2.MenCount*arg1, s.rand)+500)/512, 0, unit2.MenCount)
 	s.score.MenLost[1-unit.Side] += menLost2
@@ -1155,6 +1311,7 @@ func (s *AI) performAttack(unit *Unit, sxy UnitCoords, weather int, message *Mes
 				s.terrainTypes.showUnit(*unit)
 				if city, captured := s.function16(*unit); captured {
 					*message = WeHaveCaptured{*unit, *city}
+					s.recordEvent(FlashbackCapture, unit.Side, city.XY)
 				}
 			}
 		} else {
@@ -1248,7 +1405,7 @@ outerLoop:
 					}
 				}
 				if unitVisible {
-					sync.SendUpdate(SupplyTruckMove{supplyXY.ToMapCoords(), xy.ToMapCoords()})
+					sync.SendUpdate(SupplyTruckMove{unit.Side, supplyXY.ToMapCoords(), xy.ToMapCoords()})
 					//  function13(x, y) (show truck icon at x, y)
 				}
 				supplyXY = xy
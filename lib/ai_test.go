@@ -0,0 +1,94 @@
+package lib
+
+import "testing"
+
+func TestObjectiveDecisions(t *testing.T) {
+	var units Units
+	units[1] = make([]Unit, 3)
+	ai := &AI{units: &units}
+	gameState := &GameState{ai: ai}
+	if _, ok := gameState.ObjectiveDecision(1, 2); ok {
+		t.Error("Unexpected decision before any decision was made")
+	}
+
+	decision := ai.newObjectiveDecision(Unit{Side: 1, Index: 2, XY: UnitCoords{4, 4}}, Attack)
+	decision.Candidates = append(decision.Candidates,
+		ObjectiveCandidate{XY: UnitCoords{6, 4}, Score: 10},
+		ObjectiveCandidate{XY: UnitCoords{2, 4}, Score: 5})
+	decision.Objective, decision.Score = UnitCoords{2, 4}, 5
+
+	recorded, ok := gameState.ObjectiveDecision(1, 2)
+	if !ok || recorded.Order != Attack || recorded.XY != (UnitCoords{4, 4}) || len(recorded.Candidates) != 2 || recorded.Objective != (UnitCoords{2, 4}) {
+		t.Fatalf("Unexpected decision %v", recorded)
+	}
+	recorded.Candidates[0].Score = 100
+	if ai.objectiveDecisions[1][2].Candidates[0].Score != 10 {
+		t.Error("Recorded decision modified through a returned copy")
+	}
+	if sorted := recorded.SortedCandidates(); sorted[0].XY != (UnitCoords{2, 4}) || recorded.Candidates[0].XY != (UnitCoords{6, 4}) {
+		t.Errorf("Expected the lowest score to be the most preferred when attacking, got %v", sorted)
+	}
+	recorded.Order = Defend
+	if sorted := recorded.SortedCandidates(); sorted[0].XY != (UnitCoords{6, 4}) {
+		t.Errorf("Expected the highest score to be the most preferred when defending, got %v", sorted)
+	}
+	if _, ok := gameState.ObjectiveDecision(1, 1); ok {
+		t.Error("Unexpected decision for another unit")
+	}
+
+	decision = ai.newObjectiveDecision(Unit{Side: 1, Index: 2, XY: UnitCoords{6, 4}}, Defend)
+	if len(decision.Candidates) != 0 || decision.Order != Defend || decision.XY != (UnitCoords{6, 4}) {
+		t.Errorf("Expected a cleared decision, got %v", decision)
+	}
+}
+
+func TestAILevelTinyMapValue(t *testing.T) {
+	for side := 0; side < 2; side++ {
+		ai := &AI{options: &Options{}}
+		// Own troops in (0,0), enemy troops in (1,0), equally important objects in both.
+		ai.map2_0[side][0][0], ai.map2_0[1-side][1][0] = 40, 40
+		ai.map2_1[side][0][0], ai.map2_1[side][1][0] = 8, 8
+		if original := ai.tinyMapValue(side, 0, 0); original != 8*16/40 {
+			t.Errorf("Expected the original value %d, got %d", 8*16/40, original)
+		}
+		if ai.tinyMapValue(side, 0, 0) >= ai.tinyMapValue(side, 1, 0) {
+			t.Error("Expected the original computer commander to prefer squares with less own troops")
+		}
+
+		ai.options.AlliedAILevel, ai.options.GermanAILevel = AggressiveAI, AggressiveAI
+		if ai.tinyMapValue(side, 0, 0) >= ai.tinyMapValue(side, 1, 0) {
+			t.Error("Expected the aggressive computer commander to prefer squares with enemy troops")
+		}
+		// Equally strong enemy in (0,1), but friendly units gather in (1,0).
+		ai.map2_0[1-side][0][1], ai.map2_0[side][1][0] = 40, 20
+		if concentrated, spread := ai.tinyMapValue(side, 1, 0), ai.tinyMapValue(side, 0, 1); concentrated <= spread {
+			t.Errorf("Expected the aggressive computer commander to concentrate force, got %d <= %d", concentrated, spread)
+		}
+	}
+}
+
+func TestBestDefenceObjectiveStayingInPlace(t *testing.T) {
+	var units Units
+	units[0] = []Unit{{Side: 0, Index: 0, XY: UnitCoords{6, 4}, IsInGame: true}}
+	data := &Data{}
+	data.TerrainMenDefence[0] = 5
+	data.FormationMenDefence[0] = 18
+	ai := &AI{
+		game:           Conflict,
+		commanderFlags: &CommanderFlags{},
+		scenarioData:   data,
+		// A city next to the unit makes it the best of the neighbouring locations.
+		terrain:      &Terrain{Cities: Cities{{VictoryPoints: 3, XY: UnitCoords{8, 4}}}},
+		terrainTypes: newTerrainTypeMap(&Map{Width: 10, Height: 10, terrain: make([]byte, 100)}, &Generic{TerrainTypes: make([]int, 64)}),
+		units:        &units}
+
+	// The city scores 5+3, but the unit's own location 5 plus the defence bonus 10.
+	objective, _ := ai.bestDefenceObjective(units[0][0])
+	if objective != units[0][0].XY {
+		t.Fatalf("Expected the unit to stay in place, got %v", objective)
+	}
+	decision := ai.objectiveDecisions[0][0]
+	if decision.Objective != units[0][0].XY || decision.Score != 15 {
+		t.Errorf("Expected the score of staying in place to be recorded, got %v, %d", decision.Objective, decision.Score)
+	}
+}
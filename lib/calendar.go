@@ -0,0 +1,102 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+)
+
+// Calendar selects how game dates advance and how the length of days is computed.
+type Calendar int
+
+func (c Calendar) String() string {
+	switch c {
+	case OriginalCalendar:
+		return "ORIGINAL"
+	case RealCalendar:
+		return "REAL"
+	}
+	panic(fmt.Errorf("unknown calendar: %d", int(c)))
+}
+func (c Calendar) Other() Calendar {
+	return 1 - c
+}
+
+const (
+	// All months have 30 days, sunrise and sunset depend only on the month (as in the original games).
+	OriginalCalendar Calendar = 0
+	// Gregorian calendar, with sunrise and sunset computed from the day of year.
+	RealCalendar Calendar = 1
+)
+
+// Approximate latitude (in degrees) of the battlefields of the given game.
+func latitude(game Game) float64 {
+	switch game {
+	case Crusade:
+		return 49
+	case Decision:
+		return 31
+	case Conflict:
+		return 16
+	}
+	panic(fmt.Errorf("unknown game: %d", int(game)))
+}
+
+// Scenarios may store years either in full or as the last two digits.
+func fullYear(year int) int {
+	if year < 100 {
+		return year + 1900
+	}
+	return year
+}
+
+// month is 1-based.
+func monthLength(month, year int) int {
+	switch month {
+	case 1, 3, 5, 7, 8, 10, 12:
+		return 31
+	case 4, 6, 9, 11:
+		return 30
+	case 2:
+		if year%400 == 0 {
+			return 29
+		}
+		if year%100 == 0 {
+			return 28
+		}
+		if year%4 == 0 {
+			return 29
+		}
+		return 28
+	}
+	panic(fmt.Errorf("unexpected month number %d", month))
+}
+
+// day and month are 0-based, so is the result.
+func dayOfYear(day, month, year int) int {
+	for m := 1; m <= month; m++ {
+		day += monthLength(m, year)
+	}
+	return day
+}
+
+// daylightHours returns the number of hours between sunrise and sunset
+// on the given (0-based) day of year at the given latitude.
+func daylightHours(dayOfYear int, latitude float64) float64 {
+	declination := -23.44 * math.Cos(2*math.Pi/365*float64(dayOfYear+10))
+	cosHourAngle := -math.Tan(latitude*math.Pi/180) * math.Tan(declination*math.Pi/180)
+	hourAngle := math.Acos(Clamp(cosHourAngle, -1, 1)) * 180 / math.Pi
+	return 2 * hourAngle / 15
+}
+
+// isNightAt tells if the given (0-based) date and hour is during the night.
+func isNightAt(hour, day, month, year int, calendar Calendar, game Game) bool {
+	if calendar == OriginalCalendar {
+		sunriseOffset := Abs(6-month) / 2
+		return hour < 5+sunriseOffset || hour > 20-sunriseOffset
+	}
+	daylight := daylightHours(dayOfYear(day, month, fullYear(year)), latitude(game))
+	// Midday at 12:30 keeps days symmetric with the original 5-20 summer day.
+	sunrise := math.Ceil(12.5 - daylight/2)
+	sunset := math.Floor(12.5 + daylight/2)
+	return float64(hour) < sunrise || float64(hour) > sunset
+}
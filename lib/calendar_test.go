@@ -0,0 +1,56 @@
+package lib
+
+import "testing"
+
+func TestMonthLength(t *testing.T) {
+	for _, tc := range []struct{ month, year, length int }{
+		{1, 1944, 31}, {2, 1944, 29}, {2, 1942, 28}, {2, 1900, 28}, {2, 2000, 29}, {6, 1944, 30}, {12, 1965, 31},
+	} {
+		if length := monthLength(tc.month, tc.year); length != tc.length {
+			t.Errorf("monthLength(%d, %d) = %d, expected %d", tc.month, tc.year, length, tc.length)
+		}
+	}
+}
+
+func TestDayOfYear(t *testing.T) {
+	// D-Day, 6th of June 1944 (a leap year).
+	if day := dayOfYear(5, 5, 1944); day != 157 {
+		t.Errorf("Expected day 157, got %d", day)
+	}
+	if day := dayOfYear(30, 11, 1943); day != 364 {
+		t.Errorf("Expected day 364, got %d", day)
+	}
+}
+
+func TestIsNightAt(t *testing.T) {
+	// Original calendar must match the formula of the original games.
+	for month := 0; month < 12; month++ {
+		sunriseOffset := Abs(6-month) / 2
+		for hour := 0; hour < 24; hour++ {
+			expected := hour < 5+sunriseOffset || hour > 20-sunriseOffset
+			if isNightAt(hour, 0, month, 44, OriginalCalendar, Crusade) != expected {
+				t.Errorf("Unexpected night value for month %d, hour %d", month, hour)
+			}
+		}
+	}
+	// Around the summer solstice days in Normandy are much longer than in Vietnam.
+	normandyDaylight, vietnamDaylight := 0, 0
+	for hour := 0; hour < 24; hour++ {
+		if !isNightAt(hour, 20, 5, 1944, RealCalendar, Crusade) {
+			normandyDaylight++
+		}
+		if !isNightAt(hour, 20, 5, 1965, RealCalendar, Conflict) {
+			vietnamDaylight++
+		}
+	}
+	if normandyDaylight != 16 {
+		t.Errorf("Expected 16 hours of daylight in Normandy, got %d", normandyDaylight)
+	}
+	if vietnamDaylight >= normandyDaylight {
+		t.Errorf("Expected shorter days in Vietnam, got %d", vietnamDaylight)
+	}
+	// In the winter it's the other way round.
+	if !isNightAt(8, 20, 11, 1944, RealCalendar, Crusade) || isNightAt(8, 20, 11, 1965, RealCalendar, Conflict) {
+		t.Error("Expected 8 AM to be night in December in Europe, but not in Vietnam")
+	}
+}
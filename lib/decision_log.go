@@ -0,0 +1,90 @@
+package lib
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// UnitDecision records a single update of a unit by the computer commander.
+// Decisions are written to the decision log as JSON lines.
+type UnitDecision struct {
+	// Time of the update.
+	DaysElapsed, Hour, Minute int
+	Side, Index               int
+	Unit                      string
+	// Location of the unit before and after the update.
+	From, To UnitCoords
+	// Order chosen for the unit, empty if the unit kept following its previous orders.
+	Order string `json:",omitempty"`
+	// Objective chosen for the unit, if a new one was looked for.
+	Objective *ObjectiveLog `json:",omitempty"`
+	// Locations the unit moved through.
+	Path []UnitCoords `json:",omitempty"`
+	// Result of an attack performed by the unit.
+	Attack *AttackResult `json:",omitempty"`
+	// Message sent by the unit.
+	Message string `json:",omitempty"`
+}
+
+// ObjectiveLog lists locations considered as the objective of a unit.
+type ObjectiveLog struct {
+	// When attacking the lowest score wins, when defending the highest one.
+	Candidates []ObjectiveCandidate
+	Objective  UnitCoords
+	Score      int
+}
+
+// AttackResult describes the outcome of an attack.
+type AttackResult struct {
+	EnemySide, EnemyIndex int
+	Enemy                 string
+	// Location of the enemy unit before and after the attack.
+	EnemyFrom, EnemyTo           UnitCoords
+	MenLost, TanksLost           int
+	EnemyMenLost, EnemyTanksLost int
+}
+
+// SetDecisionLog makes the game write every decision of the computer commander to the writer.
+// A nil writer disables logging.
+func (s *GameState) SetDecisionLog(writer io.Writer) {
+	if writer == nil {
+		s.decisionLog = nil
+		s.ai.decision = nil
+		return
+	}
+	s.decisionLog = json.NewEncoder(writer)
+	s.ai.decision = &UnitDecision{}
+}
+
+func (s *GameState) logDecision(message MessageFromUnit) error {
+	decision := s.ai.decision
+	decision.DaysElapsed, decision.Hour, decision.Minute = s.daysElapsed, s.hour, s.minute
+	decision.To = s.units[decision.Side][decision.Index].XY
+	if message != nil {
+		decision.Message = message.String()
+	}
+	return s.decisionLog.Encode(decision)
+}
+
+// startDecision starts recording the update of the unit.
+func (s *AI) startDecision(unit Unit) {
+	if s.decision == nil {
+		return
+	}
+	*s.decision = UnitDecision{
+		Side:  unit.Side,
+		Index: unit.Index,
+		Unit:  unit.FullName(),
+		From:  unit.XY,
+		Path:  s.decision.Path[:0]}
+}
+
+func (s *AI) logObjective(decision *ObjectiveDecision) {
+	if s.decision == nil {
+		return
+	}
+	s.decision.Objective = &ObjectiveLog{
+		Candidates: append([]ObjectiveCandidate(nil), decision.Candidates...),
+		Objective:  decision.Objective,
+		Score:      decision.Score}
+}
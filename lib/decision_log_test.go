@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDecisionLog(t *testing.T) {
+	var units Units
+	units[1] = []Unit{{Side: 1, Index: 0, XY: UnitCoords{4, 4}}}
+	ai := &AI{units: &units}
+	gameState := &GameState{ai: ai, units: &units, daysElapsed: 2, hour: 14, minute: 30}
+	ai.startDecision(units[1][0])
+	if ai.decision != nil {
+		t.Fatal("Unexpected decision recorded with logging disabled")
+	}
+
+	var buf bytes.Buffer
+	gameState.SetDecisionLog(&buf)
+	for i := 0; i < 2; i++ {
+		ai.startDecision(units[1][0])
+		decision := ai.newObjectiveDecision(units[1][0], Attack)
+		decision.Candidates = append(decision.Candidates, ObjectiveCandidate{XY: UnitCoords{6, 4}, Score: 10})
+		decision.Objective, decision.Score = UnitCoords{6, 4}, 10
+		ai.logObjective(decision)
+		ai.decision.Order = Attack.String()
+		ai.decision.Path = append(ai.decision.Path, UnitCoords{5, 4})
+		units[1][0].XY = UnitCoords{5, 4}
+		if err := gameState.logDecision(nil); err != nil {
+			t.Fatal("Cannot log decision,", err)
+		}
+		units[1][0].XY = UnitCoords{4, 4}
+	}
+
+	decoder := json.NewDecoder(&buf)
+	for i := 0; i < 2; i++ {
+		var logged UnitDecision
+		if err := decoder.Decode(&logged); err != nil {
+			t.Fatal("Cannot decode logged decision,", err)
+		}
+		if logged.DaysElapsed != 2 || logged.Hour != 14 || logged.Minute != 30 || logged.Side != 1 || logged.Index != 0 {
+			t.Errorf("Unexpected time or unit of the decision %v", logged)
+		}
+		if logged.From != (UnitCoords{4, 4}) || logged.To != (UnitCoords{5, 4}) || len(logged.Path) != 1 || logged.Order != Attack.String() {
+			t.Errorf("Unexpected movement or order %v", logged)
+		}
+		if logged.Objective == nil || len(logged.Objective.Candidates) != 1 || logged.Objective.Objective != (UnitCoords{6, 4}) {
+			t.Errorf("Unexpected objective %v", logged.Objective)
+		}
+		if logged.Attack != nil || logged.Message != "" {
+			t.Errorf("Unexpected attack or message %v", logged)
+		}
+	}
+
+	gameState.SetDecisionLog(nil)
+	if ai.decision != nil {
+		t.Error("Decision still recorded after logging was disabled")
+	}
+}
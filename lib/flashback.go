@@ -0,0 +1,260 @@
+package lib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Position of a unit at the end of a day.
+type FlashbackUnit struct {
+	XY           UnitCoords
+	ColorPalette int
+	Type         int
+	// Side and Index identify the unit, so that it can be followed from day to day.
+	Side  int
+	Index int
+}
+type FlashbackUnits []FlashbackUnit
+
+type FlashbackEventType int
+
+func (t FlashbackEventType) String() string {
+	switch t {
+	case FlashbackBattle:
+		return "BATTLE"
+	case FlashbackCapture:
+		return "CAPTURE"
+	case FlashbackSurrender:
+		return "SURRENDER"
+	case FlashbackReinforcement:
+		return "REINFORCEMENT"
+	}
+	panic(fmt.Errorf("unknown flashback event type: %d", int(t)))
+}
+
+const (
+	// A unit attacked an enemy unit at XY.
+	FlashbackBattle FlashbackEventType = 0
+	// A unit captured the city at XY.
+	FlashbackCapture FlashbackEventType = 1
+	// A unit at XY surrendered.
+	FlashbackSurrender FlashbackEventType = 2
+	// A new unit appeared at XY.
+	FlashbackReinforcement FlashbackEventType = 3
+)
+
+// Event which happened during a day shown in the flashback.
+type FlashbackEvent struct {
+	Type FlashbackEventType
+	// Side which attacked, captured, surrendered or was reinforced.
+	Side int
+	XY   UnitCoords
+}
+type FlashbackEvents []FlashbackEvent
+
+// Units at the end of a day and the events which happened during the day.
+type FlashbackDay struct {
+	Units  FlashbackUnits
+	Events FlashbackEvents
+}
+type FlashbackHistory []FlashbackDay
+
+// Maximum distance from a unit (in hexes) of map tiles considered held by its side when looking for the front line.
+const frontLineRange = 4
+
+// FrontLineSegment separates two neighbouring map tiles held by different sides.
+type FrontLineSegment struct {
+	XY0, XY1 MapCoords
+}
+
+// FrontLine returns segments separating map tiles held by different sides.
+// A tile is held by the side of the nearest unit, if it's not farther than frontLineRange.
+// Only tiles within given bounds (in map coordinates) are considered.
+func (u FlashbackUnits) FrontLine(minX, minY, maxX, maxY int) []FrontLineSegment {
+	width, height := maxX-minX+1, maxY-minY+1
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	sides := make([]int, width*height)
+	sideAt := func(xy MapCoords) int {
+		if xy.X < minX || xy.X > maxX || xy.Y < minY || xy.Y > maxY {
+			return -1
+		}
+		return sides[(xy.Y-minY)*width+xy.X-minX]
+	}
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			sides[(y-minY)*width+x-minX] = u.nearestSide(MapCoords{x, y}.ToUnitCoords())
+		}
+	}
+	var segments []FrontLineSegment
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			xy := MapCoords{x, y}
+			side := sideAt(xy)
+			if side < 0 {
+				continue
+			}
+			unitXY := xy.ToUnitCoords()
+			// Neighbours to the right and below, so that every pair of tiles is checked once.
+			for _, neighbour := range []UnitCoords{
+				{unitXY.X + 2, unitXY.Y},
+				{unitXY.X - 1, unitXY.Y + 1},
+				{unitXY.X + 1, unitXY.Y + 1}} {
+				neighbourXY := neighbour.ToMapCoords()
+				if neighbourSide := sideAt(neighbourXY); neighbourSide >= 0 && neighbourSide != side {
+					segments = append(segments, FrontLineSegment{xy, neighbourXY})
+				}
+			}
+		}
+	}
+	return segments
+}
+
+// nearestSide returns side of the unit nearest to xy, or -1 if there are no units within frontLineRange.
+func (u FlashbackUnits) nearestSide(xy UnitCoords) int {
+	side, distance := -1, frontLineRange+1
+	for _, unit := range u {
+		if d := hexDistance(unit.XY.X-xy.X, unit.XY.Y-xy.Y); d < distance {
+			side, distance = unit.Side, d
+		}
+	}
+	return side
+}
+
+func (u FlashbackUnits) Write(writer io.Writer) error {
+	size := uint64(len(u))
+	if err := binary.Write(writer, binary.LittleEndian, size); err != nil {
+		return err
+	}
+	var data [4]byte
+	for _, unit := range u {
+		data[0] = byte(unit.XY.X)
+		data[1] = byte(unit.XY.Y)
+		data[2] = byte(unit.Type) + byte(unit.ColorPalette<<4)
+		data[3] = byte(unit.Side<<7) + byte(unit.Index&127)
+		if _, err := writer.Write(data[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *FlashbackUnits) Read(reader io.Reader) error {
+	var size uint64
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	units := make([]FlashbackUnit, 0, size)
+	var data [4]byte
+	for i := 0; i < int(size); i++ {
+		if _, err := io.ReadFull(reader, data[:]); err != nil {
+			return err
+		}
+		units = append(units, FlashbackUnit{
+			XY:           UnitCoords{int(data[0]), int(data[1])},
+			Type:         int(data[2] & 15),
+			ColorPalette: int(data[2] / 16),
+			Side:         int(data[3] / 128),
+			Index:        int(data[3] & 127)})
+	}
+	*u = FlashbackUnits(units)
+	return nil
+}
+
+func (e FlashbackEvents) Write(writer io.Writer) error {
+	size := uint64(len(e))
+	if err := binary.Write(writer, binary.LittleEndian, size); err != nil {
+		return err
+	}
+	var data [4]byte
+	for _, event := range e {
+		data[0] = byte(event.XY.X)
+		data[1] = byte(event.XY.Y)
+		data[2] = byte(event.Type)
+		data[3] = byte(event.Side)
+		if _, err := writer.Write(data[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *FlashbackEvents) Read(reader io.Reader) error {
+	var size uint64
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	events := make([]FlashbackEvent, 0, size)
+	var data [4]byte
+	for i := 0; i < int(size); i++ {
+		if _, err := io.ReadFull(reader, data[:]); err != nil {
+			return err
+		}
+		if data[2] > byte(FlashbackReinforcement) || data[3] > 1 {
+			return fmt.Errorf("invalid flashback event %v", data)
+		}
+		events = append(events, FlashbackEvent{
+			Type: FlashbackEventType(data[2]),
+			Side: int(data[3]),
+			XY:   UnitCoords{int(data[0]), int(data[1])}})
+	}
+	*e = FlashbackEvents(events)
+	return nil
+}
+
+func (h FlashbackHistory) Write(writer io.Writer) error {
+	size := uint64(len(h))
+	if err := binary.Write(writer, binary.LittleEndian, size); err != nil {
+		return err
+	}
+	for _, day := range h {
+		if err := day.Units.Write(writer); err != nil {
+			return err
+		}
+		if err := day.Events.Write(writer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readV0 reads the history written by games saved before the format was versioned,
+// which listed only units of every day. Sides and indices of the units are left 0.
+func (h *FlashbackHistory) readV0(reader io.Reader) error {
+	var size uint64
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	history := make([]FlashbackDay, 0, size)
+	for i := 0; i < int(size); i++ {
+		var day FlashbackDay
+		if err := day.Units.Read(reader); err != nil {
+			return err
+		}
+		history = append(history, day)
+	}
+	*h = FlashbackHistory(history)
+	return nil
+}
+
+func (h *FlashbackHistory) Read(reader io.Reader) error {
+	var size uint64
+	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+		return err
+	}
+	history := make([]FlashbackDay, 0, size)
+	for i := 0; i < int(size); i++ {
+		var day FlashbackDay
+		if err := day.Units.Read(reader); err != nil {
+			return err
+		}
+		if err := day.Events.Read(reader); err != nil {
+			return err
+		}
+		history = append(history, day)
+	}
+	*h = FlashbackHistory(history)
+	return nil
+}
@@ -0,0 +1,60 @@
+package lib
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestFlashbackHistoryWriteRead(t *testing.T) {
+	history := FlashbackHistory{
+		{Units: FlashbackUnits{
+			{XY: UnitCoords{10, 5}, ColorPalette: 2, Type: 3, Side: 0, Index: 7},
+			{XY: UnitCoords{13, 6}, ColorPalette: 1, Type: 15, Side: 1, Index: 63}}},
+		{Units: FlashbackUnits{
+			{XY: UnitCoords{11, 6}, ColorPalette: 2, Type: 3, Side: 0, Index: 7}},
+			Events: FlashbackEvents{
+				{Type: FlashbackBattle, Side: 0, XY: UnitCoords{13, 6}},
+				{Type: FlashbackSurrender, Side: 1, XY: UnitCoords{13, 6}},
+				{Type: FlashbackCapture, Side: 0, XY: UnitCoords{12, 6}},
+				{Type: FlashbackReinforcement, Side: 1, XY: UnitCoords{40, 20}}}}}
+	var buf bytes.Buffer
+	if err := history.Write(&buf); err != nil {
+		t.Fatal("Cannot write flashback history,", err)
+	}
+	var readHistory FlashbackHistory
+	if err := readHistory.Read(&buf); err != nil {
+		t.Fatal("Cannot read flashback history,", err)
+	}
+	if len(readHistory[0].Events) != 0 {
+		t.Errorf("Unexpected events %v", readHistory[0].Events)
+	}
+	readHistory[0].Events = nil
+	if !reflect.DeepEqual(history, readHistory) {
+		t.Errorf("Expected %v, got %v", history, readHistory)
+	}
+}
+
+func TestFrontLine(t *testing.T) {
+	units := FlashbackUnits{
+		{XY: UnitCoords{4, 4}, Side: 0},
+		{XY: UnitCoords{12, 4}, Side: 1}}
+	segments := units.FrontLine(0, 0, 10, 10)
+	if len(segments) == 0 {
+		t.Fatal("Expected a front line between the units")
+	}
+	for _, segment := range segments {
+		side0 := units.nearestSide(segment.XY0.ToUnitCoords())
+		side1 := units.nearestSide(segment.XY1.ToUnitCoords())
+		if side0 < 0 || side1 < 0 || side0 == side1 {
+			t.Errorf("Segment %v doesn't separate tiles of different sides", segment)
+		}
+		// The front line is half way between the units.
+		if segment.XY0.X < 3 || segment.XY0.X > 6 {
+			t.Errorf("Segment %v too far from the middle", segment)
+		}
+	}
+	if segments := (FlashbackUnits{{XY: UnitCoords{4, 4}, Side: 0}}).FrontLine(0, 0, 10, 10); len(segments) != 0 {
+		t.Errorf("Unexpected front line with units of a single side %v", segments)
+	}
+}
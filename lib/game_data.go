@@ -65,6 +65,21 @@ func LoadGameData(fsys fs.FS) (*GameData, error) {
 
 }
 
+// clone returns a copy of the scenario data, which doesn't share units, cities
+// and data modified during the game with the original.
+func (d *ScenarioData) clone() *ScenarioData {
+	terrain := *d.Terrain
+	terrain.Cities = append(Cities(nil), d.Terrain.Cities...)
+	data := *d.Data
+	units := d.Units.clone()
+	return &ScenarioData{
+		Variants: d.Variants,
+		Generals: d.Generals,
+		Terrain:  &terrain,
+		Data:     &data,
+		Units:    &units}
+}
+
 func LoadScenarioData(fsys fs.FS, filePrefix string) (*ScenarioData, error) {
 	game, err := FilePrefixToGame(filePrefix)
 	if err != nil {
@@ -1,13 +1,17 @@
 package lib
 
 import (
+	"bytes"
 	"encoding/binary"
+	"encoding/json"
+	"fmt"
 	"io"
 	"math/rand"
 )
 
 type GameState struct {
-	rand *rand.Rand
+	randSource *RandSource
+	rand       *rand.Rand
 
 	game Game
 
@@ -21,11 +25,19 @@ type GameState struct {
 	isNight      bool
 	supplyLevels [2]int
 
+	// Weather for the following days (used by the ForecastWeather and RegionalWeather models).
+	weatherForecast [WeatherForecastDays]int
+	weatherTable    WeatherTable
+	// Local weather differences (nil unless the RegionalWeather model is used).
+	weatherRegions *WeatherRegions
+
 	commanderFlags                   *CommanderFlags
 	unitsUpdated                     int
 	numUnitsToUpdatePerTimeIncrement int
 
 	flashback FlashbackHistory
+	// Game states saved at the end of every day.
+	snapshots []snapshot
 
 	score *Score
 	ai    *AI
@@ -39,20 +51,35 @@ type GameState struct {
 	generals        *Generals
 	variants        []Variant
 	selectedVariant int
-	options         *Options
+	// Scenario being played, its bounds limit the part of the map used by the game.
+	scenario *Scenario
+	options  *Options
 
 	sync *MessageSync
 
+	script *ScenarioScript
+	// Encoder of the log of decisions of the computer commander (nil if they are not logged).
+	decisionLog *json.Encoder
+	// Set in copies played out by the lookahead computer commander, which don't keep
+	// the flashback, take snapshots, run the script or log decisions.
+	simulation bool
+
 	allUnitsHidden bool
 }
 
-func NewGameState(rand *rand.Rand, gameData *GameData, scenarioData *ScenarioData, scenarioNum, variantNum int, options *Options, sync *MessageSync) *GameState {
+func NewGameState(randSource *RandSource, gameData *GameData, scenarioData *ScenarioData, scenarioNum, variantNum int, options *Options, sync *MessageSync) *GameState {
+	// The game modifies units, cities, scenario data and the terrain type map,
+	// so it works on copies to let many games share the loaded data.
+	gameDataCopy := *gameData
+	gameDataCopy.TerrainTypeMap = gameData.TerrainTypeMap.clone()
+	gameData = &gameDataCopy
+	scenarioData = scenarioData.clone()
 	scenario := &gameData.Scenarios[scenarioNum]
 	variant := &scenarioData.Variants[variantNum]
-	sunriseOffset := Abs(6-scenario.StartMonth) / 2
 	s := &GameState{}
 	s.game = gameData.Game
-	s.rand = rand
+	s.randSource = randSource
+	s.rand = rand.New(randSource)
 	s.game = gameData.Game
 	s.minute = scenario.StartMinute
 	s.hour = scenario.StartHour
@@ -60,7 +87,7 @@ func NewGameState(rand *rand.Rand, gameData *GameData, scenarioData *ScenarioDat
 	s.month = scenario.StartMonth
 	s.year = scenario.StartYear
 	s.weather = scenario.StartWeather
-	s.isNight = s.hour < 5+sunriseOffset || s.hour > 20-sunriseOffset
+	s.isNight = isNightAt(s.hour, s.day, s.month, s.year, options.Calendar, s.game)
 	s.supplyLevels = scenario.StartSupplyLevels
 	s.numUnitsToUpdatePerTimeIncrement = scenarioData.Data.UnitUpdatesPerTimeIncrement / 2
 	s.scenarioData = scenarioData.Data
@@ -72,11 +99,21 @@ func NewGameState(rand *rand.Rand, gameData *GameData, scenarioData *ScenarioDat
 	s.generals = scenarioData.Generals
 	s.variants = scenarioData.Variants
 	s.selectedVariant = variantNum
+	s.scenario = scenario
 	s.commanderFlags = newCommanderFlags(options)
 	s.score = newScore(s.game, *variant, scenarioData.Data, s.commanderFlags, options)
-	s.ai = newAI(rand, s.commanderFlags, gameData, scenarioData, s.score)
+	s.ai = newAI(s.rand, s.commanderFlags, options, gameData, scenarioData, s.score)
 	s.options = options
 	s.sync = sync
+	s.weatherTable = DefaultWeatherTable(scenarioData.Data)
+	if options.Weather.HasForecast() {
+		s.initWeatherForecast()
+	}
+	if options.Weather == RegionalWeather {
+		s.weatherRegions = &WeatherRegions{}
+		s.weatherRegions.generate(s.rand)
+	}
+	s.ai.weatherRegions = s.weatherRegions
 
 	for side, sideUnits := range s.units {
 		for i, unit := range sideUnits {
@@ -114,6 +151,54 @@ func NewGameState(rand *rand.Rand, gameData *GameData, scenarioData *ScenarioDat
 	return s
 }
 
+// SetScript sets the scenario script run at the end of hourly, half-daily and daily updates.
+// A nil script disables scripting.
+func (s *GameState) SetScript(script *ScenarioScript) {
+	s.script = script
+}
+
+// Clone returns a copy of the game state, which can be played independently of the original
+// and continues with the same random numbers. Updates of the copy are discarded and its decisions
+// are not logged.
+func (s *GameState) Clone() *GameState {
+	return s.clone(s.randSource.Clone(), newDiscardingMessageSync(), false)
+}
+
+// clone returns a copy of the game state using given random number source and sync.
+// A simulation copy starts with no history of the game and without the script.
+func (s *GameState) clone(randSource *RandSource, sync *MessageSync, simulation bool) *GameState {
+	c := *s
+	c.randSource = randSource
+	c.rand = rand.New(randSource)
+	c.sync = sync
+	c.decisionLog = nil
+	c.simulation = simulation
+	commanderFlags := *s.commanderFlags
+	c.commanderFlags = &commanderFlags
+	options := *s.options
+	c.options = &options
+	scenarioData := s.ScenarioData().clone()
+	c.scenarioData, c.terrain, c.units = scenarioData.Data, scenarioData.Terrain, scenarioData.Units
+	c.terrainTypes = s.terrainTypes.clone()
+	if s.weatherRegions != nil {
+		weatherRegions := *s.weatherRegions
+		c.weatherRegions = &weatherRegions
+	}
+	if simulation {
+		c.flashback, c.snapshots, c.script = nil, nil, nil
+	} else {
+		c.flashback = append(FlashbackHistory(nil), s.flashback...)
+		c.snapshots = append([]snapshot(nil), s.snapshots...)
+	}
+	score := *s.score
+	score.scenarioData = c.scenarioData
+	score.commanderFlags = c.commanderFlags
+	score.options = c.options
+	c.score = &score
+	c.ai = s.ai.clone(&c)
+	return &c
+}
+
 func (s *GameState) Init() bool {
 	if !s.everyHour() {
 		return false
@@ -130,6 +215,8 @@ type saveData struct {
 	DaysElapsed              uint8
 	Weather                  uint8
 	IsNight                  bool
+	WeatherForecast          [WeatherForecastDays]uint8
+	WeatherRegions           [16][16]int8
 
 	CommanderFlags uint8
 
@@ -142,6 +229,7 @@ type saveData struct {
 	NumUnitsToUpdatePerTimeIncrement uint8
 	LastUpdatedUnit                  uint8
 	Update                           uint8
+	PlannedAILevels                  [2]uint8
 
 	Map0           [2][16][16]int16
 	Map1           [2][16][16]int16
@@ -149,8 +237,110 @@ type saveData struct {
 	Map2_0, Map2_1 [2][4][4]int16
 }
 
+// saveDataV0 is the layout of saveData in games saved before the format was versioned.
+type saveDataV0 struct {
+	Minute, Hour, Day, Month uint8
+	Year                     uint16
+	DaysElapsed              uint8
+	Weather                  uint8
+	IsNight                  bool
+
+	CommanderFlags uint8
+
+	SupplyLevels, MenLost, TanksLost, CitiesHeld [2]uint16
+	CriticalLocationsCaptured                    [2]uint8
+
+	SelectedVariant uint8
+
+	UnitsUpdated                     uint8
+	NumUnitsToUpdatePerTimeIncrement uint8
+	LastUpdatedUnit                  uint8
+	Update                           uint8
+
+	Map0           [2][16][16]int16
+	Map1           [2][16][16]int16
+	Map3           [2][16][16]int16
+	Map2_0, Map2_1 [2][4][4]int16
+}
+
+func (d *saveDataV0) upgrade() saveData {
+	return saveData{
+		Minute:                           d.Minute,
+		Hour:                             d.Hour,
+		Day:                              d.Day,
+		Month:                            d.Month,
+		Year:                             d.Year,
+		DaysElapsed:                      d.DaysElapsed,
+		Weather:                          d.Weather,
+		IsNight:                          d.IsNight,
+		CommanderFlags:                   d.CommanderFlags,
+		SupplyLevels:                     d.SupplyLevels,
+		MenLost:                          d.MenLost,
+		TanksLost:                        d.TanksLost,
+		CitiesHeld:                       d.CitiesHeld,
+		CriticalLocationsCaptured:        d.CriticalLocationsCaptured,
+		SelectedVariant:                  d.SelectedVariant,
+		UnitsUpdated:                     d.UnitsUpdated,
+		NumUnitsToUpdatePerTimeIncrement: d.NumUnitsToUpdatePerTimeIncrement,
+		LastUpdatedUnit:                  d.LastUpdatedUnit,
+		Update:                           d.Update,
+		Map0:                             d.Map0,
+		Map1:                             d.Map1,
+		Map3:                             d.Map3,
+		Map2_0:                           d.Map2_0,
+		Map2_1:                           d.Map2_1}
+}
+
+// Saved games start with the magic and the version of the format.
+// Games saved before the format was versioned start with units, they are read as version 0.
+var saveMagic = [4]byte{'C', 'S', 'G', 'S'}
+
+const saveVersion = 1
+
 func (s *GameState) Save(writer io.Writer) error {
-	if err := s.units.Write(writer); err != nil {
+	return s.save(writer, s.units, s.flashback, s.ai.dayEvents, true)
+}
+
+// WriteView writes the game state as seen by the given side. Enemy units not visible
+// to the side are written as placeholders keeping only their side, index and type,
+// the flashback history and events are limited to what the side knows,
+// and the state of the computer player is left out.
+func (s *GameState) WriteView(writer io.Writer, side int) error {
+	view := s.ViewFor(side)
+	var units Units
+	for unitsSide, sideUnits := range s.units {
+		units[unitsSide] = make([]Unit, len(sideUnits))
+		for i, unit := range sideUnits {
+			if !view.IsUnitVisible(unit) {
+				unit = Unit{Side: unit.Side, Index: unit.Index, Type: unit.Type}
+			}
+			units[unitsSide][i] = unit
+		}
+	}
+	return s.save(writer, &units, view.flashback(s.flashback), view.events(s.ai.dayEvents), false)
+}
+
+// ReadView replaces the game state with a state written by WriteView.
+// Commander flags of the game are kept intact.
+func (s *GameState) ReadView(reader io.Reader) error {
+	allUnitsHidden := s.allUnitsHidden
+	if !allUnitsHidden {
+		s.HideAllUnits()
+	}
+	commanderFlags := *s.commanderFlags
+	err := s.load(reader, false)
+	*s.commanderFlags = commanderFlags
+	if !allUnitsHidden {
+		s.ShowAllVisibleUnits()
+	}
+	return err
+}
+
+func (s *GameState) save(writer io.Writer, units *Units, flashback FlashbackHistory, dayEvents FlashbackEvents, withAIState bool) error {
+	if _, err := writer.Write(append(saveMagic[:], saveVersion)); err != nil {
+		return err
+	}
+	if err := units.Write(writer); err != nil {
 		return err
 	}
 	if err := s.terrain.Cities.WriteOwnerAndVictoryPoints(writer); err != nil {
@@ -168,6 +358,16 @@ func (s *GameState) Save(writer io.Writer) error {
 	saveData.DaysElapsed = uint8(s.daysElapsed)
 	saveData.Weather = uint8(s.weather)
 	saveData.IsNight = s.isNight
+	for i, weather := range s.weatherForecast {
+		saveData.WeatherForecast[i] = uint8(weather)
+	}
+	if s.weatherRegions != nil {
+		for x := 0; x < 16; x++ {
+			for y := 0; y < 16; y++ {
+				saveData.WeatherRegions[x][y] = int8(s.weatherRegions[x][y])
+			}
+		}
+	}
 	saveData.CommanderFlags = s.commanderFlags.Serialize()
 	saveData.SupplyLevels = [2]uint16{uint16(s.supplyLevels[0]), uint16(s.supplyLevels[1])}
 	saveData.MenLost = [2]uint16{uint16(s.score.MenLost[0]), uint16(s.score.MenLost[1])}
@@ -181,8 +381,9 @@ func (s *GameState) Save(writer io.Writer) error {
 	saveData.NumUnitsToUpdatePerTimeIncrement = uint8(s.numUnitsToUpdatePerTimeIncrement)
 	saveData.LastUpdatedUnit = uint8(s.ai.lastUpdatedUnit)
 	saveData.Update = uint8(s.ai.update)
+	saveData.PlannedAILevels = [2]uint8{uint8(s.ai.plannedLevels[0]), uint8(s.ai.plannedLevels[1])}
 
-	for i := 0; i < 2; i++ {
+	for i := 0; withAIState && i < 2; i++ {
 		for x := 0; x < 16; x++ {
 			for y := 0; y < 16; y++ {
 				saveData.Map0[i][x][y] = int16(s.ai.map0[i][x][y])
@@ -201,9 +402,18 @@ func (s *GameState) Save(writer io.Writer) error {
 	if err := binary.Write(writer, binary.LittleEndian, saveData); err != nil {
 		return err
 	}
-	if err := s.flashback.Write(writer); err != nil {
+	if err := flashback.Write(writer); err != nil {
+		return err
+	}
+	if err := dayEvents.Write(writer); err != nil {
 		return err
 	}
+	// Random numbers are part of the state of the computer player, the other side must not predict them.
+	if withAIState {
+		if err := s.randSource.Write(writer); err != nil {
+			return err
+		}
+	}
 	// array of saved game state numbers (first 19 single byte values mirrored to v10_ array)
 	//   mapped to memory 29927-28 + i:
 	// 0, 1, 2, 3, 4: minute, hour, day, month, year
@@ -228,11 +438,31 @@ func (s *GameState) Save(writer io.Writer) error {
 	return nil
 }
 func (s *GameState) Load(reader io.Reader) error {
+	// Snapshots of the previous game cannot be continued from the loaded game.
+	s.snapshots = nil
+	return s.load(reader, true)
+}
+func (s *GameState) load(reader io.Reader, withAIState bool) error {
+	var header [5]byte
+	n, err := io.ReadFull(reader, header[:])
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	version := 0
+	if n == len(header) && [4]byte(header[:4]) == saveMagic {
+		version = int(header[4])
+		if version != saveVersion {
+			return fmt.Errorf("unsupported saved game version %d", version)
+		}
+	} else {
+		reader = io.MultiReader(bytes.NewReader(header[:n]), reader)
+	}
 	units, err := ParseUnits(reader, s.scenarioData.UnitTypes, s.scenarioData.UnitNames, s.generals)
 	if err != nil {
 		return err
 	}
-	s.units = units
+	// Units are shared with the AI and the UI, so update them in place.
+	*s.units = *units
 	if err := s.terrain.Cities.ReadOwnerAndVictoryPoints(reader); err != nil {
 		return err
 	}
@@ -240,7 +470,13 @@ func (s *GameState) Load(reader io.Reader) error {
 		return err
 	}
 	var saveData saveData
-	if err := binary.Read(reader, binary.LittleEndian, &saveData); err != nil {
+	if version == 0 {
+		var saveDataV0 saveDataV0
+		if err := binary.Read(reader, binary.LittleEndian, &saveDataV0); err != nil {
+			return err
+		}
+		saveData = saveDataV0.upgrade()
+	} else if err := binary.Read(reader, binary.LittleEndian, &saveData); err != nil {
 		return err
 	}
 	s.minute = int(saveData.Minute)
@@ -251,7 +487,26 @@ func (s *GameState) Load(reader io.Reader) error {
 	s.daysElapsed = int(saveData.DaysElapsed)
 	s.weather = int(saveData.Weather)
 	s.isNight = saveData.IsNight
-	s.commanderFlags.Deserialize(saveData.CommanderFlags)
+	for i, weather := range saveData.WeatherForecast {
+		s.weatherForecast[i] = int(weather)
+	}
+	if s.options.Weather == RegionalWeather {
+		s.weatherRegions = &WeatherRegions{}
+		for x := 0; x < 16; x++ {
+			for y := 0; y < 16; y++ {
+				s.weatherRegions[x][y] = int(saveData.WeatherRegions[x][y])
+			}
+		}
+	} else {
+		s.weatherRegions = nil
+	}
+	s.ai.weatherRegions = s.weatherRegions
+	if version == 0 {
+		// Commander flags weren't saved correctly, but they were set by the options.
+		*s.commanderFlags = *newCommanderFlags(s.options)
+	} else {
+		s.commanderFlags.Deserialize(saveData.CommanderFlags)
+	}
 	s.supplyLevels = [2]int{int(saveData.SupplyLevels[0]), int(saveData.SupplyLevels[1])}
 	s.score.MenLost = [2]int{int(saveData.MenLost[0]), int(saveData.MenLost[1])}
 	s.score.TanksLost = [2]int{int(saveData.TanksLost[0]), int(saveData.TanksLost[1])}
@@ -264,6 +519,7 @@ func (s *GameState) Load(reader io.Reader) error {
 	s.numUnitsToUpdatePerTimeIncrement = int(saveData.NumUnitsToUpdatePerTimeIncrement)
 	s.ai.lastUpdatedUnit = int(saveData.LastUpdatedUnit)
 	s.ai.update = int(saveData.Update)
+	s.ai.plannedLevels = [2]AILevel{AILevel(saveData.PlannedAILevels[0]), AILevel(saveData.PlannedAILevels[1])}
 
 	for i := 0; i < 2; i++ {
 		for x := 0; x < 16; x++ {
@@ -280,9 +536,27 @@ func (s *GameState) Load(reader io.Reader) error {
 			}
 		}
 	}
+	if version == 0 {
+		// Units of the flashback were saved without their sides and indices,
+		// and without events. Events of the day and random numbers weren't saved.
+		if err := s.flashback.readV0(reader); err != nil {
+			return err
+		}
+		s.ai.dayEvents = nil
+		return nil
+	}
 	if err := s.flashback.Read(reader); err != nil {
 		return err
 	}
+	if err := s.ai.dayEvents.Read(reader); err != nil {
+		return err
+	}
+	// Continue with the same random numbers as the saved game.
+	if withAIState {
+		if err := s.randSource.Read(reader); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -296,6 +570,10 @@ func (s *GameState) Update() bool {
 		if quit {
 			return false
 		}
+		if s.decisionLog != nil && s.logDecision(message) != nil {
+			// Stop logging if the log cannot be written to.
+			s.SetDecisionLog(nil)
+		}
 		if !s.sync.SendUpdate(message) {
 			return false
 		}
@@ -311,8 +589,7 @@ func (s *GameState) Update() bool {
 		s.hour = 0
 		s.day++
 	}
-	// game treats all months to have 30 days.
-	if s.day >= 30 { // monthLength(s.month+1, s.year+1900) {
+	if s.day >= s.monthLength() {
 		s.day = 0
 		s.month++
 	}
@@ -325,10 +602,15 @@ func (s *GameState) Update() bool {
 		if !s.everyHour() {
 			return false
 		}
+		if s.hour%lookaheadHours == 0 {
+			s.planLookahead()
+		}
 		if s.hour == 0 {
 			if !s.everyDay() {
 				return false
 			}
+			// The game can be resumed from here, as the next update starts with updating units.
+			s.takeSnapshot()
 		}
 		if s.hour == 18 {
 			if s.isGameOver() {
@@ -341,9 +623,16 @@ func (s *GameState) Update() bool {
 	return true
 }
 
+func (s *GameState) monthLength() int {
+	if s.options.Calendar == RealCalendar {
+		return monthLength(s.month+1, fullYear(s.year))
+	}
+	// game treats all months to have 30 days.
+	return 30
+}
+
 func (s *GameState) everyHour() bool {
-	sunriseOffset := Abs(6-s.month) / 2
-	s.isNight = s.hour < 5+sunriseOffset || s.hour > 20-sunriseOffset
+	s.isNight = isNightAt(s.hour, s.day, s.month, s.year, s.options.Calendar, s.game)
 
 	if s.hour == 12 {
 		if !s.every12Hours() {
@@ -365,7 +654,7 @@ func (s *GameState) everyHour() bool {
 		}
 
 	}
-	return true
+	return s.runScript("every_hour")
 }
 
 func (s *GameState) every12Hours() bool {
@@ -397,6 +686,7 @@ func (s *GameState) every12Hours() bool {
 					}
 					if shouldSpawnUnit {
 						unit.IsInGame = true
+						s.ai.recordEvent(FlashbackReinforcement, unit.Side, unit.XY)
 						// Unit will be shown if needed inside ShowAllUnits at the end of the function.
 						reinforcements[unit.Side] = true
 					} else {
@@ -431,6 +721,28 @@ func (s *GameState) every12Hours() bool {
 			return false
 		}
 	}
+	return s.runScript("every_12_hours")
+}
+
+func (s *GameState) runScript(hook string) bool {
+	if s.script == nil || s.simulation {
+		return true
+	}
+	effects, err := s.script.call(hook, s)
+	if err != nil {
+		s.script = nil
+		return s.sync.SendUpdate(ScriptError{Err: err})
+	}
+	if effects.reinforcements[0] || effects.reinforcements[1] {
+		if !s.sync.SendUpdate(Reinforcements{Sides: effects.reinforcements}) {
+			return false
+		}
+	}
+	for _, text := range effects.messages {
+		if !s.sync.SendUpdate(ScriptMessage{Text: text}) {
+			return false
+		}
+	}
 	return true
 }
 
@@ -448,6 +760,11 @@ func (s *GameState) HideAllUnits() {
 func (s *GameState) IsUnitVisible(unit Unit) bool {
 	return unit.IsInGame && (unit.InContactWithEnemy || unit.SeenByEnemy || s.commanderFlags.PlayerCanSeeUnits[unit.Side])
 }
+
+// IsUnitVisibleToSide returns true if the player commanding the given side can see the unit.
+func (s *GameState) IsUnitVisibleToSide(unit Unit, side int) bool {
+	return s.ViewFor(side).IsUnitVisible(unit)
+}
 func (s *GameState) ShowAllVisibleUnits() {
 	s.allUnitsHidden = false
 	for _, sideUnits := range s.units {
@@ -473,18 +790,34 @@ func (s *GameState) everyDay() bool {
 			}
 			if unit.IsInGame {
 				flashback = append(flashback, FlashbackUnit{
-					XY: unit.XY, ColorPalette: unit.ColorPalette, Type: unit.Type})
+					XY: unit.XY, ColorPalette: unit.ColorPalette, Type: unit.Type,
+					Side: unit.Side, Index: unit.Index})
 			}
 		}
 	}
 	s.numUnitsToUpdatePerTimeIncrement = (numActiveUnits*s.scenarioData.UnitUpdatesPerTimeIncrement)/128 + 1
 
-	s.flashback = append(s.flashback, flashback)
-	rnd := Rand(256, s.rand)
-	if rnd < 140 {
-		s.weather = int(s.scenarioData.PossibleWeather[4*(s.month/3)+rnd/35])
+	if !s.simulation {
+		s.flashback = append(s.flashback, FlashbackDay{Units: flashback, Events: s.ai.dayEvents})
+	}
+	s.ai.dayEvents = nil
+	if s.options.Weather.HasForecast() {
+		s.weather = s.weatherForecast[0]
+		copy(s.weatherForecast[:], s.weatherForecast[1:])
+		s.weatherForecast[WeatherForecastDays-1] = s.weatherTable.next(s.weatherForecast[WeatherForecastDays-2], s.month, s.rand)
+		forecast := make([]int, WeatherForecastDays)
+		copy(forecast, s.weatherForecast[:])
+		if s.weatherRegions != nil {
+			s.weatherRegions.generate(s.rand)
+		}
+		s.sync.SendUpdate(WeatherForecast{Weather: s.weather, Forecast: forecast})
+	} else {
+		rnd := Rand(256, s.rand)
+		if rnd < 140 {
+			s.weather = int(s.scenarioData.PossibleWeather[4*(s.month/3)+rnd/35])
+		}
+		s.sync.SendUpdate(WeatherForecast{Weather: s.weather})
 	}
-	s.sync.SendUpdate(WeatherForecast{s.weather})
 	if !s.every12Hours() {
 		return false
 	}
@@ -493,6 +826,9 @@ func (s *GameState) everyDay() bool {
 			s.scenarioData.UpdateData(update.Offset, update.Value)
 		}
 	}
+	if !s.runScript("every_day") {
+		return false
+	}
 	s.sync.SendUpdate(DailyUpdate{
 		DaysRemaining: s.variants[s.selectedVariant].LengthInDays - s.daysElapsed + 1,
 		SupplyLevels:  s.supplyLevels})
@@ -500,27 +836,6 @@ func (s *GameState) everyDay() bool {
 	return true
 }
 
-/*func monthLength(month, year int) int {
-	switch month {
-	case 1, 3, 5, 7, 8, 10, 12:
-		return 31
-	case 4, 6, 9, 11:
-		return 30
-	case 2:
-		if year%400 == 0 {
-			return 29
-		}
-		if year%100 == 0 {
-			return 28
-		}
-		if year%4 == 0 {
-			return 29
-		}
-		return 28
-	}
-	panic(fmt.Errorf("Unexpected month number %d", month))
-}*/
-
 func (s *GameState) WinningSideAndAdvantage() (winningSide int, advantage int) {
 	return s.score.WinningSideAndAdvantage()
 }
@@ -529,10 +844,18 @@ func (s *GameState) FinalResults(playerSide int) (int, int, int) {
 	return s.score.FinalResults(playerSide)
 }
 func (s *GameState) isGameOver() bool {
+	if winner, ok := s.score.rules.suddenDeathWinner(s.score, s.terrain.Cities, s.daysElapsed); ok {
+		s.score.suddenDeath = true
+		s.score.suddenDeathWinner = winner
+		return true
+	}
 	variant := s.variants[s.selectedVariant]
 	if s.daysElapsed >= variant.LengthInDays {
 		return true
 	}
+	if !s.score.rules.CriticalLocations {
+		return false
+	}
 	criticalLocationBalance := s.score.CriticalLocationsCaptured[0] - s.score.CriticalLocationsCaptured[1]
 	if criticalLocationBalance >= variant.CriticalLocations[0] {
 		return true
@@ -543,6 +866,35 @@ func (s *GameState) isGameOver() bool {
 	return false
 }
 
+func (s *GameState) initWeatherForecast() {
+	weather := s.weather
+	for i := range s.weatherForecast {
+		weather = s.weatherTable.next(weather, s.month, s.rand)
+		s.weatherForecast[i] = weather
+	}
+}
+
+// SetWeatherTable replaces the default weather statistics used by the ForecastWeather and RegionalWeather models.
+func (s *GameState) SetWeatherTable(table WeatherTable) error {
+	if err := table.validate(len(s.scenarioData.Weather)); err != nil {
+		return err
+	}
+	s.weatherTable = table
+	if s.options.Weather.HasForecast() {
+		s.initWeatherForecast()
+	}
+	return nil
+}
+
+// SetVictoryRules replaces the default victory rules of the game.
+func (s *GameState) SetVictoryRules(rules VictoryRules) error {
+	if err := rules.checkCities(s.terrain.Cities); err != nil {
+		return err
+	}
+	s.score.rules = rules
+	return nil
+}
+
 func (s *GameState) Minute() int {
 	return s.minute
 }
@@ -564,6 +916,60 @@ func (s *GameState) Year() int {
 func (s *GameState) Weather() string {
 	return s.scenarioData.Weather[s.weather]
 }
+
+// LocalWeatherDifferences returns difference between the local and the global weather
+// in every region of the map (positive if the local weather is worse) and true
+// if the weather may differ between regions.
+func (s *GameState) LocalWeatherDifferences() (differences [16][16]int, ok bool) {
+	if s.weatherRegions == nil {
+		return differences, false
+	}
+	numWeatherTypes := len(s.scenarioData.Weather)
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			differences[x][y] = s.weatherRegions.weatherAt(UnitCoords{X: x * 8, Y: y * 4}, s.weather, numWeatherTypes) - s.weather
+		}
+	}
+	return differences, true
+}
+
+// InfluenceMaps are the strategy level maps computed by the computer commander.
+// Each map is a 16x16 grid, each square covering 4x4 map tiles.
+type InfluenceMaps struct {
+	// Number of men and tanks of the side in every square.
+	Troops [16][16]int
+	// Influence of the side's units and important cities on the surrounding squares.
+	Influence [16][16]int
+	// Defensive strength of the side's units including terrain and cities held by the side.
+	Defence [16][16]int
+	// Troops and Influence aggregated on a 4x4 grid, each square covering 16x16 map tiles.
+	AggregatedTroops, AggregatedInfluence [4][4]int
+}
+
+// InfluenceMaps returns copies of the maps of the side as last computed by the computer commander.
+func (s *GameState) InfluenceMaps(side int) InfluenceMaps {
+	return InfluenceMaps{
+		Troops:    s.ai.map0[side],
+		Influence: s.ai.map1[side],
+		Defence:   s.ai.map3[side],
+
+		AggregatedTroops:    s.ai.map2_0[side],
+		AggregatedInfluence: s.ai.map2_1[side]}
+}
+
+// ObjectiveDecision returns how the computer commander most recently chose
+// the objective of the unit and true if it has ever done so.
+func (s *GameState) ObjectiveDecision(side, index int) (ObjectiveDecision, bool) {
+	if side < 0 || side > 1 || index < 0 || index >= len(s.ai.objectiveDecisions[side]) {
+		return ObjectiveDecision{}, false
+	}
+	decision := s.ai.objectiveDecisions[side][index]
+	if len(decision.Candidates) == 0 {
+		return ObjectiveDecision{}, false
+	}
+	decision.Candidates = append([]ObjectiveCandidate(nil), decision.Candidates...)
+	return decision, true
+}
 func (s *GameState) MenLost(side int) int {
 	return s.score.MenLost[side] * s.scenarioData.MenMultiplier
 }
@@ -576,6 +982,17 @@ func (s *GameState) CitiesHeld(side int) int {
 func (s *GameState) Flashback() FlashbackHistory {
 	return s.flashback
 }
+
+// ScenarioData returns the scenario data with units, cities and scenario data owned by the game.
+func (s *GameState) ScenarioData() *ScenarioData {
+	return &ScenarioData{
+		Variants: s.variants,
+		Generals: s.generals,
+		Terrain:  s.terrain,
+		Data:     s.scenarioData,
+		Units:    s.units}
+}
+
 func (s *GameState) TerrainTypeMap() *TerrainTypeMap {
 	return s.terrainTypes
 }
@@ -1,18 +1,21 @@
 package lib
 
 import (
+	"bytes"
+	"encoding/binary"
 	"math/rand"
+	"reflect"
 	"testing"
 )
 
 func createTestGameState(filename string, scenarioNum, variantNum int, options Options, messageSync *MessageSync, t *testing.T) *GameState {
-	rand := rand.New(rand.NewSource(1))
+	randSource := NewRandSource(1)
 	gameData, scenarioData, err := readTestData(filename, scenarioNum)
 	if err != nil {
 		t.Fatal("Error reading game data,", err)
 	}
 
-	return NewGameState(rand, gameData, scenarioData, scenarioNum, variantNum, &options, messageSync)
+	return NewGameState(randSource, gameData, scenarioData, scenarioNum, variantNum, &options, messageSync)
 }
 
 func TestRegression_Basic(t *testing.T) {
@@ -249,3 +252,189 @@ func TestRegression_Conflict_FullIntelligence(t *testing.T) {
 			expectedResult, expectedBalance, expectedRank, result, balance, rank)
 	}
 }
+
+func TestCloneIsIndependent(t *testing.T) {
+	randSource := NewRandSource(1)
+	var units Units
+	units[0] = []Unit{{Side: 0, Index: 0, IsInGame: true, XY: UnitCoords{2, 2}, MenCount: 10}}
+	options := DefaultOptions()
+	commanderFlags := newCommanderFlags(&options)
+	data := &Data{MinutesPerTick: 30}
+	s := &GameState{
+		randSource:     randSource,
+		rand:           rand.New(randSource),
+		units:          &units,
+		terrain:        &Terrain{Cities: Cities{{Owner: 0, VictoryPoints: 5}}},
+		terrainTypes:   &TerrainTypeMap{units: make([]bool, 4)},
+		weatherRegions: &WeatherRegions{},
+		options:        &options,
+		commanderFlags: commanderFlags,
+		scenarioData:   data,
+		score:          newScore(Crusade, Variant{}, data, commanderFlags, &options)}
+	s.ai = &AI{rand: s.rand, units: &units, options: &options, commanderFlags: commanderFlags, score: s.score}
+	s.ai.newObjectiveDecision(units[0][0], Attack)
+
+	c := s.Clone()
+	if c.ai.units != c.units || c.ai.score != c.score || c.ai.options != c.options || c.score.options != c.options || c.ai.rand != c.rand {
+		t.Error("The copy of the AI doesn't refer to the copied state")
+	}
+	c.units[0][0].MenCount = 5
+	c.terrain.Cities[0].Owner = 1
+	c.terrainTypes.units[0] = true
+	c.weatherRegions[0][0] = 1
+	c.options.Weather = RegionalWeather
+	c.commanderFlags.PlayerControlled[0] = false
+	c.scenarioData.MinutesPerTick = 60
+	c.score.MenLost[0] = 100
+	c.ai.map0[0][0][0] = 7
+	c.ai.newObjectiveDecision(c.units[0][0], Defend)
+	if units[0][0].MenCount != 10 || s.terrain.Cities[0].Owner != 0 || s.terrainTypes.units[0] || s.weatherRegions[0][0] != 0 {
+		t.Error("Units, cities or the map modified through the copy")
+	}
+	if options.Weather != OriginalWeather || !commanderFlags.PlayerControlled[0] || data.MinutesPerTick != 30 || s.score.MenLost[0] != 0 {
+		t.Error("Options or scenario data modified through the copy")
+	}
+	if s.ai.map0[0][0][0] != 0 || s.ai.objectiveDecisions[0][0].Order != Attack {
+		t.Error("State of the AI modified through the copy")
+	}
+
+	if c.rand.Int63() != s.rand.Int63() || c.rand.Intn(100) != s.rand.Intn(100) {
+		t.Error("Expected the copy to continue with the same random numbers")
+	}
+	if !c.sync.SendUpdate(TimeChanged{}) {
+		t.Error("Expected updates of the copy to be discarded")
+	}
+}
+
+func TestLoadSaveWithoutVersion(t *testing.T) {
+	options := DefaultOptions()
+	saved := newTestGameState(options)
+	saved.units[0][1].XY = UnitCoords{16, 6}
+	// A game saved before the format was versioned.
+	var game bytes.Buffer
+	if err := saved.units.Write(&game); err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.terrain.Cities.WriteOwnerAndVictoryPoints(&game); err != nil {
+		t.Fatal(err)
+	}
+	if err := saved.scenarioData.WriteFirst255Bytes(&game); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(&game, binary.LittleEndian, saveDataV0{Hour: 15, DaysElapsed: 3, CommanderFlags: 1, MenLost: [2]uint16{12, 34}}); err != nil {
+		t.Fatal(err)
+	}
+	// The flashback was a list of days, each listing units without their sides and indices.
+	flashbackDays := []FlashbackUnits{
+		{{XY: UnitCoords{10, 4}, Type: 3, ColorPalette: 1}},
+		{{XY: UnitCoords{12, 4}, Type: 3, ColorPalette: 1}, {XY: UnitCoords{14, 10}, Type: 5, ColorPalette: 2}}}
+	if err := binary.Write(&game, binary.LittleEndian, uint64(len(flashbackDays))); err != nil {
+		t.Fatal(err)
+	}
+	for _, units := range flashbackDays {
+		if err := units.Write(&game); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newTestGameState(options)
+	s.ai.dayEvents = FlashbackEvents{{Type: FlashbackBattle}}
+	if err := s.Load(&game); err != nil {
+		t.Fatal(err)
+	}
+	if s.units[0][1] != saved.units[0][1] || s.hour != 15 || s.daysElapsed != 3 || s.score.MenLost != [2]int{12, 34} {
+		t.Errorf("Game not loaded, unit %v, hour %d, days elapsed %d, men lost %v", s.units[0][1], s.hour, s.daysElapsed, s.score.MenLost)
+	}
+	if *s.commanderFlags != *newCommanderFlags(&options) {
+		t.Errorf("Expected commander flags set by the options, got %v", *s.commanderFlags)
+	}
+	if len(s.flashback) != len(flashbackDays) {
+		t.Fatalf("Expected %d days of flashback, got %v", len(flashbackDays), s.flashback)
+	}
+	for i, day := range s.flashback {
+		if !reflect.DeepEqual(day.Units, flashbackDays[i]) || len(day.Events) != 0 {
+			t.Errorf("Expected units %v of day %d, got %v", flashbackDays[i], i, day)
+		}
+	}
+	if len(s.ai.dayEvents) != 0 {
+		t.Errorf("Expected no events of the day, got %v", s.ai.dayEvents)
+	}
+}
+
+func TestLoadInvalidSave(t *testing.T) {
+	saved := newTestGameState(DefaultOptions())
+	var game bytes.Buffer
+	if err := saved.Save(&game); err != nil {
+		t.Fatal(err)
+	}
+	unknownVersion := bytes.Clone(game.Bytes())
+	unknownVersion[len(saveMagic)] = saveVersion + 1
+	for name, data := range map[string][]byte{
+		"unknown version": unknownVersion,
+		"truncated":       game.Bytes()[:game.Len()/2],
+		"empty":           nil,
+		"zeros":           make([]byte, 3000),
+	} {
+		s := newTestGameState(DefaultOptions())
+		if err := s.Load(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	s := newTestGameState(DefaultOptions())
+	if err := s.Load(bytes.NewReader(game.Bytes())); err != nil {
+		t.Error("Cannot load saved game,", err)
+	}
+}
+
+func TestWriteViewHidesEnemyUnits(t *testing.T) {
+	options := DefaultOptions()
+	options.GermanCommander = Player
+	game := newTestGameState(options)
+	game.units[1][0].SeenByEnemy = true
+	hidden := &game.units[1][1]
+	hidden.XY, hidden.MenCount, hidden.Objective = UnitCoords{30, 14}, 77, UnitCoords{32, 14}
+	game.flashback = FlashbackHistory{{
+		Units: FlashbackUnits{
+			{XY: game.units[0][0].XY, Side: 0, Index: 0},
+			{XY: game.units[1][0].XY, Side: 1, Index: 0},
+			{XY: hidden.XY, Side: 1, Index: 1}},
+		Events: FlashbackEvents{
+			{Type: FlashbackBattle, Side: 1, XY: game.units[0][0].XY},
+			{Type: FlashbackReinforcement, Side: 1, XY: hidden.XY}}}}
+	game.ai.dayEvents = FlashbackEvents{{Type: FlashbackSurrender, Side: 1, XY: hidden.XY}}
+
+	var view bytes.Buffer
+	if err := game.WriteView(&view, 0); err != nil {
+		t.Fatal(err)
+	}
+	client := newTestGameState(options)
+	if err := client.ReadView(bytes.NewReader(view.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if client.units[1][0] != game.units[1][0] || client.units[0][1] != game.units[0][1] {
+		t.Errorf("Expected visible units to be written, got %v, %v", client.units[1][0], client.units[0][1])
+	}
+	if unit := client.units[1][1]; unit.IsInGame || unit.XY != (UnitCoords{}) || unit.MenCount != 0 || unit.Objective != (UnitCoords{}) || unit.Index != 1 {
+		t.Errorf("Expected a placeholder of the hidden unit, got %v", unit)
+	}
+	if len(client.flashback) != 1 {
+		t.Fatalf("Expected one day of flashback, got %v", client.flashback)
+	}
+	for _, unit := range client.flashback[0].Units {
+		if unit.Side == 1 && unit.Index == 1 {
+			t.Errorf("Hidden unit in the flashback %v", client.flashback[0].Units)
+		}
+	}
+	if len(client.flashback[0].Units) != 2 {
+		t.Errorf("Expected the visible units in the flashback, got %v", client.flashback[0].Units)
+	}
+	if events := client.flashback[0].Events; len(events) != 1 || events[0].Type != FlashbackBattle {
+		t.Errorf("Expected only the attack on own unit in the flashback, got %v", events)
+	}
+	if len(client.ai.dayEvents) != 0 {
+		t.Errorf("Expected no events of hidden units, got %v", client.ai.dayEvents)
+	}
+	if bytes.Contains(view.Bytes(), []byte{byte(hidden.XY.X), byte(hidden.XY.Y), byte(hidden.MenCount)}) {
+		t.Error("Position and strength of the hidden unit written in the view")
+	}
+}
@@ -1,6 +1,8 @@
 package lib
 
 import (
+	"bytes"
+	"math/rand"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -32,3 +34,57 @@ func readTestData(filename string, scenario int) (*GameData, *ScenarioData, erro
 	}
 	return gameData, scenarioData, nil
 }
+
+// newTestGameState returns a small game, made without the disk images of the games,
+// which can be saved, loaded and rewound.
+func newTestGameState(options Options) *GameState {
+	var units Units
+	for side := range units {
+		for i := 0; i < 64; i++ {
+			unit := Unit{Side: side, Index: i, Morale: 40}
+			if i < 3 {
+				unit.IsInGame = true
+				unit.XY = UnitCoords{10 + 2*i, 4 + 6*side}
+				unit.MenCount, unit.TankCount = 30+i, 5
+				unit.SupplyLevel = 100
+				unit.Order = Defend
+			}
+			units[side] = append(units[side], unit)
+		}
+	}
+	generals := &Generals{{{}}, {{}}}
+	// Normalize the units, so that they are equal to units read from a saved game.
+	var unitsData bytes.Buffer
+	if err := units.Write(&unitsData); err != nil {
+		panic(err)
+	}
+	parsedUnits, err := ParseUnits(&unitsData, nil, [2][]string{}, generals)
+	if err != nil {
+		panic(err)
+	}
+	randSource := NewRandSource(1)
+	commanderFlags := newCommanderFlags(&options)
+	data := &Data{MinutesPerTick: 30}
+	s := &GameState{
+		randSource:                       randSource,
+		rand:                             rand.New(randSource),
+		hour:                             6,
+		day:                              14,
+		month:                            10,
+		year:                             1941,
+		daysElapsed:                      1,
+		supplyLevels:                     [2]int{100, 120},
+		commanderFlags:                   commanderFlags,
+		scenarioData:                     data,
+		terrain:                          &Terrain{Cities: Cities{{Owner: 0, VictoryPoints: 5}, {Owner: 1, VictoryPoints: 10}}},
+		terrainTypes:                     &TerrainTypeMap{units: make([]bool, 4)},
+		units:                            parsedUnits,
+		generals:                         generals,
+		options:                          &options,
+		sync:                             NewMessageSync(),
+		allUnitsHidden:                   true,
+		numUnitsToUpdatePerTimeIncrement: 4}
+	s.score = newScore(Crusade, Variant{}, data, commanderFlags, &options)
+	s.ai = &AI{rand: s.rand, units: s.units, options: &options, commanderFlags: commanderFlags, score: s.score, scenarioData: data, terrain: s.terrain}
+	return s
+}
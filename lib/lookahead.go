@@ -0,0 +1,92 @@
+package lib
+
+import (
+	"math"
+	"sync"
+)
+
+// Number of hours of the game played out by the lookahead computer commander
+// (it also chooses its plans every lookaheadHours hours).
+const lookaheadHours = 6
+
+// Number of random continuations of the game played out for every plan.
+const lookaheadContinuations = 3
+
+// Plans considered by the lookahead computer commander, in order of preference if they go equally well.
+var lookaheadPlans = [...]AILevel{OriginalAI, CautiousAI, AggressiveAI}
+
+// planLookahead chooses plans for sides commanded by the lookahead computer commander.
+func (s *GameState) planLookahead() {
+	for side := 0; side < 2; side++ {
+		if s.commanderFlags.PlayerControlled[side] || s.options.AILevel(side) != LookaheadAI {
+			continue
+		}
+		s.ai.plannedLevels[side] = s.bestPlan(side)
+	}
+}
+
+// bestPlan plays out the game with every plan and returns the one after which the side is best off.
+// The game waits for the result, so all continuations are played out at the same time
+// on separate goroutines.
+func (s *GameState) bestPlan(side int) AILevel {
+	// Seeds are drawn up front, so that the result doesn't depend on the order in which
+	// continuations finish.
+	var seeds [len(lookaheadPlans)][lookaheadContinuations]int64
+	for i := range seeds {
+		for j := range seeds[i] {
+			seeds[i][j] = s.rand.Int63()
+		}
+	}
+	var balances [len(lookaheadPlans)][lookaheadContinuations]int
+	var wg sync.WaitGroup
+	for i, plan := range lookaheadPlans {
+		for j, seed := range seeds[i] {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				balances[i][j] = s.playOut(side, plan, seed)
+			}()
+		}
+	}
+	wg.Wait()
+	planBalances := make(map[AILevel]int)
+	for i, plan := range lookaheadPlans {
+		for _, balance := range balances[i] {
+			planBalances[plan] += balance
+		}
+	}
+	return choosePlan(func(plan AILevel) int { return planBalances[plan] })
+}
+
+// choosePlan returns the plan with the highest balance, or the earliest of lookaheadPlans
+// among the ones with the same balance.
+func choosePlan(planBalance func(plan AILevel) int) AILevel {
+	bestPlan, bestBalance := lookaheadPlans[0], math.MinInt
+	for _, plan := range lookaheadPlans {
+		if balance := planBalance(plan); balance > bestBalance {
+			bestPlan, bestBalance = plan, balance
+		}
+	}
+	return bestPlan
+}
+
+// playOut plays a copy of the game for lookaheadHours hours with the side following the plan
+// and returns the balance of the side at the end (negative if the enemy is ahead).
+// The copy doesn't modify the game, so many continuations can be played out at once.
+// A few hours of play rarely change who's winning, let alone the clamped advantage,
+// so plans are compared by the balance of the scores behind it.
+func (s *GameState) playOut(side int, plan AILevel, seed int64) int {
+	game := s.clone(NewRandSource(seed), newDiscardingMessageSync(), true)
+	// Both sides are played by the classic computer commander.
+	game.commanderFlags.PlayerControlled = [2]bool{false, false}
+	for i := 0; i < 2; i++ {
+		game.options.setAILevel(i, s.ai.level(i))
+	}
+	game.options.setAILevel(side, plan)
+	for hours := 0; hours < lookaheadHours && game.Update(); {
+		if game.minute == 0 {
+			hours++
+		}
+	}
+	return game.score.Balance(side)
+}
@@ -0,0 +1,80 @@
+package lib
+
+import "testing"
+
+func TestLookaheadLevel(t *testing.T) {
+	options := Options{AlliedAILevel: LookaheadAI, GermanAILevel: CautiousAI}
+	ai := &AI{options: &options}
+	ai.plannedLevels = [2]AILevel{AggressiveAI, AggressiveAI}
+	if ai.level(0) != AggressiveAI || ai.level(1) != CautiousAI {
+		t.Errorf("Unexpected levels %v %v", ai.level(0), ai.level(1))
+	}
+}
+
+func TestChoosePlan(t *testing.T) {
+	// Both outcomes are a clamped, maximal advantage of side 0, but the aggressive
+	// plan gets there with fewer losses.
+	outcomes := map[AILevel]Score{
+		OriginalAI:   {rules: VictoryRules{Score: &ScoreWeights{Losses: [2]int{8, 8}}}, MenLost: [2]int{1, 60}},
+		CautiousAI:   {rules: VictoryRules{Score: &ScoreWeights{Losses: [2]int{8, 8}}}, MenLost: [2]int{2, 60}},
+		AggressiveAI: {rules: VictoryRules{Score: &ScoreWeights{Losses: [2]int{8, 8}}}, MenLost: [2]int{0, 60}}}
+	for plan, score := range outcomes {
+		if winningSide, advantage := score.WinningSideAndAdvantage(); winningSide != 0 || advantage != 4 {
+			t.Fatalf("Expected maximal advantage of side 0 after %v, got %d, %d", plan, winningSide, advantage)
+		}
+	}
+	if plan := choosePlan(func(plan AILevel) int { return outcomes[plan].Balance(0) }); plan != AggressiveAI {
+		t.Errorf("Expected the plan with fewest losses to be chosen, got %v", plan)
+	}
+	if plan := choosePlan(func(plan AILevel) int { return outcomes[plan].Balance(1) }); plan != CautiousAI {
+		t.Errorf("Expected the plan with most enemy losses to be chosen for side 1, got %v", plan)
+	}
+	if plan := choosePlan(func(AILevel) int { return 0 }); plan != OriginalAI {
+		t.Errorf("Expected the original plan to be chosen when plans go equally well, got %v", plan)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	score := Score{
+		rules:      VictoryRules{Score: &ScoreWeights{Losses: [2]int{8, 8}, Cities: [2]int{1, 1}}},
+		MenLost:    [2]int{3, 5},
+		CitiesHeld: [2]int{2, 1}}
+	// (1+5)*8 + 2*8 - ((1+3)*8 + 1*8)
+	if balance := score.Balance(0); balance != 24 {
+		t.Errorf("Expected balance 24, got %d", balance)
+	}
+	if balance := score.Balance(1); balance != -24 {
+		t.Errorf("Expected balance -24, got %d", balance)
+	}
+	score.suddenDeath, score.suddenDeathWinner = true, 1
+	if score.Balance(1) <= 0 || score.Balance(0) >= 0 {
+		t.Errorf("Expected sudden-death winner to be ahead, got %d, %d", score.Balance(0), score.Balance(1))
+	}
+}
+
+func TestSimulationKeepsNoHistory(t *testing.T) {
+	s := newTestGameState(DefaultOptions())
+	s.flashback = FlashbackHistory{{Units: FlashbackUnits{{XY: UnitCoords{10, 4}}}}}
+	s.takeSnapshot()
+	script, err := ParseScenarioScript("test.star", []byte("def every_hour(game):\n    pass\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetScript(script)
+
+	game := s.clone(NewRandSource(1), newDiscardingMessageSync(), true)
+	if len(game.flashback) != 0 || len(game.snapshots) != 0 || game.script != nil {
+		t.Errorf("Expected a simulation without history and script, got %d flashback days, %d snapshots, script %v",
+			len(game.flashback), len(game.snapshots), game.script)
+	}
+	game.takeSnapshot()
+	if len(game.snapshots) != 0 {
+		t.Error("Expected a simulation not to take snapshots")
+	}
+	if len(s.flashback) != 1 || len(s.snapshots) != 1 || s.script == nil {
+		t.Error("Expected the game to keep its history and script")
+	}
+	if copied := s.Clone(); len(copied.flashback) != 1 || len(copied.snapshots) != 1 {
+		t.Error("Expected a regular copy to keep the history")
+	}
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EncodedMessage is a representation of an update sent by the game, which
+// (unlike the messages from units) can be serialized with encoding/gob or encoding/json.
+type EncodedMessage struct {
+	Type          string
+	Unit, Enemy   Unit
+	Outcome       int
+	City          City
+	XY            UnitCoords
+	XY0, XY1      MapCoords
+	Side          int
+	Sides         [2]bool
+	Text          string
+	Weather       int
+	Forecast      []int
+	DaysRemaining int
+	SupplyLevels  [2]int
+}
+
+// EncodeMessage converts an update sent by the game to its serializable form.
+// A nil update is encoded as a message with an empty type.
+func EncodeMessage(message interface{}) (EncodedMessage, error) {
+	switch message := message.(type) {
+	case nil:
+		return EncodedMessage{}, nil
+	case WeAreAttacking:
+		return EncodedMessage{Type: "WeAreAttacking", Unit: message.unit, Enemy: message.enemy, Outcome: message.outcome}, nil
+	case WeHaveMetStrongResistance:
+		return EncodedMessage{Type: "WeHaveMetStrongResistance", Unit: message.unit}, nil
+	case WeMustSurrender:
+		return EncodedMessage{Type: "WeMustSurrender", Unit: message.unit}, nil
+	case WeAreInContactWithEnemy:
+		return EncodedMessage{Type: "WeAreInContactWithEnemy", Unit: message.unit}, nil
+	case WeHaveCaptured:
+		return EncodedMessage{Type: "WeHaveCaptured", Unit: message.unit, City: message.city}, nil
+	case WeHaveReachedOurObjective:
+		return EncodedMessage{Type: "WeHaveReachedOurObjective", Unit: message.unit}, nil
+	case WeHaveExhaustedSupplies:
+		return EncodedMessage{Type: "WeHaveExhaustedSupplies", Unit: message.unit}, nil
+	case WeAreRetreating:
+		return EncodedMessage{Type: "WeAreRetreating", Unit: message.unit}, nil
+	case WeHaveBeenOverrun:
+		return EncodedMessage{Type: "WeHaveBeenOverrun", Unit: message.unit}, nil
+	case WeAreUnderFire:
+		return EncodedMessage{Type: "WeAreUnderFire", Unit: message.unit}, nil
+	case Initialized:
+		return EncodedMessage{Type: "Initialized"}, nil
+	case Reinforcements:
+		return EncodedMessage{Type: "Reinforcements", Sides: message.Sides}, nil
+	case GameOver:
+		return EncodedMessage{Type: "GameOver", Text: message.Results}, nil
+	case UnitAttack:
+		return EncodedMessage{Type: "UnitAttack", XY: message.XY, Outcome: message.Outcome}, nil
+	case UnitMove:
+		return EncodedMessage{Type: "UnitMove", Unit: message.Unit, XY0: message.XY0, XY1: message.XY1}, nil
+	case SupplyTruckMove:
+		return EncodedMessage{Type: "SupplyTruckMove", Side: message.Side, XY0: message.XY0, XY1: message.XY1}, nil
+	case WeatherForecast:
+		return EncodedMessage{Type: "WeatherForecast", Weather: message.Weather, Forecast: message.Forecast}, nil
+	case SupplyDistributionStart:
+		return EncodedMessage{Type: "SupplyDistributionStart"}, nil
+	case SupplyDistributionEnd:
+		return EncodedMessage{Type: "SupplyDistributionEnd"}, nil
+	case DailyUpdate:
+		return EncodedMessage{Type: "DailyUpdate", DaysRemaining: message.DaysRemaining, SupplyLevels: message.SupplyLevels}, nil
+	case TimeChanged:
+		return EncodedMessage{Type: "TimeChanged"}, nil
+	case ScriptMessage:
+		return EncodedMessage{Type: "ScriptMessage", Text: message.Text}, nil
+	case ScriptError:
+		return EncodedMessage{Type: "ScriptError", Text: message.Err.Error()}, nil
+	}
+	return EncodedMessage{}, fmt.Errorf("cannot encode message %v", message)
+}
+
+// Decode converts the message back to an update, as sent by the game.
+// Scenario data is used to fill in the names of formations.
+func (m EncodedMessage) Decode(data *Data) (interface{}, error) {
+	switch m.Type {
+	case "":
+		return nil, nil
+	case "WeAreAttacking":
+		return WeAreAttacking{m.Unit, m.Enemy, m.Outcome, data.Formations}, nil
+	case "WeHaveMetStrongResistance":
+		return WeHaveMetStrongResistance{m.Unit}, nil
+	case "WeMustSurrender":
+		return WeMustSurrender{m.Unit}, nil
+	case "WeAreInContactWithEnemy":
+		return WeAreInContactWithEnemy{m.Unit}, nil
+	case "WeHaveCaptured":
+		return WeHaveCaptured{m.Unit, m.City}, nil
+	case "WeHaveReachedOurObjective":
+		return WeHaveReachedOurObjective{m.Unit}, nil
+	case "WeHaveExhaustedSupplies":
+		return WeHaveExhaustedSupplies{m.Unit}, nil
+	case "WeAreRetreating":
+		return WeAreRetreating{m.Unit}, nil
+	case "WeHaveBeenOverrun":
+		return WeHaveBeenOverrun{m.Unit}, nil
+	case "WeAreUnderFire":
+		return WeAreUnderFire{m.Unit}, nil
+	case "Initialized":
+		return Initialized{}, nil
+	case "Reinforcements":
+		return Reinforcements{Sides: m.Sides}, nil
+	case "GameOver":
+		return GameOver{Results: m.Text}, nil
+	case "UnitAttack":
+		return UnitAttack{XY: m.XY, Outcome: m.Outcome}, nil
+	case "UnitMove":
+		return UnitMove{Unit: m.Unit, XY0: m.XY0, XY1: m.XY1}, nil
+	case "SupplyTruckMove":
+		return SupplyTruckMove{Side: m.Side, XY0: m.XY0, XY1: m.XY1}, nil
+	case "WeatherForecast":
+		return WeatherForecast{Weather: m.Weather, Forecast: m.Forecast}, nil
+	case "SupplyDistributionStart":
+		return SupplyDistributionStart{}, nil
+	case "SupplyDistributionEnd":
+		return SupplyDistributionEnd{}, nil
+	case "DailyUpdate":
+		return DailyUpdate{DaysRemaining: m.DaysRemaining, SupplyLevels: m.SupplyLevels}, nil
+	case "TimeChanged":
+		return TimeChanged{}, nil
+	case "ScriptMessage":
+		return ScriptMessage{Text: m.Text}, nil
+	case "ScriptError":
+		return ScriptError{Err: errors.New(m.Text)}, nil
+	}
+	return nil, fmt.Errorf("unknown message type %s", m.Type)
+}
@@ -3,6 +3,8 @@ package lib
 type MessageSync struct {
 	update chan interface{}
 	cont   chan bool
+	// If set, updates are dropped instead of being passed to the receiver.
+	discard bool
 }
 
 func NewMessageSync() *MessageSync {
@@ -11,7 +13,16 @@ func NewMessageSync() *MessageSync {
 		cont:   make(chan bool)}
 }
 
+// newDiscardingMessageSync returns a sync letting the game run without a receiver of updates
+// (e.g. when simulating the game).
+func newDiscardingMessageSync() *MessageSync {
+	return &MessageSync{discard: true}
+}
+
 func (s *MessageSync) SendUpdate(msg interface{}) bool {
+	if s.discard {
+		return true
+	}
 	s.update <- msg
 	return <-s.cont
 }
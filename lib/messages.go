@@ -133,10 +133,16 @@ type UnitMove struct {
 }
 
 type SupplyTruckMove struct {
+	// Side whose supplies are transported.
+	Side     int
 	XY0, XY1 MapCoords
 }
 
-type WeatherForecast struct{ Weather int }
+type WeatherForecast struct {
+	Weather int
+	// Weather expected in the following days, nil if there is no forecast.
+	Forecast []int
+}
 
 type SupplyDistributionStart struct{}
 type SupplyDistributionEnd struct{}
@@ -146,3 +152,9 @@ type DailyUpdate struct {
 	SupplyLevels  [2]int
 }
 type TimeChanged struct{}
+
+// ScriptMessage is a message posted by a scenario script.
+type ScriptMessage struct{ Text string }
+
+// ScriptError is sent when a scenario script fails. The script is disabled afterwards.
+type ScriptError struct{ Err error }
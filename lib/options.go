@@ -1,7 +1,6 @@
 package lib
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io"
 )
@@ -44,6 +43,37 @@ func (c Commander) Other() Commander {
 var Player = Commander{0}
 var Computer = Commander{1}
 
+// AILevel selects how the computer commander plays.
+type AILevel int
+
+func (l AILevel) String() string {
+	switch l {
+	case OriginalAI:
+		return "ORIGINAL"
+	case CautiousAI:
+		return "CAUTIOUS"
+	case AggressiveAI:
+		return "AGGRESSIVE"
+	case LookaheadAI:
+		return "LOOKAHEAD"
+	}
+	panic(fmt.Errorf("unknown computer commander level: %d", int(l)))
+}
+func (l AILevel) Next() AILevel {
+	return (l + 1) % 4
+}
+
+const (
+	// Computer commander as in the original games.
+	OriginalAI AILevel = 0
+	// Units return to their supply units earlier and don't advance without a supply line.
+	CautiousAI AILevel = 1
+	// Units in the rear concentrate where the enemy troops are, rather than spread out.
+	AggressiveAI AILevel = 2
+	// Every few hours plays out the game with each of the levels above and chooses the one which goes best.
+	LookaheadAI AILevel = 3
+)
+
 type UnitDisplay int
 
 func (u UnitDisplay) String() string {
@@ -110,9 +140,28 @@ type Options struct {
 	AlliedCommander Commander // [0..1]
 	GermanCommander Commander // [0..1]
 	Intelligence    Intelligence
-	UnitDisplay     UnitDisplay // [0..1]
-	GameBalance     int         // [0..4]
-	Speed           Speed       // [1..3]
+	UnitDisplay     UnitDisplay  // [0..1]
+	GameBalance     int          // [0..4]
+	Speed           Speed        // [1..3]
+	Calendar        Calendar     // [0..1]
+	Weather         WeatherModel // [0..2]
+	AlliedAILevel   AILevel      // [0..3]
+	GermanAILevel   AILevel      // [0..3]
+}
+
+// AILevel returns the level of the computer commander of given side.
+func (o *Options) AILevel(side int) AILevel {
+	if side == 0 {
+		return o.AlliedAILevel
+	}
+	return o.GermanAILevel
+}
+func (o *Options) setAILevel(side int, level AILevel) {
+	if side == 0 {
+		o.AlliedAILevel = level
+	} else {
+		o.GermanAILevel = level
+	}
 }
 
 func DefaultOptions() Options {
@@ -122,57 +171,92 @@ func DefaultOptions() Options {
 		Intelligence:    Limited,
 		UnitDisplay:     ShowAsSymbols,
 		GameBalance:     2,
-		Speed:           Medium}
+		Speed:           Medium,
+		Calendar:        OriginalCalendar,
+		Weather:         OriginalWeather}
 }
 
+// Options are written after a marker and a format version. Options written before
+// they were versioned start with the allied commander (0 or 1) instead of the marker,
+// they are read as version 0, which had no calendar, weather and computer commander levels.
+const (
+	optionsMarker  = 0xFF
+	optionsVersion = 1
+)
+
 func (o Options) Write(writer io.Writer) error {
-	if err := binary.Write(writer, binary.LittleEndian, uint8(o.AlliedCommander.Int())); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.LittleEndian, uint8(o.GermanCommander.Int())); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.LittleEndian, uint8(o.Intelligence.Int())); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.LittleEndian, uint8(o.UnitDisplay)); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.LittleEndian, uint8(o.GameBalance)); err != nil {
-		return err
-	}
-	if err := binary.Write(writer, binary.LittleEndian, uint8(o.Speed)); err != nil {
-		return err
-	}
-	return nil
+	data := []uint8{
+		optionsMarker,
+		optionsVersion,
+		uint8(o.AlliedCommander.Int()),
+		uint8(o.GermanCommander.Int()),
+		uint8(o.Intelligence.Int()),
+		uint8(o.UnitDisplay),
+		uint8(o.GameBalance),
+		uint8(o.Speed),
+		uint8(o.Calendar),
+		uint8(o.Weather),
+		uint8(o.AlliedAILevel),
+		uint8(o.GermanAILevel)}
+	_, err := writer.Write(data)
+	return err
 }
 
+// Names and ranges of values of the options, in the order they are written.
+var optionRanges = [10]struct {
+	name     string
+	min, max uint8
+}{
+	{"allied commander", 0, 1},
+	{"german commander", 0, 1},
+	{"intelligence", 0, 1},
+	{"unit display", 0, 1},
+	{"game balance", 0, 4},
+	{"speed", 1, 3},
+	{"calendar", 0, 1},
+	{"weather model", 0, 2},
+	{"allied computer commander level", 0, 3},
+	{"german computer commander level", 0, 3}}
+
 func (o *Options) Read(reader io.Reader) error {
-	var alliedCommander, germanCommander, intelligence, unitDisplay, gameBalance, speed uint8
-	if err := binary.Read(reader, binary.LittleEndian, &alliedCommander); err != nil {
-		return err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &germanCommander); err != nil {
-		return err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &intelligence); err != nil {
+	var header [1]uint8
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
 		return err
 	}
-	if err := binary.Read(reader, binary.LittleEndian, &unitDisplay); err != nil {
-		return err
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &gameBalance); err != nil {
-		return err
+	// Values of the options, those missing in older versions are left zero (the defaults).
+	var data [10]uint8
+	if header[0] == optionsMarker {
+		var version [1]uint8
+		if _, err := io.ReadFull(reader, version[:]); err != nil {
+			return err
+		}
+		if version[0] != optionsVersion {
+			return fmt.Errorf("unsupported options version %d", version[0])
+		}
+		if _, err := io.ReadFull(reader, data[:]); err != nil {
+			return err
+		}
+	} else {
+		data[0] = header[0]
+		if _, err := io.ReadFull(reader, data[1:6]); err != nil {
+			return err
+		}
 	}
-	if err := binary.Read(reader, binary.LittleEndian, &speed); err != nil {
-		return err
+	for i, value := range data {
+		if value < optionRanges[i].min || value > optionRanges[i].max {
+			return fmt.Errorf("invalid %s %d", optionRanges[i].name, value)
+		}
 	}
-	o.AlliedCommander = Commander{int(alliedCommander)}
-	o.GermanCommander = Commander{int(germanCommander)}
-	o.Intelligence = Intelligence{int(intelligence)}
-	o.UnitDisplay = UnitDisplay(unitDisplay)
-	o.GameBalance = int(gameBalance)
-	o.Speed = Speed(speed)
+	o.AlliedCommander = Commander{int(data[0])}
+	o.GermanCommander = Commander{int(data[1])}
+	o.Intelligence = Intelligence{int(data[2])}
+	o.UnitDisplay = UnitDisplay(data[3])
+	o.GameBalance = int(data[4])
+	o.Speed = Speed(data[5])
+	o.Calendar = Calendar(data[6])
+	o.Weather = WeatherModel(data[7])
+	o.AlliedAILevel = AILevel(data[8])
+	o.GermanAILevel = AILevel(data[9])
 	return nil
 }
 
@@ -207,30 +291,30 @@ func (c *CommanderFlags) SwitchSides() {
 
 func (c *CommanderFlags) Serialize() (result uint8) {
 	if !c.PlayerControlled[0] {
-		result |= 0b1
+		result |= 1 << 0
 	}
 	if !c.PlayerControlled[1] {
-		result |= 0b01
+		result |= 1 << 1
 	}
 	if !c.PlayerCanSeeUnits[0] {
-		result |= 0b001
+		result |= 1 << 2
 	}
 	if !c.PlayerCanSeeUnits[1] {
-		result |= 0b0001
+		result |= 1 << 3
 	}
 	if !c.PlayerHasIntelligence[0] {
-		result |= 0b00001
+		result |= 1 << 4
 	}
 	if !c.PlayerHasIntelligence[1] {
-		result |= 0b000001
+		result |= 1 << 5
 	}
 	return
 }
 func (c *CommanderFlags) Deserialize(value uint8) {
-	c.PlayerControlled[0] = (value & 0b1) == 0
-	c.PlayerControlled[1] = (value & 0b01) == 0
-	c.PlayerCanSeeUnits[0] = (value & 0b001) == 0
-	c.PlayerCanSeeUnits[1] = (value & 0b0001) == 0
-	c.PlayerHasIntelligence[0] = (value & 0b00001) == 0
-	c.PlayerHasIntelligence[1] = (value & 0b000001) == 0
+	c.PlayerControlled[0] = value&(1<<0) == 0
+	c.PlayerControlled[1] = value&(1<<1) == 0
+	c.PlayerCanSeeUnits[0] = value&(1<<2) == 0
+	c.PlayerCanSeeUnits[1] = value&(1<<3) == 0
+	c.PlayerHasIntelligence[0] = value&(1<<4) == 0
+	c.PlayerHasIntelligence[1] = value&(1<<5) == 0
 }
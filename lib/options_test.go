@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOptionsWriteRead(t *testing.T) {
+	options := DefaultOptions()
+	options.GermanCommander = Player
+	options.Weather = RegionalWeather
+	options.AlliedAILevel = CautiousAI
+	options.GermanAILevel = AggressiveAI
+	var buf bytes.Buffer
+	if err := options.Write(&buf); err != nil {
+		t.Fatal("Cannot write options,", err)
+	}
+	var read Options
+	if err := read.Read(&buf); err != nil {
+		t.Fatal("Cannot read options,", err)
+	}
+	if read != options {
+		t.Errorf("Expected %v, got %v", options, read)
+	}
+	if read.AILevel(0) != CautiousAI || read.AILevel(1) != AggressiveAI {
+		t.Errorf("Unexpected computer commander levels %v %v", read.AILevel(0), read.AILevel(1))
+	}
+}
+
+func TestCommanderFlagsSerialize(t *testing.T) {
+	for value := uint8(0); value < 64; value++ {
+		var flags CommanderFlags
+		flags.Deserialize(value)
+		if serialized := flags.Serialize(); serialized != value {
+			t.Errorf("Expected %06b, got %06b for flags %v", value, serialized, flags)
+		}
+	}
+}
+
+func TestReadOptionsVersion0(t *testing.T) {
+	// Options written before they were versioned: player vs. computer, limited intelligence,
+	// icons, fair balance, slow speed.
+	var options Options
+	if err := options.Read(bytes.NewReader([]byte{0, 1, 1, 1, 2, 3})); err != nil {
+		t.Fatal("Cannot read options,", err)
+	}
+	expected := Options{
+		AlliedCommander: Player,
+		GermanCommander: Computer,
+		Intelligence:    Limited,
+		UnitDisplay:     ShowAsIcons,
+		GameBalance:     2,
+		Speed:           Slow}
+	if options != expected {
+		t.Errorf("Expected %v, got %v", expected, options)
+	}
+}
+
+func TestReadInvalidOptions(t *testing.T) {
+	var valid bytes.Buffer
+	if err := DefaultOptions().Write(&valid); err != nil {
+		t.Fatal(err)
+	}
+	// Every option set to a value out of its range.
+	for i := 2; i < valid.Len(); i++ {
+		data := bytes.Clone(valid.Bytes())
+		data[i] = 0xF0
+		var options Options
+		if err := options.Read(bytes.NewReader(data)); err == nil {
+			t.Errorf("Expected an error for invalid value of option %d", i-2)
+		}
+	}
+	data := bytes.Clone(valid.Bytes())
+	data[1] = optionsVersion + 1
+	var options Options
+	if err := options.Read(bytes.NewReader(data)); err == nil {
+		t.Error("Expected an error for unsupported version")
+	}
+	if err := options.Read(bytes.NewReader(valid.Bytes()[:5])); err == nil {
+		t.Error("Expected an error for truncated options")
+	}
+}
@@ -0,0 +1,47 @@
+package lib
+
+import "fmt"
+
+// UnitOrder is an order given by a player to one of their units.
+type UnitOrder struct {
+	Side, Index int
+	Order       OrderType
+	// If true, only the objective of the unit is changed and Order is ignored.
+	SetObjective bool
+	Objective    UnitCoords
+}
+
+// ApplyOrder changes orders of a unit and returns the unit after the change.
+func (u *Units) ApplyOrder(order UnitOrder) (Unit, error) {
+	if order.Side < 0 || order.Side > 1 || order.Index < 0 || order.Index >= len(u[order.Side]) {
+		return Unit{}, fmt.Errorf("invalid unit %d of side %d", order.Index, order.Side)
+	}
+	unit := u[order.Side][order.Index]
+	if !unit.IsInGame {
+		return Unit{}, fmt.Errorf("unit %s is not in game", unit.FullName())
+	}
+	if order.SetObjective {
+		unit.Objective = order.Objective
+	} else {
+		switch order.Order {
+		case Reserve, Attack:
+			unit.Objective.X = 0
+		case Defend:
+			unit.Objective = unit.XY
+		case Move:
+		default:
+			return Unit{}, fmt.Errorf("invalid order %v", order.Order)
+		}
+		unit.Order = order.Order
+	}
+	unit.HasLocalCommand = false
+	u[order.Side][order.Index] = unit
+	return unit, nil
+}
+
+// ApplyOrder changes orders of a unit. It must be called only when the game is
+// waiting for the UI to process an update.
+func (s *GameState) ApplyOrder(order UnitOrder) error {
+	_, err := s.units.ApplyOrder(order)
+	return err
+}
@@ -0,0 +1,77 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+)
+
+func TestApplyOrder(t *testing.T) {
+	var units Units
+	units[1] = []Unit{
+		{Side: 1, Index: 0, IsInGame: true, XY: UnitCoords{10, 20}, Objective: UnitCoords{30, 40}, HasLocalCommand: true},
+		{Side: 1, Index: 1}}
+	unit, err := units.ApplyOrder(UnitOrder{Side: 1, Index: 0, Order: Defend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Order != Defend || unit.Objective != unit.XY || unit.HasLocalCommand || units[1][0] != unit {
+		t.Errorf("Unexpected unit after the order %v", unit)
+	}
+	unit, err = units.ApplyOrder(UnitOrder{Side: 1, Index: 0, SetObjective: true, Objective: UnitCoords{12, 14}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unit.Order != Defend || unit.Objective != (UnitCoords{12, 14}) {
+		t.Errorf("Unexpected unit after setting the objective %v", unit)
+	}
+	if _, err := units.ApplyOrder(UnitOrder{Side: 1, Index: 1, Order: Attack}); err == nil {
+		t.Error("Expected an error for a unit which is not in game")
+	}
+	if _, err := units.ApplyOrder(UnitOrder{Side: 0, Index: 0, Order: Attack}); err == nil {
+		t.Error("Expected an error for a non-existent unit")
+	}
+	if _, err := units.ApplyOrder(UnitOrder{Side: 1, Index: 0, Order: OrderType(7)}); err == nil {
+		t.Error("Expected an error for an invalid order")
+	}
+}
+
+func TestEncodeDecodeMessages(t *testing.T) {
+	data := &Data{Formations: []string{"MARCH", "ASSAULT"}}
+	unit := Unit{Side: 1, Index: 5, Name: "1ST", IsInGame: true, XY: UnitCoords{3, 4}, Formation: 1}
+	messages := []interface{}{
+		nil,
+		WeAreAttacking{unit, unit, 12, data.Formations},
+		WeHaveCaptured{unit, City{Name: "PARIS", VictoryPoints: 10}},
+		WeAreUnderFire{unit},
+		UnitMove{Unit: unit, XY0: MapCoords{1, 2}, XY1: MapCoords{2, 2}},
+		SupplyTruckMove{Side: 1, XY0: MapCoords{1, 2}, XY1: MapCoords{2, 2}},
+		WeatherForecast{Weather: 2, Forecast: []int{1, 2, 3}},
+		DailyUpdate{DaysRemaining: 4, SupplyLevels: [2]int{100, 200}},
+		TimeChanged{}}
+	for _, message := range messages {
+		encoded, err := EncodeMessage(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(encoded); err != nil {
+			t.Fatal(err)
+		}
+		var received EncodedMessage
+		if err := gob.NewDecoder(&buf).Decode(&received); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := received.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(message, decoded) {
+			t.Errorf("Expected %v, got %v", message, decoded)
+		}
+	}
+	if _, err := EncodeMessage(42); err == nil {
+		t.Error("Expected an error for an unknown message")
+	}
+}
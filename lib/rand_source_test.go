@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestRandSource(t *testing.T) {
+	for _, seed := range []int64{0, 1, -5, 1 << 40} {
+		expected := rand.New(rand.NewSource(seed))
+		source := NewRandSource(seed)
+		actual := rand.New(source)
+		for i := 0; i < 2000; i++ {
+			if e, a := expected.Int63(), actual.Int63(); e != a {
+				t.Fatalf("Seed %d, number %d: expected %d, got %d", seed, i, e, a)
+			}
+		}
+		copied := rand.New(source.Clone())
+		for i := 0; i < 100; i++ {
+			if e, a := actual.Intn(1000), copied.Intn(1000); e != a {
+				t.Fatalf("Seed %d, number %d: expected copy to return %d, got %d", seed, i, e, a)
+			}
+		}
+	}
+}
+
+func TestRandSourceWriteRead(t *testing.T) {
+	source := NewRandSource(7)
+	rnd := rand.New(source)
+	for i := 0; i < 1000; i++ {
+		rnd.Int63()
+	}
+	var buf bytes.Buffer
+	if err := source.Write(&buf); err != nil {
+		t.Fatal("Cannot write random source,", err)
+	}
+	read := NewRandSource(1)
+	if err := read.Read(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal("Cannot read random source,", err)
+	}
+	readRnd := rand.New(read)
+	for i := 0; i < 1000; i++ {
+		if e, a := rnd.Intn(1000), readRnd.Intn(1000); e != a {
+			t.Fatalf("Number %d: expected %d, got %d", i, e, a)
+		}
+	}
+
+	data := buf.Bytes()
+	data[0], data[1] = 0xff, 0xff
+	if err := read.Read(bytes.NewReader(data)); err == nil {
+		t.Error("Expected error reading invalid state")
+	}
+	if err := read.Read(bytes.NewReader(data[:10])); err == nil {
+		t.Error("Expected error reading truncated state")
+	}
+}
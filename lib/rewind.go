@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// snapshot is the game state saved at the end of a day.
+// The flashback is left out, so that snapshots don't grow with the length of the game.
+type snapshot struct {
+	daysElapsed int
+	// Number of days of the flashback history at the time of the snapshot.
+	flashbackDays int
+	game          []byte
+}
+
+// takeSnapshot saves the game state, so that the game can be rewound to it.
+// It must be called only where the game can be resumed from the saved state.
+func (s *GameState) takeSnapshot() {
+	if s.simulation {
+		return
+	}
+	var game bytes.Buffer
+	if err := s.save(&game, s.units, nil, s.ai.dayEvents, true); err != nil {
+		return
+	}
+	s.snapshots = append(s.snapshots, snapshot{
+		daysElapsed: s.daysElapsed, flashbackDays: len(s.flashback), game: game.Bytes()})
+}
+
+// CanRewindTo returns true if the game can be rewound to the end of the day
+// with given number of days elapsed since the beginning of the game.
+func (s *GameState) CanRewindTo(daysElapsed int) bool {
+	_, ok := s.findSnapshot(daysElapsed)
+	return ok
+}
+
+// RewindTo restores the game state from the end of the day with given number of days elapsed.
+// Later snapshots and days of the flashback are dropped, so the game continues from that day
+// as if the later days never happened.
+// The game must be paused after an update of a unit (in a state from which it can be resumed after Load).
+func (s *GameState) RewindTo(daysElapsed int) error {
+	i, ok := s.findSnapshot(daysElapsed)
+	if !ok {
+		return fmt.Errorf("no snapshot of day %d", daysElapsed)
+	}
+	flashback := s.flashback
+	if err := s.load(bytes.NewReader(s.snapshots[i].game), true); err != nil {
+		return err
+	}
+	s.flashback = flashback[:min(s.snapshots[i].flashbackDays, len(flashback))]
+	s.snapshots = s.snapshots[:i+1]
+	return nil
+}
+
+func (s *GameState) findSnapshot(daysElapsed int) (int, bool) {
+	for i, snapshot := range s.snapshots {
+		if snapshot.daysElapsed == daysElapsed {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanRewindTo(t *testing.T) {
+	s := &GameState{snapshots: []snapshot{{daysElapsed: 1}, {daysElapsed: 2}}}
+	if !s.CanRewindTo(1) || !s.CanRewindTo(2) {
+		t.Error("Expected to be able to rewind to days with snapshots")
+	}
+	if s.CanRewindTo(0) || s.CanRewindTo(3) {
+		t.Error("Unexpected snapshot")
+	}
+	if err := s.RewindTo(3); err == nil {
+		t.Error("Expected error rewinding to a day without snapshot")
+	}
+	if len(s.snapshots) != 2 {
+		t.Errorf("Snapshots modified by a failed rewind %v", s.snapshots)
+	}
+}
+
+func TestRewindRestoresGame(t *testing.T) {
+	s := newTestGameState(DefaultOptions())
+	units := [2][]Unit{append([]Unit(nil), s.units[0]...), append([]Unit(nil), s.units[1]...)}
+	commanderFlags := *s.commanderFlags
+	s.takeSnapshot()
+
+	s.units[0][0].XY = UnitCoords{20, 8}
+	s.units[1][1].MenCount = 3
+	s.hour, s.daysElapsed = 18, 2
+	s.score.MenLost[1] = 27
+	s.takeSnapshot()
+
+	if err := s.RewindTo(1); err != nil {
+		t.Fatal(err)
+	}
+	for side, sideUnits := range units {
+		for i, unit := range sideUnits {
+			if s.units[side][i] != unit {
+				t.Errorf("Expected unit %v, got %v", unit, s.units[side][i])
+			}
+		}
+	}
+	if s.hour != 6 || s.daysElapsed != 1 || s.score.MenLost[1] != 0 {
+		t.Errorf("Time or score not rewound, hour %d, days elapsed %d, men lost %d", s.hour, s.daysElapsed, s.score.MenLost[1])
+	}
+	if *s.commanderFlags != commanderFlags {
+		t.Errorf("Expected commander flags %v, got %v", commanderFlags, *s.commanderFlags)
+	}
+	if !s.commanderFlags.PlayerControlled[0] || s.commanderFlags.PlayerControlled[1] {
+		t.Error("Expected the first side to stay under the player's control")
+	}
+	if s.CanRewindTo(2) {
+		t.Error("Expected later snapshots to be dropped")
+	}
+}
+
+func TestSnapshotSizeDoesNotGrowWithDays(t *testing.T) {
+	s := newTestGameState(DefaultOptions())
+	for day := 0; day < 10; day++ {
+		s.flashback = append(s.flashback, FlashbackDay{Units: FlashbackUnits{
+			{XY: UnitCoords{10, 4}, Side: 0, Index: 0}, {XY: UnitCoords{10, 10}, Side: 1, Index: 0}}})
+		s.daysElapsed++
+		s.takeSnapshot()
+	}
+	for _, snapshot := range s.snapshots {
+		if len(snapshot.game) != len(s.snapshots[0].game) {
+			t.Fatalf("Expected snapshots of the same size, got %d bytes after %d days and %d bytes after %d days",
+				len(s.snapshots[0].game), s.snapshots[0].daysElapsed, len(snapshot.game), snapshot.daysElapsed)
+		}
+	}
+}
+
+func TestRewindTruncatesFlashback(t *testing.T) {
+	s := newTestGameState(DefaultOptions())
+	day := func(x int) FlashbackDay {
+		return FlashbackDay{Units: FlashbackUnits{{XY: UnitCoords{x, 4}}}}
+	}
+	s.flashback = FlashbackHistory{day(10)}
+	s.takeSnapshot()
+	s.flashback = append(s.flashback, day(11))
+	s.daysElapsed = 2
+	s.takeSnapshot()
+	s.flashback = append(s.flashback, day(12))
+	s.daysElapsed = 3
+	s.takeSnapshot()
+
+	if err := s.RewindTo(2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.flashback, FlashbackHistory{day(10), day(11)}) {
+		t.Errorf("Expected the flashback up to the day rewound to, got %v", s.flashback)
+	}
+}
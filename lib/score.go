@@ -1,16 +1,23 @@
 package lib
 
+import "math"
+
 type Score struct {
 	game           Game
 	variant        Variant
 	scenarioData   *Data
 	commanderFlags *CommanderFlags
 	options        *Options
+	rules          VictoryRules
 
 	MenLost                   [2]int // 29927 + side*2
 	TanksLost                 [2]int // 29927 + 4 + side*2
 	CitiesHeld                [2]int // 29927 + 13 + side*2
 	CriticalLocationsCaptured [2]int // 29927 + 21 + side*2
+
+	// Set when the game ended because one of the sudden-death conditions was met.
+	suddenDeath       bool
+	suddenDeathWinner int
 }
 
 func newScore(game Game, variant Variant, scenarioData *Data, commanderFlags *CommanderFlags, options *Options) *Score {
@@ -20,19 +27,15 @@ func newScore(game Game, variant Variant, scenarioData *Data, commanderFlags *Co
 		scenarioData:   scenarioData,
 		commanderFlags: commanderFlags,
 		options:        options,
+		rules:          DefaultVictoryRules(),
 		CitiesHeld:     variant.CitiesHeld}
 }
 
 func (s Score) WinningSideAndAdvantage() (winningSide int, advantage int) {
-	side0Score := (1 + s.MenLost[1] + s.TanksLost[1]) * s.variant.Data3 / 8
-	side1Score := 1 + s.MenLost[0] + s.TanksLost[0]
-	if s.game != Conflict {
-		side0Score += s.CitiesHeld[0] * 3
-		side1Score += s.CitiesHeld[1] * 3
-	} else {
-		side0Score += s.CitiesHeld[0] * 6 / (s.scenarioData.Data174 + 1)
-		side1Score += s.CitiesHeld[1] * 6 / (s.scenarioData.Data174 + 1)
+	if s.suddenDeath {
+		return s.suddenDeathWinner, 4
 	}
+	side0Score, side1Score := s.sideScores(1)
 	var score int
 	if side0Score < side1Score {
 		score = side1Score * 3 / side0Score
@@ -48,6 +51,46 @@ func (s Score) WinningSideAndAdvantage() (winningSide int, advantage int) {
 	return
 }
 
+// Balance returns how far the side is ahead of the enemy (negative if it's behind).
+// Unlike the advantage it's neither rounded nor clamped, so it tells apart
+// positions which differ by a few men or a single city.
+func (s Score) Balance(side int) int {
+	var balance int
+	if s.suddenDeath {
+		balance = math.MaxInt32
+		if s.suddenDeathWinner != 0 {
+			balance = -balance
+		}
+	} else {
+		// Scale the scores so that the losses aren't rounded down to multiples of 8.
+		side0Score, side1Score := s.sideScores(8)
+		balance = side0Score - side1Score
+	}
+	if side != 0 {
+		return -balance
+	}
+	return balance
+}
+
+// sideScores returns the scores of both sides, multiplied by scale.
+func (s Score) sideScores(scale int) (side0Score, side1Score int) {
+	if weights := s.rules.Score; weights != nil {
+		side0Score = Max((1+s.MenLost[1]+s.TanksLost[1])*weights.Losses[0]*scale/8+s.CitiesHeld[0]*weights.Cities[0]*scale, 1)
+		side1Score = Max((1+s.MenLost[0]+s.TanksLost[0])*weights.Losses[1]*scale/8+s.CitiesHeld[1]*weights.Cities[1]*scale, 1)
+	} else {
+		side0Score = (1 + s.MenLost[1] + s.TanksLost[1]) * s.variant.Data3 * scale / 8
+		side1Score = (1 + s.MenLost[0] + s.TanksLost[0]) * scale
+		if s.game != Conflict {
+			side0Score += s.CitiesHeld[0] * 3 * scale
+			side1Score += s.CitiesHeld[1] * 3 * scale
+		} else {
+			side0Score += s.CitiesHeld[0] * 6 * scale / (s.scenarioData.Data174 + 1)
+			side1Score += s.CitiesHeld[1] * 6 * scale / (s.scenarioData.Data174 + 1)
+		}
+	}
+	return
+}
+
 func (s Score) FinalResults(playerSide int) (int, int, int) {
 	winningSide, advantage := s.WinningSideAndAdvantage()
 	var absoluteAdvantage int // a number from [1..10]
@@ -71,12 +114,21 @@ func (s Score) FinalResults(playerSide int) (int, int, int) {
 		v74 = 11 - absoluteAdvantage
 	}
 
-	criticalLocationBalance := s.CriticalLocationsCaptured[0] - s.CriticalLocationsCaptured[1]
-	if criticalLocationBalance >= s.variant.CriticalLocations[0] {
-		v74 = 1 + 9*(1-v73)
+	if s.rules.CriticalLocations {
+		criticalLocationBalance := s.CriticalLocationsCaptured[0] - s.CriticalLocationsCaptured[1]
+		if criticalLocationBalance >= s.variant.CriticalLocations[0] {
+			v74 = 1 + 9*(1-v73)
+		}
+		if -criticalLocationBalance >= s.variant.CriticalLocations[1] {
+			v74 = 1 + 9*v73
+		}
 	}
-	if -criticalLocationBalance >= s.variant.CriticalLocations[1] {
-		v74 = 1 + 9*v73
+	if s.suddenDeath {
+		if s.suddenDeathWinner == v73 {
+			v74 = 10
+		} else {
+			v74 = 1
+		}
 	}
 	var difficulty int
 	if v73 == 0 {
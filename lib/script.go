@@ -0,0 +1,254 @@
+package lib
+
+import (
+	"fmt"
+	"io/fs"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Upper bound on the number of Starlark computation steps of a single hook invocation,
+// so that a buggy script cannot hang the game.
+const maxScriptExecutionSteps = 1000000
+
+// ScenarioScript contains scenario events written in Starlark.
+// A script may define any of the functions every_hour(game), every_12_hours(game)
+// and every_day(game), which are called at the end of the respective GameState updates.
+// The game argument exposes the current date and score, as well as functions
+// for changing the course of the scenario:
+//
+//	game.spawn_unit(side, index, x, y)  - brings a unit not yet in game onto the map
+//	                                      (at its scenario location if x, y are omitted)
+//	game.set_resupply_rate(side, rate)  - changes the daily resupply rate of a side
+//	game.add_supplies(side, amount)     - adds supplies to the side's supply pool
+//	game.set_weather(weather)           - changes the current weather
+//	game.set_victory_points(city, vps)  - changes victory points of a named city
+//	game.add_victory_points(side, vps)  - adds victory points to the side's score
+//	game.message(text)                  - shows a message to the player
+//
+// Scripts are not saved with the game state, so they should derive everything
+// from the game argument instead of keeping their own state between calls.
+type ScenarioScript struct {
+	name    string
+	globals starlark.StringDict
+}
+
+// ReadScenarioScript reads and parses a Starlark scenario script from the given file system.
+func ReadScenarioScript(fsys fs.FS, filename string) (*ScenarioScript, error) {
+	src, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read script file %s (%v)", filename, err)
+	}
+	return ParseScenarioScript(filename, src)
+}
+
+// ParseScenarioScript parses a Starlark scenario script. Name is used in error messages.
+func ParseScenarioScript(name string, src []byte) (*ScenarioScript, error) {
+	thread := &starlark.Thread{Name: name}
+	thread.SetMaxExecutionSteps(maxScriptExecutionSteps)
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, name, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse script %s (%v)", name, err)
+	}
+	globals.Freeze()
+	for _, hook := range []string{"every_hour", "every_12_hours", "every_day"} {
+		if fn, ok := globals[hook]; ok {
+			if _, ok := fn.(starlark.Callable); !ok {
+				return nil, fmt.Errorf("%s in script %s is not a function", hook, name)
+			}
+		}
+	}
+	return &ScenarioScript{name: name, globals: globals}, nil
+}
+
+// Effects of a single hook invocation, which have to be reported after the hook finishes.
+type scriptEffects struct {
+	messages       []string
+	reinforcements [2]bool
+}
+
+func (s *ScenarioScript) call(hook string, state *GameState) (scriptEffects, error) {
+	var effects scriptEffects
+	fn, ok := s.globals[hook]
+	if !ok {
+		return effects, nil
+	}
+	thread := &starlark.Thread{Name: s.name}
+	thread.SetMaxExecutionSteps(maxScriptExecutionSteps)
+	game := newScriptGame(state, &effects)
+	if _, err := starlark.Call(thread, fn, starlark.Tuple{game}, nil); err != nil {
+		return effects, fmt.Errorf("error in %s (%v)", hook, err)
+	}
+	return effects, nil
+}
+
+// scriptUnitCoords converts coordinates passed by a script to unit coordinates
+// of a tile within the bounds of the scenario.
+func (s *GameState) scriptUnitCoords(x, y starlark.Value) (UnitCoords, error) {
+	if x == nil || y == nil {
+		return UnitCoords{}, fmt.Errorf("both x and y coordinates must be given")
+	}
+	xValue, err := starlark.AsInt32(x)
+	if err != nil {
+		return UnitCoords{}, fmt.Errorf("invalid x coordinate %v (%v)", x, err)
+	}
+	yValue, err := starlark.AsInt32(y)
+	if err != nil {
+		return UnitCoords{}, fmt.Errorf("invalid y coordinate %v (%v)", y, err)
+	}
+	xy := UnitCoords{xValue, yValue}
+	// Unit coordinates of tiles in odd rows are odd, in even rows are even.
+	if xy.X < 0 || xy.Y < 0 || xy.X%2 != xy.Y%2 {
+		return UnitCoords{}, fmt.Errorf("invalid coordinates %d,%d", xy.X, xy.Y)
+	}
+	mapXY := xy.ToMapCoords()
+	if !s.terrainTypes.AreCoordsValid(mapXY) ||
+		mapXY.X < s.scenario.MinX || mapXY.X > s.scenario.MaxX ||
+		mapXY.Y < s.scenario.MinY || mapXY.Y > s.scenario.MaxY {
+		return UnitCoords{}, fmt.Errorf("coordinates %d,%d outside of the scenario map", xy.X, xy.Y)
+	}
+	return xy, nil
+}
+
+func newScriptGame(s *GameState, effects *scriptEffects) *starlarkstruct.Struct {
+	pair := func(values [2]int) starlark.Tuple {
+		return starlark.Tuple{starlark.MakeInt(values[0]), starlark.MakeInt(values[1])}
+	}
+	checkSide := func(side int) error {
+		if side != 0 && side != 1 {
+			return fmt.Errorf("invalid side %d", side)
+		}
+		return nil
+	}
+	builtin := func(name string, fn func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)) *starlark.Builtin {
+		return starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return fn(args, kwargs)
+		})
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"minute":        starlark.MakeInt(s.minute),
+		"hour":          starlark.MakeInt(s.hour),
+		"day":           starlark.MakeInt(s.day + 1),
+		"month":         starlark.MakeInt(s.month + 1),
+		"year":          starlark.MakeInt(s.year),
+		"days_elapsed":  starlark.MakeInt(s.daysElapsed),
+		"weather":       starlark.MakeInt(s.weather),
+		"is_night":      starlark.Bool(s.isNight),
+		"variant":       starlark.MakeInt(s.selectedVariant),
+		"supply_levels": pair(s.supplyLevels),
+		"men_lost":      pair(s.score.MenLost),
+		"tanks_lost":    pair(s.score.TanksLost),
+		"cities_held":   pair(s.score.CitiesHeld),
+		"spawn_unit": builtin("spawn_unit", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var side, index int
+			var x, y starlark.Value
+			if err := starlark.UnpackArgs("spawn_unit", args, kwargs, "side", &side, "index", &index, "x?", &x, "y?", &y); err != nil {
+				return nil, err
+			}
+			if err := checkSide(side); err != nil {
+				return nil, err
+			}
+			if index < 0 || index >= len(s.units[side]) {
+				return nil, fmt.Errorf("invalid unit index %d", index)
+			}
+			unit := s.units[side][index]
+			if unit.IsInGame {
+				return starlark.False, nil
+			}
+			if x != nil || y != nil {
+				xy, err := s.scriptUnitCoords(x, y)
+				if err != nil {
+					return nil, err
+				}
+				unit.XY = xy
+			}
+			if s.units.IsUnitAt(unit.XY) {
+				return starlark.False, nil
+			}
+			unit.IsInGame = true
+			unit.HalfDaysUntilAppear = 0
+			s.ai.recordEvent(FlashbackReinforcement, side, unit.XY)
+			s.units[side][index] = unit
+			if !s.allUnitsHidden && s.IsUnitVisible(unit) {
+				s.terrainTypes.showUnit(unit)
+			}
+			effects.reinforcements[side] = true
+			return starlark.True, nil
+		}),
+		"set_resupply_rate": builtin("set_resupply_rate", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var side, rate int
+			if err := starlark.UnpackArgs("set_resupply_rate", args, kwargs, "side", &side, "rate", &rate); err != nil {
+				return nil, err
+			}
+			if err := checkSide(side); err != nil {
+				return nil, err
+			}
+			s.scenarioData.ResupplyRate[side] = Clamp(rate, 0, 255)
+			return starlark.None, nil
+		}),
+		"add_supplies": builtin("add_supplies", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var side, amount int
+			if err := starlark.UnpackArgs("add_supplies", args, kwargs, "side", &side, "amount", &amount); err != nil {
+				return nil, err
+			}
+			if err := checkSide(side); err != nil {
+				return nil, err
+			}
+			s.supplyLevels[side] = Max(s.supplyLevels[side]+amount, 0)
+			return starlark.None, nil
+		}),
+		"set_weather": builtin("set_weather", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var weather int
+			if err := starlark.UnpackArgs("set_weather", args, kwargs, "weather", &weather); err != nil {
+				return nil, err
+			}
+			if weather < 0 || weather >= len(s.scenarioData.Weather) {
+				return nil, fmt.Errorf("invalid weather %d", weather)
+			}
+			s.weather = weather
+			return starlark.None, nil
+		}),
+		"set_victory_points": builtin("set_victory_points", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var name string
+			var points int
+			if err := starlark.UnpackArgs("set_victory_points", args, kwargs, "city", &name, "points", &points); err != nil {
+				return nil, err
+			}
+			if points <= 0 {
+				return nil, fmt.Errorf("invalid victory points %d", points)
+			}
+			for i, city := range s.terrain.Cities {
+				if city.Name != name || city.VictoryPoints == 0 {
+					continue
+				}
+				// Keep the score consistent with the points of the cities held.
+				s.score.CitiesHeld[city.Owner] += points - city.VictoryPoints
+				city.VictoryPoints = points
+				s.terrain.Cities[i] = city
+				return starlark.True, nil
+			}
+			return starlark.False, nil
+		}),
+		"add_victory_points": builtin("add_victory_points", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var side, points int
+			if err := starlark.UnpackArgs("add_victory_points", args, kwargs, "side", &side, "points", &points); err != nil {
+				return nil, err
+			}
+			if err := checkSide(side); err != nil {
+				return nil, err
+			}
+			s.score.CitiesHeld[side] += points
+			return starlark.None, nil
+		}),
+		"message": builtin("message", func(args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var text string
+			if err := starlark.UnpackArgs("message", args, kwargs, "text", &text); err != nil {
+				return nil, err
+			}
+			effects.messages = append(effects.messages, text)
+			return starlark.None, nil
+		}),
+	})
+}
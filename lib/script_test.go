@@ -0,0 +1,120 @@
+package lib
+
+import "testing"
+
+func TestParseScenarioScriptErrors(t *testing.T) {
+	if _, err := ParseScenarioScript("syntax.star", []byte("def every_day(game)\n")); err == nil {
+		t.Error("Expected a syntax error")
+	}
+	if _, err := ParseScenarioScript("hook.star", []byte("every_day = 1\n")); err == nil {
+		t.Error("Expected an error for a hook which is not a function")
+	}
+}
+
+func TestScenarioScriptHooks(t *testing.T) {
+	script, err := ParseScenarioScript("test.star", []byte(`
+def every_day(game):
+    if game.days_elapsed == 2:
+        game.spawn_unit(1, 0, 10, 12)
+        game.set_weather(1)
+        game.add_victory_points(0, 5)
+        game.set_resupply_rate(1, 300)
+        game.message("AIRBORNE DROP!")
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sync := NewMessageSync()
+	s := &GameState{
+		daysElapsed:    2,
+		score:          &Score{},
+		scenarioData:   &Data{Weather: []string{"CLEAR", "RAIN"}},
+		terrain:        &Terrain{},
+		terrainTypes:   newTerrainTypeMap(&Map{Width: 20, Height: 20}, nil),
+		scenario:       &Scenario{MinX: 2, MaxX: 15, MinY: 2, MaxY: 15},
+		units:          &Units{nil, []Unit{{Side: 1}}},
+		sync:           sync,
+		script:         script,
+		ai:             &AI{},
+		allUnitsHidden: true}
+	done := make(chan bool)
+	go func() {
+		done <- s.runScript("every_day")
+	}()
+	var messages []interface{}
+	for {
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Fatal("Script hook quit the game")
+			}
+			if len(messages) != 2 {
+				t.Fatalf("Expected 2 messages, got %v", messages)
+			}
+			if reinforcements, ok := messages[0].(Reinforcements); !ok || !reinforcements.Sides[1] {
+				t.Errorf("Expected reinforcements message, got %v", messages[0])
+			}
+			if message, ok := messages[1].(ScriptMessage); !ok || message.Text != "AIRBORNE DROP!" {
+				t.Errorf("Expected script message, got %v", messages[1])
+			}
+			unit := s.units[1][0]
+			if !unit.IsInGame || unit.XY != (UnitCoords{10, 12}) {
+				t.Errorf("Unit not spawned correctly %v", unit)
+			}
+			if events := s.ai.dayEvents; len(events) != 1 || events[0].Type != FlashbackReinforcement || events[0].XY != unit.XY {
+				t.Errorf("Expected reinforcement flashback event, got %v", events)
+			}
+			if s.weather != 1 {
+				t.Errorf("Expected weather 1, got %d", s.weather)
+			}
+			if s.score.CitiesHeld[0] != 5 {
+				t.Errorf("Expected 5 victory points, got %d", s.score.CitiesHeld[0])
+			}
+			if s.scenarioData.ResupplyRate[1] != 255 {
+				t.Errorf("Expected resupply rate 255, got %d", s.scenarioData.ResupplyRate[1])
+			}
+			return
+		case msg := <-sync.update:
+			messages = append(messages, msg)
+			sync.cont <- true
+		}
+	}
+}
+
+func TestSpawnUnitCoordinates(t *testing.T) {
+	for _, test := range []struct {
+		call  string
+		valid bool
+	}{
+		{"game.spawn_unit(1, 0, 10, 12)", true},
+		{"game.spawn_unit(1, 0)", true},
+		{"game.spawn_unit(1, 0, 10)", false},
+		{"game.spawn_unit(1, 0, y = 12)", false},
+		{"game.spawn_unit(1, 0, 500, 12)", false},
+		{"game.spawn_unit(1, 0, -2, 12)", false},
+		{"game.spawn_unit(1, 0, 11, 12)", false},
+		{"game.spawn_unit(1, 0, 2, 2)", false},
+		{"game.spawn_unit(1, 0, 10, 18)", false},
+	} {
+		script, err := ParseScenarioScript("test.star", []byte("def every_day(game):\n    "+test.call+"\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &GameState{
+			terrainTypes:   newTerrainTypeMap(&Map{Width: 20, Height: 20}, nil),
+			scenario:       &Scenario{MinX: 2, MaxX: 15, MinY: 2, MaxY: 15},
+			units:          &Units{nil, []Unit{{Side: 1, XY: UnitCoords{8, 4}}}},
+			score:          &Score{},
+			ai:             &AI{},
+			allUnitsHidden: true}
+		_, err = script.call("every_day", s)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error %v", test.call, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%s: expected an error", test.call)
+		}
+		if s.units[1][0].IsInGame != test.valid {
+			t.Errorf("%s: unexpected unit %v", test.call, s.units[1][0])
+		}
+	}
+}
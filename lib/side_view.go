@@ -0,0 +1,114 @@
+package lib
+
+// SideView is a read-only view of the game as known to the commander of one side.
+// Enemy units which the side cannot see are left out.
+// Unlike the units shown on the TerrainTypeMap, the view does not depend on
+// which side's units are currently displayed.
+type SideView struct {
+	side           int
+	units          *Units
+	cities         Cities
+	commanderFlags *CommanderFlags
+}
+
+func newSideView(side int, units *Units, cities Cities, commanderFlags *CommanderFlags) SideView {
+	return SideView{side: side, units: units, cities: cities, commanderFlags: commanderFlags}
+}
+
+// ViewFor returns a view of the game as known to the commander of the side.
+// The view reflects the current state of the game, so it must not be used while
+// the game is being updated.
+func (s *GameState) ViewFor(side int) SideView {
+	return newSideView(side, s.units, s.terrain.Cities, s.commanderFlags)
+}
+
+// Side returns the side whose view it is.
+func (v SideView) Side() int {
+	return v.side
+}
+
+// IsUnitVisible returns true if the side can see the unit. When both sides are commanded
+// by the computer every unit is visible, as the game is only watched by the player.
+func (v SideView) IsUnitVisible(unit Unit) bool {
+	return unit.IsInGame && (unit.Side == v.side || unit.InContactWithEnemy || unit.SeenByEnemy ||
+		v.commanderFlags.PlayerHasIntelligence[v.side] ||
+		(!v.commanderFlags.PlayerControlled[0] && !v.commanderFlags.PlayerControlled[1]))
+}
+
+// isUnitReported returns true if the position of the unit has been reported to the side,
+// either by intelligence or because the unit has been spotted. Computer commander plans
+// using the reported units only, it doesn't take into account enemy units it is merely in contact with.
+func (v SideView) isUnitReported(unit Unit) bool {
+	return unit.Side == v.side || v.commanderFlags.PlayerHasIntelligence[v.side] || unit.SeenByEnemy
+}
+
+// Units returns copies of all units visible to the side.
+func (v SideView) Units() []Unit {
+	var units []Unit
+	for _, sideUnits := range v.units {
+		for _, unit := range sideUnits {
+			if v.IsUnitVisible(unit) {
+				units = append(units, unit)
+			}
+		}
+	}
+	return units
+}
+
+// Unit returns the unit with the given index if it's visible to the side.
+func (v SideView) Unit(side, index int) (Unit, bool) {
+	if side < 0 || side > 1 || index < 0 || index >= len(v.units[side]) {
+		return Unit{}, false
+	}
+	unit := v.units[side][index]
+	if !v.IsUnitVisible(unit) {
+		return Unit{}, false
+	}
+	return unit, true
+}
+
+// FindUnitAt returns the unit at given coordinates if it's visible to the side.
+func (v SideView) FindUnitAt(xy UnitCoords) (Unit, bool) {
+	unit, ok := v.units.FindUnitAt(xy)
+	if !ok || !v.IsUnitVisible(unit) {
+		return Unit{}, false
+	}
+	return unit, true
+}
+
+// Cities returns copies of all cities. Owners of cities are known to both sides.
+func (v SideView) Cities() []City {
+	cities := make([]City, len(v.cities))
+	copy(cities, v.cities)
+	return cities
+}
+
+// flashback returns the flashback history as known to the side. Positions of enemy units
+// the side cannot see now are left out, as well as events it didn't witness.
+func (v SideView) flashback(history FlashbackHistory) FlashbackHistory {
+	viewHistory := make(FlashbackHistory, 0, len(history))
+	for _, day := range history {
+		var units FlashbackUnits
+		for _, unit := range day.Units {
+			if unit.Side == v.side {
+				units = append(units, unit)
+			} else if viewUnit, ok := v.Unit(unit.Side, unit.Index); ok && viewUnit.Type == unit.Type {
+				units = append(units, unit)
+			}
+		}
+		viewHistory = append(viewHistory, FlashbackDay{Units: units, Events: v.events(day.Events)})
+	}
+	return viewHistory
+}
+
+// events returns the events known to the side: its own, attacks of the enemy on its units
+// and captured cities, whose owners are known to both sides.
+func (v SideView) events(events FlashbackEvents) FlashbackEvents {
+	var viewEvents FlashbackEvents
+	for _, event := range events {
+		if event.Side == v.side || event.Type == FlashbackBattle || event.Type == FlashbackCapture {
+			viewEvents = append(viewEvents, event)
+		}
+	}
+	return viewEvents
+}
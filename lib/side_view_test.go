@@ -0,0 +1,58 @@
+package lib
+
+import "testing"
+
+func TestSideView(t *testing.T) {
+	var units Units
+	units[0] = []Unit{
+		{Side: 0, Index: 0, IsInGame: true, XY: UnitCoords{1, 1}},
+		{Side: 0, Index: 1, XY: UnitCoords{2, 2}}}
+	units[1] = []Unit{
+		{Side: 1, Index: 0, IsInGame: true, XY: UnitCoords{3, 3}},
+		{Side: 1, Index: 1, IsInGame: true, XY: UnitCoords{4, 4}, SeenByEnemy: true},
+		{Side: 1, Index: 2, IsInGame: true, XY: UnitCoords{5, 5}, InContactWithEnemy: true}}
+	cities := Cities{{Owner: 1, VictoryPoints: 10}}
+	commanderFlags := newCommanderFlags(&Options{AlliedCommander: Player, GermanCommander: Computer, Intelligence: Limited})
+	view := newSideView(0, &units, cities, commanderFlags)
+
+	if visible := view.Units(); len(visible) != 3 || visible[0].Index != 0 || visible[1].Index != 1 || visible[2].Index != 2 {
+		t.Errorf("Unexpected visible units %v", visible)
+	}
+	if _, ok := view.FindUnitAt(UnitCoords{3, 3}); ok {
+		t.Error("Hidden enemy unit found")
+	}
+	if _, ok := view.Unit(1, 0); ok {
+		t.Error("Hidden enemy unit returned")
+	}
+	if _, ok := view.Unit(0, 1); ok {
+		t.Error("Unit which is not in game returned")
+	}
+	if unit, ok := view.FindUnitAt(UnitCoords{5, 5}); !ok || unit.Index != 2 {
+		t.Errorf("Expected enemy unit in contact, got %v %v", unit, ok)
+	}
+	if view.isUnitReported(units[1][2]) || !view.isUnitReported(units[1][1]) {
+		t.Error("Enemy units in contact should not be reported, spotted units should")
+	}
+	view.Cities()[0].Owner = 0
+	if cities[0].Owner != 1 {
+		t.Error("Cities modified through the view")
+	}
+
+	// The view doesn't change when units of the other side are displayed.
+	commanderFlags.SwitchSides()
+	if len(view.Units()) != 3 {
+		t.Errorf("Unexpected visible units after switching sides %v", view.Units())
+	}
+	if visible := newSideView(1, &units, cities, commanderFlags).Units(); len(visible) != 3 {
+		t.Errorf("Expected only own units to be visible to side 1, got %v", visible)
+	}
+
+	fullIntelligence := newCommanderFlags(&Options{AlliedCommander: Player, GermanCommander: Computer, Intelligence: Full})
+	if visible := newSideView(0, &units, cities, fullIntelligence).Units(); len(visible) != 4 {
+		t.Errorf("Expected all units in game to be visible with full intelligence, got %v", visible)
+	}
+	computerOnly := newCommanderFlags(&Options{AlliedCommander: Computer, GermanCommander: Computer, Intelligence: Limited})
+	if visible := newSideView(0, &units, cities, computerOnly).Units(); len(visible) != 4 {
+		t.Errorf("Expected all units in game to be visible when computer plays against computer, got %v", visible)
+	}
+}
@@ -14,6 +14,15 @@ func newTerrainTypeMap(terrainMap *Map, generic *Generic) *TerrainTypeMap {
 	}
 }
 
+// clone returns a copy of the map with its own record of unit locations.
+func (m *TerrainTypeMap) clone() *TerrainTypeMap {
+	return &TerrainTypeMap{
+		terrainMap: m.terrainMap,
+		units:      append([]bool(nil), m.units...),
+		generic:    m.generic,
+	}
+}
+
 func (m *TerrainTypeMap) terrainOrUnitTypeAt(xy UnitCoords) int {
 	if !m.AreCoordsValid(xy.ToMapCoords()) {
 		return 7
@@ -0,0 +1,138 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Turn files are exchanged by players of a play-by-email game.
+// A turn file consists of the turnFileMagic, the TurnHeader, the saved game encrypted
+// with AES-GCM and the HMAC-SHA256 of all the preceding bytes. Keys of both are derived
+// from the key shared by the players.
+//
+// The signature keeps the turn file from being modified. The encryption only keeps the
+// game, with positions of hidden units and the state of the computer commander, from
+// anyone who doesn't know the key. It doesn't keep them from the other player: the game
+// of the other player needs the whole state to play out the next turn, so whoever knows
+// the key can decrypt it.
+const turnFileMagic = "CSTURN2\x00"
+
+// Magic of turn files with an unencrypted saved game, written before they were encrypted.
+const unencryptedTurnFileMagic = "CSTURN1\x00"
+
+// TurnHeader describes a turn of a play-by-email game.
+type TurnHeader struct {
+	// Number of the turn, starting from 1.
+	Number int
+	// Side which gives orders in this turn.
+	Side int
+	// Number of game hours played after the orders are given.
+	Hours int
+	// Variant of the scenario being played.
+	Variant int
+}
+
+type turnHeaderData struct {
+	Number               uint16
+	Side, Hours, Variant uint8
+}
+
+// WriteTurnFile writes a turn file containing the header and the saved game encrypted and signed with the key.
+func WriteTurnFile(writer io.Writer, key []byte, header TurnHeader, game []byte) error {
+	if header.Number < 1 || header.Number > 65535 || header.Side < 0 || header.Side > 1 ||
+		header.Hours < 1 || header.Hours > 255 || header.Variant < 0 || header.Variant > 255 {
+		return fmt.Errorf("invalid turn header %v", header)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(turnFileMagic)
+	headerData := turnHeaderData{
+		Number:  uint16(header.Number),
+		Side:    uint8(header.Side),
+		Hours:   uint8(header.Hours),
+		Variant: uint8(header.Variant)}
+	if err := binary.Write(&buf, binary.LittleEndian, headerData); err != nil {
+		return err
+	}
+	aead, err := turnFileCipher(key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("cannot generate nonce (%v)", err)
+	}
+	buf.Write(nonce)
+	buf.Write(aead.Seal(nil, nonce, game, nil))
+	mac := hmac.New(sha256.New, deriveTurnFileKey(key, "signature"))
+	mac.Write(buf.Bytes())
+	buf.Write(mac.Sum(nil))
+	_, err = writer.Write(buf.Bytes())
+	return err
+}
+
+// ReadTurnFile reads a turn file written by WriteTurnFile, verifies its signature
+// and returns the header and the decrypted saved game.
+func ReadTurnFile(reader io.Reader, key []byte) (TurnHeader, []byte, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return TurnHeader{}, nil, fmt.Errorf("cannot read turn file (%v)", err)
+	}
+	if bytes.HasPrefix(data, []byte(unencryptedTurnFileMagic)) {
+		return TurnHeader{}, nil, fmt.Errorf("turn file written by an older version of the game")
+	}
+	aead, err := turnFileCipher(key)
+	if err != nil {
+		return TurnHeader{}, nil, err
+	}
+	headerSize := len(turnFileMagic) + binary.Size(turnHeaderData{})
+	if len(data) < headerSize+aead.NonceSize()+aead.Overhead()+sha256.Size || string(data[:len(turnFileMagic)]) != turnFileMagic {
+		return TurnHeader{}, nil, fmt.Errorf("not a turn file")
+	}
+	signed, signature := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+	mac := hmac.New(sha256.New, deriveTurnFileKey(key, "signature"))
+	mac.Write(signed)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return TurnHeader{}, nil, fmt.Errorf("invalid turn file signature")
+	}
+	var headerData turnHeaderData
+	if err := binary.Read(bytes.NewReader(signed[len(turnFileMagic):]), binary.LittleEndian, &headerData); err != nil {
+		return TurnHeader{}, nil, fmt.Errorf("cannot read turn header (%v)", err)
+	}
+	header := TurnHeader{
+		Number:  int(headerData.Number),
+		Side:    int(headerData.Side),
+		Hours:   int(headerData.Hours),
+		Variant: int(headerData.Variant)}
+	if header.Side > 1 || header.Hours < 1 {
+		return TurnHeader{}, nil, fmt.Errorf("invalid turn header %v", header)
+	}
+	nonce, encrypted := signed[headerSize:headerSize+aead.NonceSize()], signed[headerSize+aead.NonceSize():]
+	game, err := aead.Open(nil, nonce, encrypted, nil)
+	if err != nil {
+		return TurnHeader{}, nil, fmt.Errorf("cannot decrypt turn file (%v)", err)
+	}
+	return header, game, nil
+}
+
+// deriveTurnFileKey derives a key for the given purpose from the key shared by the players,
+// so that the same key isn't used both for signing and for encryption.
+func deriveTurnFileKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func turnFileCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveTurnFileKey(key, "encryption"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create turn file cipher (%v)", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTurnFile(t *testing.T) {
+	key := []byte("secret")
+	header := TurnHeader{Number: 3, Side: 1, Hours: 12, Variant: 2}
+	game := []byte("saved game")
+	var buf bytes.Buffer
+	if err := WriteTurnFile(&buf, key, header, game); err != nil {
+		t.Fatal(err)
+	}
+	readHeader, readGame, err := ReadTurnFile(bytes.NewReader(buf.Bytes()), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readHeader != header || !bytes.Equal(readGame, game) {
+		t.Errorf("Expected %v %q, got %v %q", header, game, readHeader, readGame)
+	}
+	if bytes.Contains(buf.Bytes(), game) {
+		t.Error("Saved game not encrypted in the turn file")
+	}
+	if _, _, err := ReadTurnFile(bytes.NewReader(buf.Bytes()), []byte("other")); err == nil {
+		t.Error("Expected an error for a wrong key")
+	}
+	tampered := bytes.Clone(buf.Bytes())
+	tampered[len(turnFileMagic)+2]++
+	if _, _, err := ReadTurnFile(bytes.NewReader(tampered), key); err == nil {
+		t.Error("Expected an error for a tampered turn file")
+	}
+	if _, _, err := ReadTurnFile(bytes.NewReader(buf.Bytes()[:10]), key); err == nil {
+		t.Error("Expected an error for a truncated turn file")
+	}
+	unencrypted := append([]byte(unencryptedTurnFileMagic), buf.Bytes()[len(turnFileMagic):]...)
+	if _, _, err := ReadTurnFile(bytes.NewReader(unencrypted), key); err == nil {
+		t.Error("Expected an error for a turn file of an older version")
+	}
+	if err := WriteTurnFile(&buf, key, TurnHeader{Number: 1, Side: 0, Hours: 0}, game); err == nil {
+		t.Error("Expected an error for a turn without hours")
+	}
+}
+
+func TestLoadTurnGame(t *testing.T) {
+	options := DefaultOptions()
+	options.GermanCommander = Player
+	options.Intelligence = Limited
+	first := newTestGameState(options)
+	first.units[1][2].XY = UnitCoords{18, 12}
+	first.hour = 20
+	var game bytes.Buffer
+	if err := first.Save(&game); err != nil {
+		t.Fatal(err)
+	}
+	key := []byte("secret")
+	var turn bytes.Buffer
+	if err := WriteTurnFile(&turn, key, TurnHeader{Number: 2, Side: 1, Hours: 12}, game.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	_, turnGame, err := ReadTurnFile(&turn, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The game is loaded by the player of the other side, as in MainScreen.loadTurnGame.
+	second := newTestGameState(options)
+	if err := second.Load(bytes.NewReader(turnGame)); err != nil {
+		t.Fatal(err)
+	}
+	second.SwitchSides()
+	expectedFlags := CommanderFlags{
+		PlayerControlled:  [2]bool{true, true},
+		PlayerCanSeeUnits: [2]bool{false, true}}
+	if *second.commanderFlags != expectedFlags {
+		t.Errorf("Expected commander flags %v, got %v", expectedFlags, *second.commanderFlags)
+	}
+	if second.units[1][2] != first.units[1][2] || second.hour != 20 {
+		t.Errorf("Game not loaded, unit %v, hour %d", second.units[1][2], second.hour)
+	}
+	if view := second.ViewFor(1); view.IsUnitVisible(second.units[0][0]) || !view.IsUnitVisible(second.units[1][0]) {
+		t.Error("Expected the player to see only units of own side")
+	}
+}
@@ -2,7 +2,6 @@ package lib
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"io/fs"
@@ -111,6 +110,15 @@ func (u Units) FindUnitOfSideAt(xy UnitCoords, side int) (Unit, bool) {
 	}
 	return Unit{}, false
 }
+
+// clone returns a copy of the units not sharing memory with the original.
+func (u Units) clone() Units {
+	var units Units
+	for side, sideUnits := range u {
+		units[side] = append([]Unit(nil), sideUnits...)
+	}
+	return units
+}
 func (u Units) NeighbourUnitCount(xy UnitCoords, side int) int {
 	num := 0
 	for _, unit := range u[side] {
@@ -144,14 +152,6 @@ func (u Unit) IsVisible() bool {
 	return u.IsInGame && (u.InContactWithEnemy || u.SeenByEnemy)
 }
 
-type FlashbackUnit struct {
-	XY           UnitCoords
-	ColorPalette int
-	Type         int
-}
-type FlashbackUnits []FlashbackUnit
-type FlashbackHistory []FlashbackUnits
-
 func ReadUnits(fsys fs.FS, filename string, game Game, unitTypeNames []string, unitNames [2][]string, generals *Generals) (*Units, error) {
 	fileData, err := fs.ReadFile(fsys, filename)
 	if err != nil {
@@ -354,73 +354,6 @@ func (u *Unit) Write(writer io.Writer) error {
 	return nil
 }
 
-func (u FlashbackUnits) Write(writer io.Writer) error {
-	size := uint64(len(u))
-	if err := binary.Write(writer, binary.LittleEndian, size); err != nil {
-		return err
-	}
-	var data [4]byte
-	for _, unit := range u {
-		data[0] = byte(unit.XY.X)
-		data[1] = byte(unit.XY.Y)
-		data[2] = byte(unit.Type) + byte(unit.ColorPalette<<4)
-		if _, err := writer.Write(data[:]); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (u *FlashbackUnits) Read(reader io.Reader) error {
-	var size uint64
-	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
-		return err
-	}
-	units := make([]FlashbackUnit, 0, size)
-	var data [4]byte
-	for i := 0; i < int(size); i++ {
-		if _, err := io.ReadFull(reader, data[:]); err != nil {
-			return err
-		}
-		units = append(units, FlashbackUnit{
-			XY:           UnitCoords{int(data[0]), int(data[1])},
-			Type:         int(data[2] & 15),
-			ColorPalette: int(data[2] / 16)})
-	}
-	*u = FlashbackUnits(units)
-	return nil
-}
-
-func (h FlashbackHistory) Write(writer io.Writer) error {
-	size := uint64(len(h))
-	if err := binary.Write(writer, binary.LittleEndian, size); err != nil {
-		return err
-	}
-	for _, units := range h {
-		if err := units.Write(writer); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func (h *FlashbackHistory) Read(reader io.Reader) error {
-	var size uint64
-	if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
-		return err
-	}
-	history := make([]FlashbackUnits, 0, size)
-	for i := 0; i < int(size); i++ {
-		units := FlashbackUnits{}
-		if err := units.Read(reader); err != nil {
-			return err
-		}
-		history = append(history, units)
-	}
-	*h = FlashbackHistory(history)
-	return nil
-}
-
 func (u Unit) FullString() string {
 	return fmt.Sprintf(`Side: %d
 In contact with enemy: %t
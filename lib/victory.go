@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+)
+
+// VictoryRules describe when the game ends and how the winner is determined.
+type VictoryRules struct {
+	// Weights used to compute score of both sides. If nil, the original formula is used.
+	Score *ScoreWeights `json:"score,omitempty"`
+	// If true, capturing the number of critical locations defined by the variant
+	// ends the game with a total victory, like in the original games.
+	// True unless it's set to false in the JSON file.
+	CriticalLocations bool `json:"critical_locations"`
+	// Sudden-death conditions checked every day at 6 PM. The first condition met
+	// ends the game with a total victory of the side defined by the condition.
+	Conditions []VictoryCondition `json:"conditions,omitempty"`
+}
+
+// ScoreWeights replace the original scoring formula. Score of a side is
+// (1 + enemy men lost + enemy tanks lost) * Losses[side] / 8 + victory points held * Cities[side].
+type ScoreWeights struct {
+	Losses [2]int `json:"losses"`
+	Cities [2]int `json:"cities"`
+}
+
+type VictoryConditionType string
+
+const (
+	// Side wins if it holds all the listed cities. If Deadline is set and the cities
+	// are not held on that day, the other side wins.
+	HoldCities VictoryConditionType = "hold_cities"
+	// Side wins if the losses of the enemy are at least Ratio percent of its own losses.
+	CasualtyRatio VictoryConditionType = "casualty_ratio"
+)
+
+// VictoryCondition is a single sudden-death condition. Days are counted
+// from the start of the scenario.
+type VictoryCondition struct {
+	Type VictoryConditionType `json:"type"`
+	// Side which wins if the condition is met.
+	Side int `json:"side"`
+	// First day on which the condition is checked.
+	FromDay int `json:"from_day,omitempty"`
+	// Cities to hold (HoldCities only).
+	Cities []string `json:"cities,omitempty"`
+	// Day by which the cities have to be held (HoldCities only, 0 for no deadline).
+	Deadline int `json:"deadline,omitempty"`
+	// Percentage of enemy losses relative to own losses (CasualtyRatio only).
+	Ratio int `json:"ratio,omitempty"`
+}
+
+// DefaultVictoryRules returns the rules of the original games.
+func DefaultVictoryRules() VictoryRules {
+	return VictoryRules{CriticalLocations: true}
+}
+
+// UnmarshalJSON decodes the rules, keeping the original rules for fields left out.
+func (r *VictoryRules) UnmarshalJSON(data []byte) error {
+	type jsonVictoryRules VictoryRules
+	rules := jsonVictoryRules(DefaultVictoryRules())
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return err
+	}
+	*r = VictoryRules(rules)
+	return nil
+}
+
+// ScenarioVictoryRules contains victory rules for all variants of a scenario.
+type ScenarioVictoryRules struct {
+	// Rules used for variants not listed in Variants. If nil, the default rules are used.
+	Default *VictoryRules `json:"default,omitempty"`
+	// Rules for particular variants, keyed by the variant number.
+	Variants map[int]VictoryRules `json:"variants,omitempty"`
+}
+
+// ForVariant returns victory rules to be used in the given variant.
+func (r *ScenarioVictoryRules) ForVariant(variant int) VictoryRules {
+	if rules, ok := r.Variants[variant]; ok {
+		return rules
+	}
+	if r.Default != nil {
+		return *r.Default
+	}
+	return DefaultVictoryRules()
+}
+
+// ReadScenarioVictoryRules reads victory rules of a scenario from a JSON file.
+func ReadScenarioVictoryRules(fsys fs.FS, filename string) (*ScenarioVictoryRules, error) {
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read victory rules file %s (%v)", filename, err)
+	}
+	return ParseScenarioVictoryRules(data)
+}
+
+// ParseScenarioVictoryRules parses JSON encoded victory rules of a scenario.
+func ParseScenarioVictoryRules(data []byte) (*ScenarioVictoryRules, error) {
+	var rules ScenarioVictoryRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("cannot parse victory rules (%v)", err)
+	}
+	if rules.Default != nil {
+		if err := rules.Default.validate(); err != nil {
+			return nil, err
+		}
+	}
+	for variant, variantRules := range rules.Variants {
+		if err := variantRules.validate(); err != nil {
+			return nil, fmt.Errorf("variant %d: %v", variant, err)
+		}
+	}
+	return &rules, nil
+}
+
+func (r VictoryRules) validate() error {
+	for i, condition := range r.Conditions {
+		if condition.Side != 0 && condition.Side != 1 {
+			return fmt.Errorf("condition %d: invalid side %d", i, condition.Side)
+		}
+		switch condition.Type {
+		case HoldCities:
+			if len(condition.Cities) == 0 {
+				return fmt.Errorf("condition %d: no cities to hold", i)
+			}
+		case CasualtyRatio:
+			if condition.Ratio <= 0 {
+				return fmt.Errorf("condition %d: invalid casualty ratio %d", i, condition.Ratio)
+			}
+		default:
+			return fmt.Errorf("condition %d: unknown condition type \"%s\"", i, condition.Type)
+		}
+	}
+	return nil
+}
+
+// checkCities verifies that all the cities referenced by the rules exist in the scenario.
+func (r VictoryRules) checkCities(cities Cities) error {
+	for _, condition := range r.Conditions {
+	cityLoop:
+		for _, name := range condition.Cities {
+			for _, city := range cities {
+				if city.Name == name && city.VictoryPoints > 0 {
+					continue cityLoop
+				}
+			}
+			return fmt.Errorf("unknown city \"%s\"", name)
+		}
+	}
+	return nil
+}
+
+// suddenDeathWinner returns (winning side, true) if any of the conditions is met.
+func (r VictoryRules) suddenDeathWinner(score *Score, cities Cities, daysElapsed int) (int, bool) {
+	for _, condition := range r.Conditions {
+		if daysElapsed < condition.FromDay {
+			continue
+		}
+		switch condition.Type {
+		case HoldCities:
+			if holdsCities(cities, condition.Cities, condition.Side) {
+				return condition.Side, true
+			}
+			if condition.Deadline > 0 && daysElapsed >= condition.Deadline {
+				return 1 - condition.Side, true
+			}
+		case CasualtyRatio:
+			ownLosses := score.MenLost[condition.Side] + score.TanksLost[condition.Side]
+			enemyLosses := score.MenLost[1-condition.Side] + score.TanksLost[1-condition.Side]
+			if enemyLosses*100 >= (ownLosses+1)*condition.Ratio {
+				return condition.Side, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func holdsCities(cities Cities, names []string, side int) bool {
+	for _, name := range names {
+		held := false
+		for _, city := range cities {
+			if city.Name == name && city.VictoryPoints > 0 && city.Owner == side {
+				held = true
+				break
+			}
+		}
+		if !held {
+			return false
+		}
+	}
+	return true
+}
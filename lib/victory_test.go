@@ -0,0 +1,90 @@
+package lib
+
+import "testing"
+
+func TestParseScenarioVictoryRules(t *testing.T) {
+	rules, err := ParseScenarioVictoryRules([]byte(`{
+  "variants": {
+    "1": {
+      "score": {"losses": [8, 8], "cities": [1, 1]},
+      "conditions": [{"type": "hold_cities", "side": 0, "cities": ["PARIS"], "deadline": 30}]
+    },
+    "2": {"critical_locations": false}
+  }
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultRules := rules.ForVariant(0); !defaultRules.CriticalLocations || defaultRules.Score != nil {
+		t.Errorf("Expected default rules for variant 0, got %v", defaultRules)
+	}
+	variantRules := rules.ForVariant(1)
+	if variantRules.Score == nil || len(variantRules.Conditions) != 1 {
+		t.Errorf("Unexpected rules for variant 1 %v", variantRules)
+	}
+	if !variantRules.CriticalLocations {
+		t.Error("Expected critical locations to end the game when the rules leave them out")
+	}
+	if rules.ForVariant(2).CriticalLocations {
+		t.Error("Expected critical locations to be disabled")
+	}
+
+	for _, invalid := range []string{
+		`{"default": {"conditions": [{"type": "hold_cities", "side": 2, "cities": ["PARIS"]}]}}`,
+		`{"default": {"conditions": [{"type": "hold_cities", "side": 0}]}}`,
+		`{"default": {"conditions": [{"type": "casualty_ratio", "side": 0}]}}`,
+		`{"default": {"conditions": [{"type": "unknown", "side": 0}]}}`,
+	} {
+		if _, err := ParseScenarioVictoryRules([]byte(invalid)); err == nil {
+			t.Errorf("Expected an error parsing %s", invalid)
+		}
+	}
+}
+
+func TestSuddenDeathConditions(t *testing.T) {
+	cities := Cities{
+		{Name: "PARIS", Owner: 1, VictoryPoints: 10},
+		{Name: "CAEN", Owner: 0, VictoryPoints: 2}}
+	rules := VictoryRules{Conditions: []VictoryCondition{
+		{Type: HoldCities, Side: 0, Cities: []string{"PARIS", "CAEN"}, Deadline: 20},
+		{Type: CasualtyRatio, Side: 1, FromDay: 5, Ratio: 300}}}
+	score := &Score{MenLost: [2]int{40, 9}}
+	if _, ok := rules.suddenDeathWinner(score, cities, 3); ok {
+		t.Error("No condition should be met on day 3")
+	}
+	if winner, ok := rules.suddenDeathWinner(score, cities, 5); !ok || winner != 1 {
+		t.Errorf("Expected side 1 to win by casualty ratio, got %d, %v", winner, ok)
+	}
+	score.MenLost[0] = 0
+	if winner, ok := rules.suddenDeathWinner(score, cities, 20); !ok || winner != 1 {
+		t.Errorf("Expected side 1 to win after the deadline, got %d, %v", winner, ok)
+	}
+	cities[0].Owner = 0
+	if winner, ok := rules.suddenDeathWinner(score, cities, 10); !ok || winner != 0 {
+		t.Errorf("Expected side 0 to win by holding cities, got %d, %v", winner, ok)
+	}
+}
+
+func TestWeightedScore(t *testing.T) {
+	score := Score{
+		rules:      VictoryRules{Score: &ScoreWeights{Losses: [2]int{8, 8}}},
+		MenLost:    [2]int{11, 9},
+		CitiesHeld: [2]int{50, 0}}
+	if winningSide, advantage := score.WinningSideAndAdvantage(); winningSide != 1 || advantage != 0 {
+		t.Errorf("Expected slight advantage of side 1, got %d, %d", winningSide, advantage)
+	}
+	score.rules.Score.Cities = [2]int{1, 1}
+	if winningSide, _ := score.WinningSideAndAdvantage(); winningSide != 0 {
+		t.Errorf("Expected side 0 to win when cities count, got %d", winningSide)
+	}
+}
+
+func TestParseVictoryRulesWithoutCriticalLocations(t *testing.T) {
+	rules, err := ParseScenarioVictoryRules([]byte(`{"default": {"conditions": [{"type": "casualty_ratio", "side": 1, "ratio": 400}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultRules := rules.ForVariant(0); !defaultRules.CriticalLocations || len(defaultRules.Conditions) != 1 {
+		t.Errorf("Expected the original critical-location victory to be kept, got %v", defaultRules)
+	}
+}
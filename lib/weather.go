@@ -0,0 +1,159 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math/rand"
+)
+
+// WeatherModel selects how the weather changes from day to day.
+type WeatherModel int
+
+func (w WeatherModel) String() string {
+	switch w {
+	case OriginalWeather:
+		return "ORIGINAL"
+	case ForecastWeather:
+		return "FORECAST"
+	case RegionalWeather:
+		return "REGIONAL"
+	}
+	panic(fmt.Errorf("unknown weather model: %d", int(w)))
+}
+func (w WeatherModel) Next() WeatherModel {
+	return (w + 1) % 3
+}
+
+// HasForecast returns true if the weather is drawn from weather tables and forecast.
+func (w WeatherModel) HasForecast() bool {
+	return w != OriginalWeather
+}
+
+const (
+	// Weather is re-rolled every day from four possible values per quarter (as in the original games).
+	OriginalWeather WeatherModel = 0
+	// Weather persists between days, is drawn from monthly tables and is forecast three days ahead.
+	ForecastWeather WeatherModel = 1
+	// Like ForecastWeather, but weather fronts make the weather better or worse in some regions of the map.
+	RegionalWeather WeatherModel = 2
+)
+
+// Number of days ahead for which the weather is forecast.
+const WeatherForecastDays = 3
+
+// WeatherTable contains seasonal weather statistics used by the ForecastWeather model.
+type WeatherTable struct {
+	// Chance (in percent) that the weather stays the same as on the previous day.
+	Persistence int `json:"persistence"`
+	// Relative frequencies of weather types (indices into Data.Weather) for every month.
+	Months [12][]int `json:"months"`
+}
+
+// DefaultWeatherTable builds a weather table from the weather possible
+// in every quarter of the year in the original scenario data.
+func DefaultWeatherTable(data *Data) WeatherTable {
+	table := WeatherTable{Persistence: 50}
+	for month := range table.Months {
+		frequencies := make([]int, len(data.Weather))
+		for i := 0; i < 4; i++ {
+			weather := int(data.PossibleWeather[4*(month/3)+i])
+			if weather < len(frequencies) {
+				frequencies[weather]++
+			}
+		}
+		table.Months[month] = frequencies
+	}
+	return table
+}
+
+// ReadWeatherTable reads a JSON encoded weather table from the given file system.
+func ReadWeatherTable(fsys fs.FS, filename string) (WeatherTable, error) {
+	var table WeatherTable
+	data, err := fs.ReadFile(fsys, filename)
+	if err != nil {
+		return table, fmt.Errorf("cannot read weather file %s (%v)", filename, err)
+	}
+	if err := json.Unmarshal(data, &table); err != nil {
+		return table, fmt.Errorf("cannot parse weather file %s (%v)", filename, err)
+	}
+	return table, nil
+}
+
+func (t WeatherTable) validate(numWeatherTypes int) error {
+	if t.Persistence < 0 || t.Persistence > 100 {
+		return fmt.Errorf("invalid weather persistence %d", t.Persistence)
+	}
+	for month, frequencies := range t.Months {
+		if len(frequencies) > numWeatherTypes {
+			return fmt.Errorf("too many weather types for month %d", month+1)
+		}
+		total := 0
+		for _, frequency := range frequencies {
+			if frequency < 0 {
+				return fmt.Errorf("negative weather frequency for month %d", month+1)
+			}
+			total += frequency
+		}
+		if total == 0 {
+			return fmt.Errorf("no weather possible in month %d", month+1)
+		}
+	}
+	return nil
+}
+
+// next returns weather for the day following a day with the given weather.
+func (t WeatherTable) next(previous, month int, rnd *rand.Rand) int {
+	if Rand(100, rnd) < t.Persistence {
+		return previous
+	}
+	total := 0
+	for _, frequency := range t.Months[month] {
+		total += frequency
+	}
+	r := Rand(total, rnd)
+	for weather, frequency := range t.Months[month] {
+		if r < frequency {
+			return weather
+		}
+		r -= frequency
+	}
+	panic(fmt.Errorf("invalid weather table for month %d", month))
+}
+
+// WeatherRegions contains differences between the local and the global weather
+// in every region of the map. Regions have the same size as cells of Terrain.Coeffs
+// (8x4 in unit coordinates).
+type WeatherRegions [16][16]int
+
+// generate places two or three random weather fronts on the map.
+func (r *WeatherRegions) generate(rnd *rand.Rand) {
+	*r = WeatherRegions{}
+	numFronts := 2 + Rand(2, rnd)
+	for i := 0; i < numFronts; i++ {
+		centerX, centerY := Rand(16, rnd), Rand(16, rnd)
+		radius := 2 + Rand(4, rnd)
+		delta := 1 + Rand(2, rnd)
+		if Rand(2, rnd) == 0 {
+			delta = -delta
+		}
+		for x := 0; x < 16; x++ {
+			for y := 0; y < 16; y++ {
+				if Abs(x-centerX)+Abs(y-centerY) <= radius {
+					r[x][y] += delta
+				}
+			}
+		}
+	}
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 16; y++ {
+			r[x][y] = Clamp(r[x][y], -2, 2)
+		}
+	}
+}
+
+// weatherAt returns weather at given coordinates if the global weather is as given.
+func (r *WeatherRegions) weatherAt(xy UnitCoords, weather, numWeatherTypes int) int {
+	x, y := Clamp(xy.X/8, 0, 15), Clamp(xy.Y/4, 0, 15)
+	return Clamp(weather+r[x][y], 0, numWeatherTypes-1)
+}
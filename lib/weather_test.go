@@ -0,0 +1,88 @@
+package lib
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDefaultWeatherTable(t *testing.T) {
+	data := &Data{
+		Weather:         []string{"CLEAR", "CLOUDY", "RAIN", "SNOW"},
+		PossibleWeather: [16]byte{3, 3, 2, 1, 0, 0, 1, 2, 0, 0, 0, 1, 2, 2, 3, 1}}
+	table := DefaultWeatherTable(data)
+	if err := table.validate(len(data.Weather)); err != nil {
+		t.Fatal(err)
+	}
+	expected := [4][]int{{0, 1, 1, 2}, {2, 1, 1, 0}, {3, 1, 0, 0}, {0, 1, 2, 1}}
+	for month, frequencies := range table.Months {
+		for weather, frequency := range frequencies {
+			if frequency != expected[month/3][weather] {
+				t.Errorf("Unexpected frequency of weather %d in month %d: %d", weather, month, frequency)
+			}
+		}
+	}
+}
+
+func TestWeatherTableNext(t *testing.T) {
+	var table WeatherTable
+	table.Persistence = 100
+	for month := range table.Months {
+		table.Months[month] = []int{1, 0, 1}
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if weather := table.next(1, 4, rnd); weather != 1 {
+			t.Fatalf("Weather should always persist, got %d", weather)
+		}
+	}
+	table.Persistence = 0
+	for i := 0; i < 100; i++ {
+		if weather := table.next(1, 4, rnd); weather == 1 {
+			t.Fatal("Weather with zero frequency was drawn")
+		}
+	}
+}
+
+func TestInvalidWeatherTable(t *testing.T) {
+	var table WeatherTable
+	for month := range table.Months {
+		table.Months[month] = []int{1, 1}
+	}
+	if err := table.validate(1); err == nil {
+		t.Error("Expected an error for too many weather types")
+	}
+	table.Months[3] = []int{0, 0}
+	if err := table.validate(2); err == nil {
+		t.Error("Expected an error for a month without possible weather")
+	}
+}
+
+func TestWeatherRegions(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var regions WeatherRegions
+	for i := 0; i < 20; i++ {
+		regions.generate(rnd)
+		for x := 0; x < 16; x++ {
+			for y := 0; y < 16; y++ {
+				if regions[x][y] < -2 || regions[x][y] > 2 {
+					t.Fatalf("Weather difference out of range at (%d,%d): %d", x, y, regions[x][y])
+				}
+			}
+		}
+	}
+	regions = WeatherRegions{}
+	regions[1][2] = 2
+	regions[15][15] = -2
+	if weather := regions.weatherAt(UnitCoords{X: 9, Y: 11}, 1, 4); weather != 3 {
+		t.Errorf("Expected local weather 3, got %d", weather)
+	}
+	if weather := regions.weatherAt(UnitCoords{X: 9, Y: 11}, 3, 4); weather != 3 {
+		t.Errorf("Local weather should be capped at 3, got %d", weather)
+	}
+	if weather := regions.weatherAt(UnitCoords{X: 200, Y: 100}, 1, 4); weather != 0 {
+		t.Errorf("Expected local weather 0 beyond the last region, got %d", weather)
+	}
+	if weather := regions.weatherAt(UnitCoords{X: 40, Y: 40}, 1, 4); weather != 1 {
+		t.Errorf("Expected global weather outside of fronts, got %d", weather)
+	}
+}
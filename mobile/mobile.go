@@ -3,12 +3,12 @@ package mobile
 import (
 	"bytes"
 	_ "embed"
-	"math/rand"
 	"time"
 
 	"github.com/hajimehoshi/ebiten/v2/mobile"
 
 	"github.com/pwiecz/command_series/atr"
+	"github.com/pwiecz/command_series/lib"
 	"github.com/pwiecz/command_series/ui"
 )
 
@@ -16,12 +16,12 @@ import (
 var crusadeAtr []byte
 
 func init() {
-	source := rand.NewSource(time.Now().UnixNano())
+	source := lib.NewRandSource(time.Now().UnixNano())
 	fsys, err := atr.NewAtrFS(bytes.NewReader(crusadeAtr))
 	if err != nil {
 		panic(err)
 	}
-	game, err := ui.NewGame(fsys, rand.New(source))
+	game, err := ui.NewGame(fsys, source, ui.NetworkOptions{}, ui.TurnOptions{})
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,134 @@
+package netplay
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// Client receives updates of a game run by a host and sends orders of the local player to it.
+type Client struct {
+	conn    io.ReadWriteCloser
+	decoder *gob.Decoder
+
+	encoderMutex sync.Mutex
+	encoder      *gob.Encoder
+
+	side int
+
+	// Error which stopped reading updates from the host.
+	readError error
+}
+
+// Join connects to a game hosted on the given address.
+func Join(address string) (*Client, error) {
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to %s (%v)", address, err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates a client communicating with a host over the given connection.
+func NewClient(conn io.ReadWriteCloser) *Client {
+	return &Client{
+		conn:    conn,
+		decoder: gob.NewDecoder(conn),
+		encoder: gob.NewEncoder(conn)}
+}
+
+// ReadHello reads description of the game sent by the host.
+func (c *Client) ReadHello() (Hello, error) {
+	var hello Hello
+	if err := c.decoder.Decode(&hello); err != nil {
+		c.conn.Close()
+		return hello, fmt.Errorf("cannot read game description (%v)", err)
+	}
+	if hello.Version != ProtocolVersion {
+		c.conn.Close()
+		return hello, fmt.Errorf("incompatible protocol version %d", hello.Version)
+	}
+	c.side = hello.Side
+	return hello, nil
+}
+
+// Side returns side commanded by the local player.
+func (c *Client) Side() int {
+	return c.side
+}
+
+// Run passes updates received from the host to the UI through sync until the UI stops.
+// Scenario data is used to decode the updates.
+func (c *Client) Run(game ClientGame, data *lib.Data, sync *lib.MessageSync) {
+	defer c.conn.Close()
+	updates := make(chan update, 16)
+	go c.readUpdates(updates)
+	if !sync.Wait() {
+		return
+	}
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				sync.SendUpdate(ConnectionLost{c.readError})
+				return
+			}
+			// The UI is waiting for an update, so it's safe to modify the game state.
+			message, err := c.applyUpdate(game, data, update)
+			if err != nil {
+				sync.SendUpdate(ConnectionLost{err})
+				return
+			}
+			if !sync.SendUpdate(message) {
+				return
+			}
+			if err := c.send(request{Ack: true}); err != nil {
+				sync.SendUpdate(ConnectionLost{err})
+				return
+			}
+		case <-time.After(idleTimeout):
+			// Keep the UI responsive while waiting for the host.
+			if !sync.SendUpdate(nil) {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) readUpdates(updates chan<- update) {
+	defer close(updates)
+	for {
+		var update update
+		if err := c.decoder.Decode(&update); err != nil {
+			c.readError = err
+			return
+		}
+		updates <- update
+	}
+}
+
+func (c *Client) applyUpdate(game ClientGame, data *lib.Data, update update) (interface{}, error) {
+	if update.View != nil {
+		if err := game.ReadView(bytes.NewReader(update.View)); err != nil {
+			return nil, err
+		}
+	}
+	return update.Message.Decode(data)
+}
+
+// SendOrder sends an order of the local player to the host.
+func (c *Client) SendOrder(order lib.UnitOrder) error {
+	return c.send(request{Order: &order})
+}
+
+func (c *Client) send(request request) error {
+	c.encoderMutex.Lock()
+	defer c.encoderMutex.Unlock()
+	return c.encoder.Encode(request)
+}
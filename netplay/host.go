@@ -0,0 +1,229 @@
+package netplay
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// Host relays updates of a game to a remote player and applies the remote player's orders.
+type Host struct {
+	conn    io.ReadWriteCloser
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+	// Side commanded by the remote player.
+	side int
+
+	requests chan request
+	// Error to be reported to the UI if the connection is broken.
+	connectionError error
+	connected       bool
+}
+
+// Accept waits for a remote player to connect on the given address.
+func Accept(address string, side int) (*Host, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen on %s (%v)", address, err)
+	}
+	defer listener.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("cannot accept connection (%v)", err)
+	}
+	return NewHost(conn, side), nil
+}
+
+// NewHost creates a host communicating with a remote player commanding given side.
+func NewHost(conn io.ReadWriteCloser, side int) *Host {
+	return &Host{
+		conn:      conn,
+		encoder:   gob.NewEncoder(conn),
+		decoder:   gob.NewDecoder(conn),
+		side:      side,
+		requests:  make(chan request, 16),
+		connected: true}
+}
+
+// RemoteSide returns side commanded by the remote player.
+func (h *Host) RemoteSide() int {
+	return h.side
+}
+
+// SendHello sends description of the game to the remote player.
+func (h *Host) SendHello(hello Hello) error {
+	hello.Version = ProtocolVersion
+	hello.Side = h.side
+	if err := h.encoder.Encode(hello); err != nil {
+		return fmt.Errorf("cannot send game description (%v)", err)
+	}
+	return nil
+}
+
+// Run passes updates sent by the game through gameSync to the local UI through uiSync
+// and to the remote player until the game or the UI stops.
+func (h *Host) Run(game HostGame, gameSync, uiSync *lib.MessageSync) {
+	defer h.conn.Close()
+	go h.readRequests()
+	if !uiSync.Wait() {
+		gameSync.Stop()
+		return
+	}
+	for {
+		message := gameSync.GetUpdate()
+		sent := false
+		if h.connected {
+			var err error
+			if sent, err = h.sendUpdate(game, message); err != nil {
+				h.disconnect(err)
+			}
+		}
+		if h.connectionError != nil {
+			connectionLost := ConnectionLost{h.connectionError}
+			h.connectionError = nil
+			if !uiSync.SendUpdate(connectionLost) {
+				gameSync.Stop()
+				return
+			}
+		}
+		if !uiSync.SendUpdate(message) {
+			gameSync.Stop()
+			return
+		}
+		if sent {
+			acked, stopped := h.waitForAck(game, uiSync)
+			if stopped {
+				gameSync.Stop()
+				return
+			}
+			if !acked {
+				h.disconnect(fmt.Errorf("connection closed"))
+			}
+		}
+	}
+}
+
+func (h *Host) sendUpdate(game HostGame, message interface{}) (sent bool, err error) {
+	var update update
+	var ok bool
+	if update.Message, ok = h.remoteMessage(game, message); !ok {
+		return false, nil
+	}
+	if message != nil {
+		var view bytes.Buffer
+		if err := game.WriteView(&view, h.side); err != nil {
+			return false, err
+		}
+		update.View = view.Bytes()
+	}
+	if err := h.encoder.Encode(update); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// remoteMessage returns the update as it should be sent to the remote player, or false if
+// the remote player must not be informed about it. Only updates listed here are ever sent,
+// with the information the remote side cannot see left out.
+func (h *Host) remoteMessage(game HostGame, message interface{}) (lib.EncodedMessage, bool) {
+	encoded, err := lib.EncodeMessage(message)
+	if err != nil {
+		return lib.EncodedMessage{}, false
+	}
+	switch message := message.(type) {
+	case nil, lib.Initialized, lib.GameOver, lib.TimeChanged, lib.WeatherForecast,
+		lib.SupplyDistributionStart, lib.SupplyDistributionEnd, lib.ScriptMessage, lib.ScriptError:
+		return encoded, true
+	case lib.UnitAttack:
+		// With two sides every attack is either made by or aimed at a unit of the remote side,
+		// so the remote side knows the location of the attacked unit.
+		return encoded, true
+	case lib.WeAreAttacking:
+		if message.Unit().Side == h.side {
+			encoded.Enemy = seenUnit(encoded.Enemy)
+			return encoded, true
+		}
+		if message.Enemy().Side == h.side {
+			encoded.Unit = seenUnit(encoded.Unit)
+			return encoded, true
+		}
+	case lib.MessageFromUnit:
+		return encoded, message.Unit().Side == h.side
+	case lib.UnitMove:
+		if message.Unit.Side == h.side {
+			return encoded, true
+		}
+		if game.IsUnitVisibleToSide(message.Unit, h.side) {
+			encoded.Unit = seenUnit(encoded.Unit)
+			return encoded, true
+		}
+	case lib.SupplyTruckMove:
+		return encoded, message.Side == h.side
+	case lib.Reinforcements:
+		encoded.Sides[1-h.side] = false
+		return encoded, message.Sides[h.side]
+	case lib.DailyUpdate:
+		encoded.SupplyLevels[1-h.side] = 0
+		return encoded, true
+	}
+	return lib.EncodedMessage{}, false
+}
+
+// seenUnit returns what is seen of an enemy unit on the map: its position, type and formation.
+func seenUnit(unit lib.Unit) lib.Unit {
+	return lib.Unit{
+		Side:         unit.Side,
+		Index:        unit.Index,
+		IsInGame:     unit.IsInGame,
+		XY:           unit.XY,
+		Type:         unit.Type,
+		ColorPalette: unit.ColorPalette,
+		Formation:    unit.Formation}
+}
+
+// waitForAck applies orders of the remote player until it acknowledges the last update.
+// In the meantime the local UI is sent empty updates, so that it stays responsive.
+// Both the game and the local UI are waiting whenever the orders are applied.
+func (h *Host) waitForAck(game HostGame, uiSync *lib.MessageSync) (acked, stopped bool) {
+	for {
+		select {
+		case request, ok := <-h.requests:
+			if !ok {
+				return false, false
+			}
+			if request.Order != nil && request.Order.Side == h.side {
+				// Invalid orders (e.g. for units which have been destroyed in the meantime) are ignored.
+				game.ApplyOrder(*request.Order)
+			}
+			if request.Ack {
+				return true, false
+			}
+		case <-time.After(idleTimeout):
+			if !uiSync.SendUpdate(nil) {
+				return false, true
+			}
+		}
+	}
+}
+
+func (h *Host) readRequests() {
+	defer close(h.requests)
+	for {
+		var request request
+		if err := h.decoder.Decode(&request); err != nil {
+			return
+		}
+		h.requests <- request
+	}
+}
+
+func (h *Host) disconnect(err error) {
+	h.connected = false
+	h.connectionError = err
+	h.conn.Close()
+}
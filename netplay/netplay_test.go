@@ -0,0 +1,188 @@
+package netplay
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+type fakeHostGame struct {
+	orders []lib.UnitOrder
+}
+
+func (g *fakeHostGame) ApplyOrder(order lib.UnitOrder) error {
+	g.orders = append(g.orders, order)
+	return nil
+}
+func (g *fakeHostGame) WriteView(writer io.Writer, side int) error {
+	_, err := writer.Write([]byte{byte(side)})
+	return err
+}
+func (g *fakeHostGame) IsUnitVisibleToSide(unit lib.Unit, side int) bool {
+	return unit.Side == side || unit.SeenByEnemy
+}
+
+type fakeClientGame struct {
+	views int
+}
+
+func (g *fakeClientGame) ReadView(reader io.Reader) error {
+	view, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if len(view) != 1 || view[0] != 1 {
+		return io.ErrUnexpectedEOF
+	}
+	g.views++
+	return nil
+}
+
+// readUntilGameOver reads updates until the game is over and then stops the sync.
+func readUntilGameOver(sync *lib.MessageSync, onUpdate func(interface{})) []interface{} {
+	var updates []interface{}
+	for {
+		update := sync.GetUpdate()
+		if update == nil {
+			continue
+		}
+		if onUpdate != nil {
+			onUpdate(update)
+		}
+		updates = append(updates, update)
+		if _, ok := update.(lib.GameOver); ok {
+			sync.Stop()
+			return updates
+		}
+	}
+}
+
+func TestLoopbackGame(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	hosts := make(chan *Host)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(hosts)
+			return
+		}
+		hosts <- NewHost(conn, 1)
+	}()
+	client, err := Join(listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, ok := <-hosts
+	if !ok {
+		t.Fatal("Cannot accept connection")
+	}
+	if err := host.SendHello(Hello{Scenario: 2, Variant: 1, Options: []byte{1, 2, 3}}); err != nil {
+		t.Fatal(err)
+	}
+	hello, err := client.ReadHello()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello.Scenario != 2 || hello.Variant != 1 || len(hello.Options) != 3 || client.Side() != 1 {
+		t.Fatalf("Unexpected game description %v", hello)
+	}
+
+	hostGame, clientGame := &fakeHostGame{}, &fakeClientGame{}
+	gameSync, hostSync, clientSync := lib.NewMessageSync(), lib.NewMessageSync(), lib.NewMessageSync()
+	hostDone := make(chan struct{})
+	go func() {
+		host.Run(hostGame, gameSync, hostSync)
+		close(hostDone)
+	}()
+	data := &lib.Data{Formations: []string{"MARCH"}}
+	go client.Run(clientGame, data, clientSync)
+	hostUnit := lib.Unit{Side: 0, Index: 6, IsInGame: true, XY: lib.UnitCoords{X: 4, Y: 4}, MenCount: 50, Objective: lib.UnitCoords{X: 8, Y: 2}}
+	remoteUnit := lib.Unit{Side: 1, Index: 7, IsInGame: true, XY: lib.UnitCoords{X: 6, Y: 4}, MenCount: 20}
+	attack, _ := lib.EncodedMessage{Type: "WeAreAttacking", Unit: hostUnit, Enemy: remoteUnit}.Decode(data)
+	contact, _ := lib.EncodedMessage{Type: "WeAreInContactWithEnemy", Unit: hostUnit}.Decode(data)
+	go func() {
+		if !gameSync.Wait() {
+			return
+		}
+		for _, update := range []interface{}{
+			lib.Initialized{},
+			lib.TimeChanged{},
+			lib.UnitMove{Unit: lib.Unit{Side: 0, Index: 3}},
+			lib.UnitMove{Unit: lib.Unit{Side: 0, Index: 4, SeenByEnemy: true}},
+			lib.UnitMove{Unit: lib.Unit{Side: 1, Index: 5}},
+			lib.SupplyTruckMove{Side: 0},
+			lib.SupplyTruckMove{Side: 1},
+			attack,
+			contact,
+			lib.Reinforcements{Sides: [2]bool{true, false}},
+			struct{}{},
+			lib.DailyUpdate{DaysRemaining: 3, SupplyLevels: [2]int{100, 200}},
+			lib.GameOver{}} {
+			if !gameSync.SendUpdate(update) {
+				return
+			}
+		}
+	}()
+
+	clientUpdates := make(chan []interface{})
+	go func() {
+		clientUpdates <- readUntilGameOver(clientSync, func(update interface{}) {
+			if _, ok := update.(lib.TimeChanged); ok {
+				client.SendOrder(lib.UnitOrder{Side: 1, Index: 2, Order: lib.Attack})
+				client.SendOrder(lib.UnitOrder{Side: 0, Index: 2, Order: lib.Attack})
+			}
+		})
+	}()
+	hostUpdates := readUntilGameOver(hostSync, nil)
+	<-hostDone
+	remoteUpdates := <-clientUpdates
+
+	if len(hostUpdates) != 13 {
+		t.Errorf("Expected 13 updates on the host, got %v", hostUpdates)
+	}
+	if len(remoteUpdates) != 8 {
+		t.Fatalf("Expected 8 updates on the client, got %v", remoteUpdates)
+	}
+	for _, update := range remoteUpdates {
+		switch update := update.(type) {
+		case lib.UnitMove:
+			if update.Unit.Index == 3 {
+				t.Error("Move of an invisible enemy unit has been sent to the client")
+			}
+		case lib.SupplyTruckMove:
+			if update.Side != 1 {
+				t.Error("Supply route of the enemy has been sent to the client")
+			}
+		case lib.WeAreAttacking:
+			if attacker := update.Unit(); attacker.MenCount != 0 || attacker.Objective != (lib.UnitCoords{}) || attacker.XY != hostUnit.XY {
+				t.Errorf("Expected only the position of the attacker to be sent, got %v", attacker)
+			}
+			if update.Enemy() != remoteUnit {
+				t.Errorf("Expected the attacked unit of the client, got %v", update.Enemy())
+			}
+		case lib.MessageFromUnit:
+			t.Errorf("Message from an enemy unit has been sent to the client %v", update)
+		case lib.Reinforcements:
+			t.Errorf("Reinforcements of the enemy have been sent to the client %v", update)
+		case lib.DailyUpdate:
+			if update.DaysRemaining != 3 || update.SupplyLevels != [2]int{0, 200} {
+				t.Errorf("Expected daily update without the enemy's supply level, got %v", update)
+			}
+		case lib.Initialized, lib.TimeChanged, lib.GameOver:
+		default:
+			t.Errorf("Unexpected update sent to the client %v", update)
+		}
+	}
+	if clientGame.views != 8 {
+		t.Errorf("Expected 8 game views, got %d", clientGame.views)
+	}
+	if len(hostGame.orders) != 1 || hostGame.orders[0].Side != 1 || hostGame.orders[0].Order != lib.Attack {
+		t.Errorf("Unexpected orders applied on the host %v", hostGame.orders)
+	}
+}
@@ -0,0 +1,60 @@
+// Package netplay lets two players play a game over the network.
+//
+// The host runs the game and relays updates sent by the game both to its own UI
+// and to the joining player. Every update sent to the joining player is accompanied
+// by the game state as seen by the joining player's side. The joining player sends
+// back orders for their units and acknowledges every update, so both players
+// progress through the game at the same pace.
+package netplay
+
+import (
+	"io"
+	"time"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// ProtocolVersion must be the same on both sides of the connection.
+const ProtocolVersion = 1
+
+// Time after which the UI is sent an empty update if nothing has been received from the other player.
+const idleTimeout = 50 * time.Millisecond
+
+// Hello is sent by the host to the joining player right after the connection is established.
+type Hello struct {
+	Version           int
+	Scenario, Variant int
+	// Side commanded by the joining player.
+	Side int
+	// Game options serialized with lib.Options.Write.
+	Options []byte
+}
+
+// update is sent by the host for every update of the game visible to the joining player.
+type update struct {
+	// Game state as seen by the joining player (nil if it didn't change).
+	View    []byte
+	Message lib.EncodedMessage
+}
+
+// request is sent by the joining player.
+type request struct {
+	Order *lib.UnitOrder
+	// Set when the previous update has been processed by the joining player.
+	Ack bool
+}
+
+// ConnectionLost is sent to the UI if the connection with the other player is broken.
+type ConnectionLost struct{ Err error }
+
+// HostGame is the part of the game state used by the host.
+type HostGame interface {
+	ApplyOrder(order lib.UnitOrder) error
+	WriteView(writer io.Writer, side int) error
+	IsUnitVisibleToSide(unit lib.Unit, side int) bool
+}
+
+// ClientGame is the part of the game state used by the joining player.
+type ClientGame interface {
+	ReadView(reader io.Reader) error
+}
@@ -0,0 +1,255 @@
+// Package pokey emulates sound generation of POKEY, the sound chip of Atari 8-bit computers.
+package pokey
+
+// Frequency of the machine clock driving POKEY in NTSC and PAL computers (in Hz).
+const (
+	NTSCClock = 1789790
+	PALClock  = 1773447
+)
+
+// Number of machine cycles per tick of the 64kHz and the 15kHz base clock.
+const (
+	cyclesPer64kHz = 28
+	cyclesPer15kHz = 114
+)
+
+// Bits of the AUDCTL register.
+const (
+	Poly9           = 0x80 // use 9-bit instead of 17-bit polynomial counter
+	Ch1MachineClock = 0x40 // clock channel 1 with the machine clock
+	Ch3MachineClock = 0x20 // clock channel 3 with the machine clock
+	Join12          = 0x10 // join channels 1 and 2 into a 16-bit channel 2
+	Join34          = 0x08 // join channels 3 and 4 into a 16-bit channel 4
+	HighPass13      = 0x04 // high-pass filter channel 1 by channel 3
+	HighPass24      = 0x02 // high-pass filter channel 2 by channel 4
+	Clock15kHz      = 0x01 // use 15kHz instead of 64kHz base clock
+)
+
+// Bits of the AUDC registers.
+const (
+	noPoly5    = 0x80 // don't gate the channel output by the 5-bit polynomial counter
+	poly4      = 0x40 // use 4-bit polynomial counter as the noise source
+	pureTone   = 0x20 // toggle the output instead of sampling the noise source
+	volumeOnly = 0x10 // output constant volume, bypassing the counters
+)
+
+// Pokey is an emulation of 4 sound channels of POKEY.
+// Channels are numbered from 0, so channel 0 is POKEY's channel 1 etc.
+type Pokey struct {
+	audf   [4]byte
+	audc   [4]byte
+	audctl byte
+
+	// Number of clock ticks left until every channel's counter expires.
+	counters [4]int
+	// Output flip-flops of the channels.
+	outputs [4]byte
+	// Flip-flops of the high-pass filters of channels 0 and 1.
+	highPass [2]byte
+	// Number of cycles left until the next tick of the base clock.
+	baseCounter int
+
+	poly4, poly5, poly9, poly17 uint32
+
+	clock, sampleRate int
+	// Fraction of a sample elapsed since the last generated sample (in cycles multiplied by sampleRate).
+	sampleCycles int
+}
+
+// New creates emulation of POKEY driven by given machine clock, generating samples at given rate.
+func New(clock, sampleRate int) *Pokey {
+	if clock <= 0 || sampleRate <= 0 {
+		panic("clock and sample rate must be positive")
+	}
+	return &Pokey{
+		poly9:      1<<9 - 1,
+		poly17:     1<<17 - 1,
+		clock:      clock,
+		sampleRate: sampleRate,
+	}
+}
+
+// SetAUDF sets the frequency register of the channel.
+func (p *Pokey) SetAUDF(channel int, value byte) {
+	p.audf[channel] = value
+}
+
+// SetAUDC sets the control register (distortion and volume) of the channel.
+func (p *Pokey) SetAUDC(channel int, value byte) {
+	p.audc[channel] = value
+}
+
+// SetAUDCTL sets the register controlling clocks, joining and filtering of the channels.
+func (p *Pokey) SetAUDCTL(value byte) {
+	p.audctl = value
+}
+
+// Step emulates a single machine cycle.
+func (p *Pokey) Step() {
+	p.stepPolys()
+	baseTick := false
+	p.baseCounter--
+	if p.baseCounter <= 0 {
+		baseTick = true
+		if p.audctl&Clock15kHz != 0 {
+			p.baseCounter = cyclesPer15kHz
+		} else {
+			p.baseCounter = cyclesPer64kHz
+		}
+	}
+	ch1Tick := baseTick || p.audctl&Ch1MachineClock != 0
+	ch3Tick := baseTick || p.audctl&Ch3MachineClock != 0
+	if p.audctl&Join12 != 0 {
+		if ch1Tick {
+			p.tick(1)
+		}
+	} else {
+		if ch1Tick {
+			p.tick(0)
+		}
+		if baseTick {
+			p.tick(1)
+		}
+	}
+	if p.audctl&Join34 != 0 {
+		if ch3Tick {
+			p.tick(3)
+		}
+	} else {
+		if ch3Tick {
+			p.tick(2)
+		}
+		if baseTick {
+			p.tick(3)
+		}
+	}
+}
+
+// tick counts down a tick of the clock of the channel.
+func (p *Pokey) tick(channel int) {
+	p.counters[channel]--
+	if p.counters[channel] > 0 {
+		return
+	}
+	p.counters[channel] = p.period(channel)
+	p.expire(channel)
+}
+
+// period returns number of clock ticks between expirations of the channel's counter.
+func (p *Pokey) period(channel int) int {
+	switch {
+	case channel == 1 && p.audctl&Join12 != 0:
+		period := int(p.audf[1])<<8 | int(p.audf[0])
+		if p.audctl&Ch1MachineClock != 0 {
+			return period + 7
+		}
+		return period + 1
+	case channel == 3 && p.audctl&Join34 != 0:
+		period := int(p.audf[3])<<8 | int(p.audf[2])
+		if p.audctl&Ch3MachineClock != 0 {
+			return period + 7
+		}
+		return period + 1
+	case channel == 0 && p.audctl&Ch1MachineClock != 0,
+		channel == 2 && p.audctl&Ch3MachineClock != 0:
+		return int(p.audf[channel]) + 4
+	}
+	return int(p.audf[channel]) + 1
+}
+
+// expire updates the output of the channel after its counter has expired.
+func (p *Pokey) expire(channel int) {
+	// Channels 2 and 3 clock the high-pass filters of channels 0 and 1.
+	if channel == 2 {
+		p.highPass[0] = p.outputs[0]
+	} else if channel == 3 {
+		p.highPass[1] = p.outputs[1]
+	}
+	audc := p.audc[channel]
+	if audc&noPoly5 == 0 && p.poly5&1 == 0 {
+		return
+	}
+	switch {
+	case audc&pureTone != 0:
+		p.outputs[channel] ^= 1
+	case audc&poly4 != 0:
+		p.outputs[channel] = byte(p.poly4 & 1)
+	case p.audctl&Poly9 != 0:
+		p.outputs[channel] = byte(p.poly9 & 1)
+	default:
+		p.outputs[channel] = byte(p.poly17 & 1)
+	}
+}
+
+// stepPolys advances polynomial counters, which are shifted every machine cycle.
+// The lowest bit of a counter is its output. Counters follow MAME's emulation of POKEY
+// (poly_init_4_5 and poly_init_9_17 in src/devices/sound/pokey.cpp).
+func (p *Pokey) stepPolys() {
+	p.poly4 = stepPoly45(p.poly4, 4)
+	p.poly5 = stepPoly45(p.poly5, 5)
+	p.poly9 = stepPoly9(p.poly9)
+	p.poly17 = stepPoly17(p.poly17)
+}
+
+// stepPoly45 shifts the 4 or 5-bit counter left, shifting in negated xor of bits 2 and n-1.
+func stepPoly45(value uint32, n int) uint32 {
+	bit := ^(value>>2 ^ value>>(n-1)) & 1
+	return (value<<1 | bit) & (1<<n - 1)
+}
+
+// stepPoly9 shifts the 9-bit counter right, shifting in xor of bits 0 and 5.
+func stepPoly9(value uint32) uint32 {
+	return value>>1 | (value^value>>5)&1<<8
+}
+
+// stepPoly17 rotates the 17-bit counter right, replacing bit 7 with xor of bits 8 and 13.
+func stepPoly17(value uint32) uint32 {
+	bit7 := (value>>8 ^ value>>13) & 1
+	return value>>1&^(1<<7) | bit7<<7 | value&1<<16
+}
+
+// Output returns the current output level of all channels (between 0 and 60).
+func (p *Pokey) Output() int {
+	output := 0
+	for channel := 0; channel < 4; channel++ {
+		audc := p.audc[channel]
+		volume := int(audc & 15)
+		if audc&volumeOnly != 0 {
+			output += volume
+			continue
+		}
+		if (channel == 0 && p.audctl&Join12 != 0) || (channel == 2 && p.audctl&Join34 != 0) {
+			// Lower halves of joined channels only count.
+			continue
+		}
+		bit := p.outputs[channel]
+		if channel == 0 && p.audctl&HighPass13 != 0 {
+			bit ^= p.highPass[0]
+		} else if channel == 1 && p.audctl&HighPass24 != 0 {
+			bit ^= p.highPass[1]
+		}
+		output += volume * int(bit)
+	}
+	return output
+}
+
+// Generate fills the buffer with samples of the output, each being an average
+// output level over the cycles it lasts, scaled to range [0, 1].
+func (p *Pokey) Generate(samples []float32) {
+	for i := range samples {
+		sum, cycles := 0, 0
+		for p.sampleCycles < p.clock {
+			p.Step()
+			sum += p.Output()
+			cycles++
+			p.sampleCycles += p.sampleRate
+		}
+		p.sampleCycles -= p.clock
+		if cycles == 0 {
+			// Sample rate is higher than the clock, repeat the current output.
+			samples[i] = float32(p.Output()) / 60
+		} else {
+			samples[i] = float32(sum) / float32(cycles*60)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package pokey
+
+import (
+	"testing"
+)
+
+func generate(p *Pokey, n int) []float32 {
+	samples := make([]float32, n)
+	p.Generate(samples)
+	return samples
+}
+
+// repeat returns the pattern repeated until it's n samples long, scaled by the volume.
+func repeat(pattern []float32, volume int, n int) []float32 {
+	samples := make([]float32, n)
+	for i := range samples {
+		samples[i] = pattern[i%len(pattern)] * float32(volume) / 60
+	}
+	return samples
+}
+
+func compareSamples(t *testing.T, name string, actual, expected []float32) {
+	t.Helper()
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Errorf("%s: unexpected sample %d: %f, expected %f", name, i, actual[i], expected[i])
+			return
+		}
+	}
+}
+
+func TestWaveforms(t *testing.T) {
+	for _, tc := range []struct {
+		name           string
+		clock, rate    int
+		audctl         byte
+		audf, audc     [4]byte
+		expected       []float32
+		expectedVolume int
+	}{
+		{
+			name:  "machine clock pure tone",
+			clock: 1000, rate: 1000,
+			audctl: Ch1MachineClock,
+			audc:   [4]byte{0xAF},
+			// Counter of the machine clocked channel expires every AUDF+4 cycles.
+			expected:       []float32{1, 1, 1, 1, 0, 0, 0, 0},
+			expectedVolume: 15,
+		},
+		{
+			name:  "averaged samples",
+			clock: 1000, rate: 500,
+			audctl:         Ch1MachineClock,
+			audc:           [4]byte{0xAF},
+			expected:       []float32{1, 1, 0, 0},
+			expectedVolume: 15,
+		},
+		{
+			name:  "64kHz pure tone",
+			clock: cyclesPer64kHz, rate: 1,
+			audf:           [4]byte{0, 1},
+			audc:           [4]byte{0, 0xA8},
+			expected:       []float32{1, 1, 0, 0},
+			expectedVolume: 8,
+		},
+		{
+			name:  "15kHz pure tone",
+			clock: cyclesPer15kHz, rate: 1,
+			audctl:         Clock15kHz,
+			audc:           [4]byte{0xA2},
+			expected:       []float32{1, 0},
+			expectedVolume: 2,
+		},
+		{
+			name:  "volume only",
+			clock: 1000, rate: 1000,
+			audf:           [4]byte{0, 0, 10},
+			audc:           [4]byte{0, 0, 0x15},
+			expected:       []float32{1},
+			expectedVolume: 5,
+		},
+		{
+			name:  "high-pass filter of equal frequencies",
+			clock: 1000, rate: 1000,
+			audctl:   HighPass13,
+			audf:     [4]byte{3, 0, 3},
+			audc:     [4]byte{0xAF, 0, 0xA0},
+			expected: []float32{0},
+		},
+		{
+			name:  "two channels",
+			clock: cyclesPer64kHz, rate: 1,
+			audf:           [4]byte{0, 0, 0, 1},
+			audc:           [4]byte{0xA2, 0, 0, 0xA1},
+			expected:       []float32{3, 1, 2, 0},
+			expectedVolume: 1,
+		},
+	} {
+		p := New(tc.clock, tc.rate)
+		p.SetAUDCTL(tc.audctl)
+		for channel := 0; channel < 4; channel++ {
+			p.SetAUDF(channel, tc.audf[channel])
+			p.SetAUDC(channel, tc.audc[channel])
+		}
+		expected := repeat(tc.expected, tc.expectedVolume, 64)
+		compareSamples(t, tc.name, generate(p, len(expected)), expected)
+	}
+}
+
+func TestJoinedChannels(t *testing.T) {
+	p := New(1000, 1000)
+	p.SetAUDCTL(Join12 | Ch1MachineClock)
+	p.SetAUDF(0, 0x02)
+	p.SetAUDF(1, 0x01)
+	// Volume of the lower half of joined channels is ignored.
+	p.SetAUDC(0, 0xAF)
+	p.SetAUDC(1, 0xA4)
+	samples := generate(p, 1000)
+	// 16-bit counter clocked by the machine clock expires every AUDF+7 cycles.
+	const halfPeriod = 0x102 + 7
+	for i, sample := range samples {
+		expected := float32(0)
+		if (i/halfPeriod)%2 == 0 {
+			expected = 4. / 60
+		}
+		if sample != expected {
+			t.Fatalf("Unexpected sample %d: %f, expected %f", i, sample, expected)
+		}
+	}
+}
+
+func TestPolynomialCounterPeriods(t *testing.T) {
+	for _, tc := range []struct {
+		n     int
+		step  func(uint32) uint32
+		start uint32
+	}{
+		{4, func(value uint32) uint32 { return stepPoly45(value, 4) }, 0},
+		{5, func(value uint32) uint32 { return stepPoly45(value, 5) }, 0},
+		{9, stepPoly9, 1<<9 - 1},
+		{17, stepPoly17, 1<<17 - 1},
+	} {
+		value := tc.step(tc.start)
+		period := 1
+		for ; value != tc.start && period <= 1<<tc.n; period++ {
+			value = tc.step(value)
+		}
+		if period != 1<<tc.n-1 {
+			t.Errorf("Unexpected period of %d-bit polynomial counter %d, expected %d", tc.n, period, 1<<tc.n-1)
+		}
+	}
+}
+
+// Output bits of the polynomial counters in the first 64 cycles after reset,
+// as generated by MAME's emulation of POKEY (the lowest bits of values in tables
+// filled by poly_init_4_5 and poly_init_9_17 in src/devices/sound/pokey.cpp).
+var referencePolyOutputs = map[string]string{
+	"poly4":  "1110110010100001110110010100001110110010100001110110010100001110",
+	"poly5":  "1110010001010111101101001100000111001000101011110110100110000011",
+	"poly9":  "1111111100001111011100001011001101101111010000111001100001001000",
+	"poly17": "1111111000000000000111110000000111111111100111110000011000111111",
+}
+
+func TestPolynomialCounterReference(t *testing.T) {
+	p := New(NTSCClock, 44100)
+	outputs := make(map[string][]byte)
+	for i := 0; i < 64; i++ {
+		p.stepPolys()
+		outputs["poly4"] = append(outputs["poly4"], '0'+byte(p.poly4&1))
+		outputs["poly5"] = append(outputs["poly5"], '0'+byte(p.poly5&1))
+		outputs["poly9"] = append(outputs["poly9"], '0'+byte(p.poly9&1))
+		outputs["poly17"] = append(outputs["poly17"], '0'+byte(p.poly17&1))
+	}
+	for name, expected := range referencePolyOutputs {
+		if string(outputs[name]) != expected {
+			t.Errorf("Unexpected output of %s\n%s, expected\n%s", name, outputs[name], expected)
+		}
+	}
+}
+
+func TestPoly4Noise(t *testing.T) {
+	p := New(1000, 1000)
+	p.SetAUDCTL(Ch1MachineClock)
+	p.SetAUDC(0, noPoly5|poly4|15)
+	samples := generate(p, 600)
+	// The counter expires every 4 cycles, so it samples all 15 states of the polynomial
+	// counter before the output repeats.
+	const period = 4 * 15
+	highSamples := 0
+	for i, sample := range samples {
+		if i >= period && sample != samples[i-period] {
+			t.Fatalf("Expected output to repeat every %d samples, sample %d differs", period, i)
+		}
+		if sample != 0 {
+			highSamples++
+		}
+	}
+	// 7 of 15 states of the counter have the lowest bit set (it never is in the state 1111).
+	if highSamples != len(samples)*7/15 {
+		t.Errorf("Unexpected number of high samples %d", highSamples)
+	}
+}
+
+func TestPoly5Gating(t *testing.T) {
+	pure := New(1000, 1000)
+	gated := New(1000, 1000)
+	for _, p := range []*Pokey{pure, gated} {
+		p.SetAUDCTL(Ch1MachineClock)
+	}
+	pure.SetAUDC(0, noPoly5|pureTone|15)
+	gated.SetAUDC(0, pureTone|15)
+	pureSamples, gatedSamples := generate(pure, 1000), generate(gated, 1000)
+	pureToggles, gatedToggles := 0, 0
+	for i := 1; i < 1000; i++ {
+		if pureSamples[i] != pureSamples[i-1] {
+			pureToggles++
+		}
+		if gatedSamples[i] != gatedSamples[i-1] {
+			gatedToggles++
+		}
+	}
+	if gatedToggles == 0 || gatedToggles >= pureToggles {
+		t.Errorf("Expected the 5-bit polynomial counter to skip some toggles, got %d toggles of %d", gatedToggles, pureToggles)
+	}
+}
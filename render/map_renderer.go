@@ -0,0 +1,209 @@
+// Package render draws the map, cities and units of the game into plain images,
+// without the user interface, so that it can be used e.g. by command line tools.
+package render
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// Palette of all Atari colors, in which the images are rendered.
+var Palette = func() color.Palette {
+	palette := make(color.Palette, 256)
+	for i := range palette {
+		palette[i] = lib.RGBPalette[i]
+	}
+	return palette
+}()
+
+// MapRenderer draws the same tiles and sprites as the user interface,
+// with pixels twice as wide as high like on the Atari.
+type MapRenderer struct {
+	terrainMap             *lib.Map
+	minX, minY, maxX, maxY int // map bounds to draw in map coordinates
+	sprites                *lib.Sprites
+	icons                  *lib.Icons
+	colors                 *lib.ColorSchemes
+	tileWidth, tileHeight  int
+	zoomX, zoomY           int
+	// Indices in Palette of background and foreground colors of every color scheme.
+	colorIndices [2][4]*[2]uint8
+}
+
+func NewMapRenderer(
+	terrainMap *lib.Map,
+	minX, minY, maxX, maxY int,
+	sprites *lib.Sprites,
+	icons *lib.Icons,
+	daytimePalette *[8]byte,
+	nightPalette *[8]byte) *MapRenderer {
+	tileBounds := sprites.TerrainTiles[0].Bounds()
+	return &MapRenderer{
+		terrainMap: terrainMap,
+		minX:       minX,
+		minY:       minY,
+		maxX:       maxX,
+		maxY:       maxY,
+		sprites:    sprites,
+		icons:      icons,
+		colors:     lib.NewColorSchemes(daytimePalette, nightPalette),
+		tileWidth:  tileBounds.Dx(),
+		tileHeight: tileBounds.Dy(),
+		zoomX:      2,
+		zoomY:      1}
+}
+
+// Bounds returns bounds of the image of the whole map.
+func (r *MapRenderer) Bounds() image.Rectangle {
+	width := (r.maxX-r.minX+1)*r.tileWidth + r.tileWidth/2
+	height := (r.maxY - r.minY + 1) * r.tileHeight
+	return image.Rect(0, 0, width*r.zoomX, height*r.zoomY)
+}
+
+// NewImage returns an image of the size of the map to draw on.
+func (r *MapRenderer) NewImage() *image.Paletted {
+	return image.NewPaletted(r.Bounds(), Palette)
+}
+
+func (r *MapRenderer) MapCoordsToImageCoords(mapXY lib.MapCoords) (x, y int) {
+	x = ((mapXY.X-r.minX)*r.tileWidth + (mapXY.Y%2)*r.tileWidth/2) * r.zoomX
+	y = (mapXY.Y - r.minY) * r.tileHeight * r.zoomY
+	return
+}
+
+// DrawMap draws the terrain over the whole image.
+func (r *MapRenderer) DrawMap(img *image.Paletted, isNight bool) {
+	background := uint8(Palette.Index(r.colors.GetMapBackgroundColor(isNight)))
+	for i := range img.Pix {
+		img.Pix[i] = background
+	}
+	for y := r.minY; y <= r.maxY; y++ {
+		if y >= r.terrainMap.Height {
+			break
+		}
+		for x := r.minX; x <= r.maxX; x++ {
+			if x >= r.terrainMap.Width-y%2 {
+				break
+			}
+			xy := lib.MapCoords{X: x, Y: y}
+			tileNum := r.terrainMap.GetTile(xy)
+			if tileNum&63 >= 48 {
+				continue
+			}
+			imageX, imageY := r.MapCoordsToImageCoords(xy)
+			r.drawSprite(img, r.sprites.TerrainTiles[tileNum%64], tileNum/64, isNight, imageX, imageY)
+		}
+	}
+}
+
+// DrawUnit draws a unit of given type and color palette as a symbol or an icon at xy.
+func (r *MapRenderer) DrawUnit(img *image.Paletted, unitType, colorPalette int, unitDisplay lib.UnitDisplay, xy lib.UnitCoords, isNight bool) {
+	tileNum := byte(unitType + colorPalette*16)
+	sprite := r.sprites.UnitSymbolSprites[tileNum%16]
+	if unitDisplay == lib.ShowAsIcons {
+		sprite = r.sprites.UnitIconSprites[tileNum%16]
+	}
+	x, y := r.MapCoordsToImageCoords(xy.ToMapCoords())
+	r.drawSprite(img, sprite, tileNum/64, isNight, x, y)
+}
+
+// Colors of cities owned by each side, the same as of the sides in the user interface.
+var sideColors = [2]uint8{
+	uint8(Palette.Index(color.RGBA{R: 0x30, G: 0x60, B: 0xff, A: 0xff})),
+	uint8(Palette.Index(color.RGBA{R: 0xff, G: 0x30, B: 0x30, A: 0xff}))}
+
+// Color of icons and the cursor.
+var iconColor = uint8(Palette.Index(color.White))
+
+// DrawCities frames tiles of the cities with colors of sides owning them.
+func (r *MapRenderer) DrawCities(img *image.Paletted, cities []lib.City) {
+	width, height := r.tileWidth*r.zoomX, r.tileHeight*r.zoomY
+	for _, city := range cities {
+		if city.Owner < 0 || city.Owner > 1 {
+			continue
+		}
+		x, y := r.MapCoordsToImageCoords(city.XY.ToMapCoords())
+		index := sideColors[city.Owner]
+		for dx := 0; dx < width; dx++ {
+			img.SetColorIndex(x+dx, y, index)
+			img.SetColorIndex(x+dx, y+height-1, index)
+		}
+		for dy := 0; dy < height; dy++ {
+			img.SetColorIndex(x, y+dy, index)
+			img.SetColorIndex(x+1, y+dy, index)
+			img.SetColorIndex(x+width-2, y+dy, index)
+			img.SetColorIndex(x+width-1, y+dy, index)
+		}
+	}
+}
+
+// DrawIcon draws the icon over the tile at xy, moved by dx, dy (in Atari pixels).
+func (r *MapRenderer) DrawIcon(img *image.Paletted, icon lib.IconType, xy lib.MapCoords, dx, dy int) {
+	x, y := r.MapCoordsToImageCoords(xy)
+	r.drawIcon(img, r.icons.Sprites[icon], 1, x+dx*r.zoomX, y+dy*r.zoomY)
+}
+
+// DrawCursor draws the cursor over the tile at xy, the way the user interface does.
+func (r *MapRenderer) DrawCursor(img *image.Paletted, xy lib.MapCoords) {
+	x, y := r.MapCoordsToImageCoords(xy)
+	// Cursor must be offset by 6,2 and scaled 2,1 to match the tile size and position.
+	r.drawIcon(img, r.icons.Sprites[lib.Cursor], 2, x-6*r.zoomX, y-2*r.zoomY)
+}
+
+// drawIcon draws non transparent pixels of the icon, additionally stretched horizontally by scaleX.
+func (r *MapRenderer) drawIcon(img *image.Paletted, icon *image.Paletted, scaleX, x, y int) {
+	r.drawPixels(img, icon, scaleX, x, y, func(colorIndex uint8) (uint8, bool) {
+		return iconColor, colorIndex != 0
+	})
+}
+
+// drawSprite draws a two color sprite using colors of the color scheme with its upper left corner at x, y.
+func (r *MapRenderer) drawSprite(img *image.Paletted, sprite *image.Paletted, colorScheme byte, isNight bool, x, y int) {
+	indices := r.getColorIndices(colorScheme, isNight)
+	r.drawPixels(img, sprite, 1, x, y, func(colorIndex uint8) (uint8, bool) {
+		return indices[colorIndex&1], true
+	})
+}
+
+// drawPixels draws pixels of the sprite for which paletteIndex returns true, using the returned index in Palette.
+func (r *MapRenderer) drawPixels(img *image.Paletted, sprite *image.Paletted, scaleX, x, y int, paletteIndex func(colorIndex uint8) (uint8, bool)) {
+	bounds := sprite.Bounds()
+	zoomX := r.zoomX * scaleX
+	for sy := bounds.Min.Y; sy < bounds.Max.Y; sy++ {
+		for sx := bounds.Min.X; sx < bounds.Max.X; sx++ {
+			index, ok := paletteIndex(sprite.ColorIndexAt(sx, sy))
+			if !ok {
+				continue
+			}
+			for dy := 0; dy < r.zoomY; dy++ {
+				for dx := 0; dx < zoomX; dx++ {
+					img.SetColorIndex(x+(sx-bounds.Min.X)*zoomX+dx, y+(sy-bounds.Min.Y)*r.zoomY+dy, index)
+				}
+			}
+		}
+	}
+}
+
+func (r *MapRenderer) getColorIndices(colorScheme byte, isNight bool) *[2]uint8 {
+	isNightIx := 0
+	if isNight {
+		isNightIx = 1
+	}
+	indices := r.colorIndices[isNightIx][colorScheme]
+	if indices == nil {
+		colors := r.colors.GetBackgroundForegroundColors(colorScheme, isNight)
+		indices = &[2]uint8{uint8(Palette.Index(colors[0])), uint8(Palette.Index(colors[1]))}
+		r.colorIndices[isNightIx][colorScheme] = indices
+	}
+	return indices
+}
+
+// DrawFlashbackDay draws the map with units at their positions at the end of the flashback day.
+func (r *MapRenderer) DrawFlashbackDay(img *image.Paletted, day lib.FlashbackDay, unitDisplay lib.UnitDisplay) {
+	r.DrawMap(img, false)
+	for _, unit := range day.Units {
+		r.DrawUnit(img, unit.Type, unit.ColorPalette, unitDisplay, unit.XY, false)
+	}
+}
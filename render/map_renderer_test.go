@@ -0,0 +1,103 @@
+package render
+
+import (
+	"bytes"
+	"image"
+	"testing"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// testSprites returns sprites with only the upper left pixel in the foreground color.
+func testSprites() *lib.Sprites {
+	sprites := &lib.Sprites{}
+	newSprite := func() *image.Paletted {
+		sprite := image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		sprite.Pix[0] = 1
+		return sprite
+	}
+	for i := range sprites.TerrainTiles {
+		sprites.TerrainTiles[i] = newSprite()
+	}
+	for i := range sprites.UnitSymbolSprites {
+		sprites.UnitSymbolSprites[i] = newSprite()
+		sprites.UnitIconSprites[i] = newSprite()
+	}
+	return sprites
+}
+
+func TestMapRenderer(t *testing.T) {
+	// Rows of the map are alternately 3 and 2 tiles long.
+	terrainMap, err := lib.ParseMap(bytes.NewReader([]byte{0, 1, 2, 3, 4}), 3, 2)
+	if err != nil {
+		t.Fatal("Cannot parse map,", err)
+	}
+	daytimePalette := [8]byte{10, 20, 30, 40, 50, 60, 70, 80}
+	nightPalette := [8]byte{11, 21, 31, 41, 51, 61, 71, 81}
+	renderer := NewMapRenderer(terrainMap, 0, 0, 2, 1, testSprites(), &lib.Icons{}, &daytimePalette, &nightPalette)
+	if bounds := renderer.Bounds(); bounds != image.Rect(0, 0, (3*8+4)*2, 2*8) {
+		t.Fatalf("Unexpected bounds %v", bounds)
+	}
+	img := renderer.NewImage()
+	renderer.DrawMap(img, false)
+	colors := lib.NewColorSchemes(&daytimePalette, &nightPalette)
+	background := uint8(Palette.Index(colors.GetMapBackgroundColor(false)))
+	foreground := uint8(Palette.Index(colors.GetBackgroundForegroundColors(0, false)[1]))
+	// Pixels are twice as wide as high.
+	if img.ColorIndexAt(0, 0) != foreground || img.ColorIndexAt(1, 0) != foreground || img.ColorIndexAt(2, 0) != background {
+		t.Errorf("Unexpected colors of the first tile %d %d %d",
+			img.ColorIndexAt(0, 0), img.ColorIndexAt(1, 0), img.ColorIndexAt(2, 0))
+	}
+	// Odd rows are shifted by half a tile.
+	if img.ColorIndexAt(8, 8) != foreground {
+		t.Errorf("Expected foreground color at the first tile of the second row, got %d", img.ColorIndexAt(8, 8))
+	}
+
+	unitsImg := renderer.NewImage()
+	renderer.DrawFlashbackDay(unitsImg, lib.FlashbackDay{Units: lib.FlashbackUnits{
+		{XY: lib.UnitCoords{X: 2, Y: 0}, Type: 1, ColorPalette: 4}}}, lib.ShowAsSymbols)
+	unitForeground := uint8(Palette.Index(colors.GetBackgroundForegroundColors(1, false)[1]))
+	if unitsImg.ColorIndexAt(16, 0) != unitForeground {
+		t.Errorf("Expected unit color %d, got %d", unitForeground, unitsImg.ColorIndexAt(16, 0))
+	}
+}
+
+func TestMapRendererCitiesAndCursor(t *testing.T) {
+	terrainMap, err := lib.ParseMap(bytes.NewReader([]byte{0, 0, 0, 0, 0}), 3, 2)
+	if err != nil {
+		t.Fatal("Cannot parse map,", err)
+	}
+	icons := &lib.Icons{}
+	for i := range icons.Sprites {
+		// Icons are transparent, except for the upper left pixel.
+		icons.Sprites[i] = image.NewPaletted(image.Rect(0, 0, 8, 8), nil)
+		icons.Sprites[i].Pix[0] = 1
+	}
+	var palette [8]byte
+	renderer := NewMapRenderer(terrainMap, 0, 0, 2, 1, testSprites(), icons, &palette, &palette)
+	img := renderer.NewImage()
+	renderer.DrawMap(img, false)
+	mapImg := renderer.NewImage()
+	copy(mapImg.Pix, img.Pix)
+
+	renderer.DrawCities(img, []lib.City{{Owner: 1, XY: lib.UnitCoords{X: 2, Y: 0}}})
+	if img.ColorIndexAt(16, 0) != sideColors[1] || img.ColorIndexAt(31, 7) != sideColors[1] {
+		t.Error("Expected city tile to be framed with the color of its owner")
+	}
+	if img.ColorIndexAt(20, 4) != mapImg.ColorIndexAt(20, 4) {
+		t.Error("Unexpected change inside of the city tile")
+	}
+
+	copy(mapImg.Pix, img.Pix)
+	renderer.DrawCursor(img, lib.MapCoords{X: 1, Y: 1})
+	// The cursor is drawn 6,2 pixels up and left from the tile and twice as wide.
+	x, y := (8+4-6)*2, 8-2
+	for dx := 0; dx < 4; dx++ {
+		if img.ColorIndexAt(x+dx, y) != iconColor {
+			t.Errorf("Expected cursor color at %d,%d", x+dx, y)
+		}
+	}
+	if img.ColorIndexAt(x+4, y) != mapImg.ColorIndexAt(x+4, y) {
+		t.Error("Transparent pixels of the cursor must not be drawn")
+	}
+}
@@ -0,0 +1,314 @@
+package sid
+
+import "math"
+
+// Frequency of the clock driving the SID chip in PAL and NTSC Commodore 64 (in Hz).
+const (
+	PALClock  = 985248
+	NTSCClock = 1022727
+)
+
+// Bits of the control registers of the voices.
+const (
+	gate     = 0x01
+	syncBit  = 0x02
+	ringMod  = 0x04
+	test     = 0x08
+	triangle = 0x10
+	sawtooth = 0x20
+	pulse    = 0x40
+	noise    = 0x80
+)
+
+// Number of clock cycles per step of the envelope counter for every attack rate.
+// Decay and release rates are three times slower.
+var envelopeRatePeriods = [16]int{9, 32, 63, 95, 149, 220, 267, 313, 392, 977, 1954, 3126, 3907, 11720, 19532, 31251}
+
+type envelopeState int
+
+const (
+	attack envelopeState = iota
+	decaySustain
+	release
+)
+
+type voice struct {
+	frequency                   uint16
+	pulseWidth                  uint16
+	control                     byte
+	attackDecay, sustainRelease byte
+
+	// 24-bit phase accumulator of the oscillator.
+	accumulator uint32
+	// 23-bit shift register generating noise.
+	noiseRegister uint32
+	// Set if the most significant bit of the accumulator has just risen.
+	msbRising bool
+
+	envelopeState   envelopeState
+	envelope        int
+	envelopeCounter int
+}
+
+// output returns current output of the waveform generator (between 0 and 0xFFF).
+// The ring modulating voice is the one syncing this voice.
+func (v *voice) output(ringModulator *voice) int {
+	if v.control&0xF0 == 0 {
+		return 0x800
+	}
+	output := 0xFFF
+	if v.control&triangle != 0 {
+		msb := v.accumulator & 0x800000
+		if v.control&ringMod != 0 {
+			msb ^= ringModulator.accumulator & 0x800000
+		}
+		tri := int(v.accumulator>>11) & 0xFFF
+		if msb != 0 {
+			tri ^= 0xFFF
+		}
+		output &= tri
+	}
+	if v.control&sawtooth != 0 {
+		output &= int(v.accumulator >> 12)
+	}
+	if v.control&pulse != 0 {
+		if v.control&test == 0 && int(v.accumulator>>12) < int(v.pulseWidth) {
+			output = 0
+		}
+	}
+	if v.control&noise != 0 {
+		r := v.noiseRegister
+		output &= int((r>>20&1)<<11 | (r>>18&1)<<10 | (r>>14&1)<<9 | (r>>11&1)<<8 |
+			(r>>9&1)<<7 | (r>>5&1)<<6 | (r>>2&1)<<5 | (r&1)<<4)
+	}
+	return output
+}
+
+// clockOscillator advances the oscillator by given number of clock cycles.
+func (v *voice) clockOscillator(cycles int) {
+	v.msbRising = false
+	if v.control&test != 0 {
+		v.accumulator = 0
+		return
+	}
+	prev := uint64(v.accumulator)
+	next := prev + uint64(v.frequency)*uint64(cycles)
+	v.msbRising = (next+0x800000)>>24 != (prev+0x800000)>>24
+	// The noise register is clocked whenever bit 19 of the accumulator rises.
+	noiseClocks := (next+0x80000)>>20 - (prev+0x80000)>>20
+	for i := uint64(0); i < noiseClocks && i < 23; i++ {
+		bit := (v.noiseRegister>>22 ^ v.noiseRegister>>17) & 1
+		v.noiseRegister = (v.noiseRegister<<1 | bit) & 0x7FFFFF
+	}
+	v.accumulator = uint32(next & 0xFFFFFF)
+}
+
+// clockEnvelope advances the envelope generator by given number of clock cycles.
+func (v *voice) clockEnvelope(cycles int) {
+	v.envelopeCounter += cycles
+	for {
+		var period int
+		switch v.envelopeState {
+		case attack:
+			period = envelopeRatePeriods[v.attackDecay>>4]
+		case decaySustain:
+			period = envelopeRatePeriods[v.attackDecay&15] * 3
+		case release:
+			period = envelopeRatePeriods[v.sustainRelease&15] * 3
+		}
+		if v.envelopeState != attack {
+			// Decay and release are exponential, they slow down as the envelope gets lower.
+			period *= exponentialDivider(v.envelope)
+		}
+		if v.envelopeCounter < period {
+			return
+		}
+		v.envelopeCounter -= period
+		switch v.envelopeState {
+		case attack:
+			v.envelope++
+			if v.envelope >= 0xFF {
+				v.envelope = 0xFF
+				v.envelopeState = decaySustain
+			}
+		case decaySustain:
+			if v.envelope > int(v.sustainRelease>>4)*0x11 {
+				v.envelope--
+			}
+		case release:
+			if v.envelope > 0 {
+				v.envelope--
+			}
+		}
+	}
+}
+
+func exponentialDivider(envelope int) int {
+	switch {
+	case envelope >= 93:
+		return 1
+	case envelope >= 54:
+		return 2
+	case envelope >= 26:
+		return 4
+	case envelope >= 14:
+		return 8
+	case envelope >= 6:
+		return 16
+	}
+	return 30
+}
+
+func (v *voice) setControl(control byte) {
+	if control&gate != 0 && v.control&gate == 0 {
+		v.envelopeState = attack
+	} else if control&gate == 0 && v.control&gate != 0 {
+		v.envelopeState = release
+	}
+	v.control = control
+}
+
+// Chip is an emulation of the SID sound chip of Commodore 64.
+type Chip struct {
+	voices [3]voice
+
+	filterCutoff    uint16
+	filterResonance byte
+	// Bits of voices passed through the filter.
+	filterVoices byte
+	// Bits of the filter modes and the main volume.
+	modeVolume byte
+
+	// State of the filter.
+	lowPass, bandPass float64
+
+	clock, sampleRate int
+	// Fraction of a sample elapsed since the last generated sample (in cycles multiplied by sampleRate).
+	sampleCycles int
+}
+
+// NewChip creates emulation of SID driven by given clock, generating samples at given rate.
+func NewChip(clock, sampleRate int) *Chip {
+	if clock <= 0 || sampleRate <= 0 {
+		panic("clock and sample rate must be positive")
+	}
+	c := &Chip{clock: clock, sampleRate: sampleRate}
+	for i := range c.voices {
+		c.voices[i].noiseRegister = 0x7FFFF8
+		c.voices[i].envelopeState = release
+	}
+	return c
+}
+
+// Write sets value of the register (0x00-0x18).
+func (c *Chip) Write(register byte, value byte) {
+	if register < 21 {
+		v := &c.voices[register/7]
+		switch register % 7 {
+		case 0:
+			v.frequency = v.frequency&0xFF00 | uint16(value)
+		case 1:
+			v.frequency = v.frequency&0x00FF | uint16(value)<<8
+		case 2:
+			v.pulseWidth = v.pulseWidth&0xF00 | uint16(value)
+		case 3:
+			v.pulseWidth = v.pulseWidth&0x0FF | uint16(value&15)<<8
+		case 4:
+			v.setControl(value)
+		case 5:
+			v.attackDecay = value
+		case 6:
+			v.sustainRelease = value
+		}
+		return
+	}
+	switch register {
+	case 0x15:
+		c.filterCutoff = c.filterCutoff&0x7F8 | uint16(value&7)
+	case 0x16:
+		c.filterCutoff = c.filterCutoff&7 | uint16(value)<<3
+	case 0x17:
+		c.filterResonance = value >> 4
+		c.filterVoices = value & 15
+	case 0x18:
+		c.modeVolume = value
+	}
+}
+
+// Read returns value of the register. Only the output of the oscillator
+// and the envelope of the third voice (registers 0x1B and 0x1C) are readable.
+func (c *Chip) Read(register byte) byte {
+	switch register {
+	case 0x1B:
+		return byte(c.voices[2].output(&c.voices[1]) >> 4)
+	case 0x1C:
+		return byte(c.voices[2].envelope)
+	}
+	return 0
+}
+
+// Generate fills the buffer with samples of the output, in range [-1, 1].
+func (c *Chip) Generate(samples []float32) {
+	for i := range samples {
+		cycles := 0
+		for c.sampleCycles < c.clock {
+			cycles++
+			c.sampleCycles += c.sampleRate
+		}
+		c.sampleCycles -= c.clock
+		samples[i] = c.clockSample(cycles)
+	}
+}
+
+// clockSample advances the chip by given number of clock cycles and returns its output.
+func (c *Chip) clockSample(cycles int) float32 {
+	for i := range c.voices {
+		c.voices[i].clockOscillator(cycles)
+		c.voices[i].clockEnvelope(cycles)
+	}
+	// Voice i is synchronized (and ring modulated) by the voice i-1.
+	for i := range c.voices {
+		v, source := &c.voices[i], &c.voices[(i+2)%3]
+		if v.control&syncBit != 0 && source.msbRising {
+			v.accumulator = 0
+		}
+	}
+	var direct, filtered float64
+	for i := range c.voices {
+		v := &c.voices[i]
+		output := float64(v.output(&c.voices[(i+2)%3])-0x800) * float64(v.envelope) / 0xFF
+		if c.filterVoices&(1<<i) != 0 {
+			filtered += output
+		} else if i != 2 || c.modeVolume&0x80 == 0 {
+			// The third voice may be muted, if it's not filtered.
+			direct += output
+		}
+	}
+	direct += c.filter(filtered)
+	volume := float64(c.modeVolume&15) / 15
+	return float32(direct * volume / (3 * 0x800))
+}
+
+// filter passes the input through a state variable filter approximating the filter of SID.
+func (c *Chip) filter(input float64) float64 {
+	if c.modeVolume&0x70 == 0 {
+		return 0
+	}
+	cutoff := 30 + float64(c.filterCutoff)*5.8
+	f := 2 * math.Sin(math.Pi*math.Min(cutoff, float64(c.sampleRate)/6)/float64(c.sampleRate))
+	q := 1.4 - float64(c.filterResonance)/15
+	highPass := input - c.lowPass - q*c.bandPass
+	c.bandPass += f * highPass
+	c.lowPass += f * c.bandPass
+	var output float64
+	if c.modeVolume&0x10 != 0 {
+		output += c.lowPass
+	}
+	if c.modeVolume&0x20 != 0 {
+		output += c.bandPass
+	}
+	if c.modeVolume&0x40 != 0 {
+		output += highPass
+	}
+	return output
+}
@@ -0,0 +1,426 @@
+package sid
+
+import "fmt"
+
+// Memory is the address space of the CPU.
+type Memory interface {
+	Read(addr uint16) byte
+	Write(addr uint16, value byte)
+}
+
+// Flags of the processor status register.
+const (
+	flagC = 0x01
+	flagZ = 0x02
+	flagI = 0x04
+	flagD = 0x08
+	flagB = 0x10
+	flagU = 0x20
+	flagV = 0x40
+	flagN = 0x80
+)
+
+type addressingMode int
+
+const (
+	implied addressingMode = iota
+	accumulator
+	immediate
+	zeroPage
+	zeroPageX
+	zeroPageY
+	absolute
+	absoluteX
+	absoluteY
+	indirect
+	indirectX
+	indirectY
+	relative
+)
+
+type instruction struct {
+	name    string
+	mode    addressingMode
+	execute func(c *CPU, mode addressingMode, addr uint16)
+}
+
+// CPU is an emulation of the documented instruction set of the 6502 processor
+// (6510 in the Commodore 64).
+type CPU struct {
+	A, X, Y, S, P byte
+	PC            uint16
+	Memory        Memory
+}
+
+// NewCPU creates a CPU with the stack pointer and flags set as after a reset.
+func NewCPU(memory Memory) *CPU {
+	return &CPU{S: 0xFF, P: flagU | flagI, Memory: memory}
+}
+
+// Step executes a single instruction.
+func (c *CPU) Step() error {
+	opcode := c.Memory.Read(c.PC)
+	instr := &instructions[opcode]
+	if instr.execute == nil {
+		return fmt.Errorf("unsupported opcode 0x%02X at 0x%04X", opcode, c.PC)
+	}
+	c.PC++
+	addr := c.operandAddress(instr.mode)
+	instr.execute(c, instr.mode, addr)
+	return nil
+}
+
+func (c *CPU) read16(addr uint16) uint16 {
+	return uint16(c.Memory.Read(addr)) | uint16(c.Memory.Read(addr+1))<<8
+}
+
+// read16ZeroPage reads a pointer from the zero page, wrapping around within it.
+func (c *CPU) read16ZeroPage(addr byte) uint16 {
+	return uint16(c.Memory.Read(uint16(addr))) | uint16(c.Memory.Read(uint16(addr+1)))<<8
+}
+
+// operandAddress reads the operand of the instruction and returns the address it refers to.
+func (c *CPU) operandAddress(mode addressingMode) uint16 {
+	var addr uint16
+	switch mode {
+	case implied, accumulator:
+		return 0
+	case immediate:
+		addr = c.PC
+		c.PC++
+	case zeroPage:
+		addr = uint16(c.Memory.Read(c.PC))
+		c.PC++
+	case zeroPageX:
+		addr = uint16(c.Memory.Read(c.PC) + c.X)
+		c.PC++
+	case zeroPageY:
+		addr = uint16(c.Memory.Read(c.PC) + c.Y)
+		c.PC++
+	case absolute:
+		addr = c.read16(c.PC)
+		c.PC += 2
+	case absoluteX:
+		addr = c.read16(c.PC) + uint16(c.X)
+		c.PC += 2
+	case absoluteY:
+		addr = c.read16(c.PC) + uint16(c.Y)
+		c.PC += 2
+	case indirect:
+		pointer := c.read16(c.PC)
+		c.PC += 2
+		// The 6502 doesn't carry to the high byte of the pointer when reading the target address.
+		hi := pointer&0xFF00 | uint16(byte(pointer)+1)
+		addr = uint16(c.Memory.Read(pointer)) | uint16(c.Memory.Read(hi))<<8
+	case indirectX:
+		addr = c.read16ZeroPage(c.Memory.Read(c.PC) + c.X)
+		c.PC++
+	case indirectY:
+		addr = c.read16ZeroPage(c.Memory.Read(c.PC)) + uint16(c.Y)
+		c.PC++
+	case relative:
+		offset := int8(c.Memory.Read(c.PC))
+		c.PC++
+		addr = uint16(int(c.PC) + int(offset))
+	}
+	return addr
+}
+
+func (c *CPU) load(mode addressingMode, addr uint16) byte {
+	if mode == accumulator {
+		return c.A
+	}
+	return c.Memory.Read(addr)
+}
+func (c *CPU) store(mode addressingMode, addr uint16, value byte) {
+	if mode == accumulator {
+		c.A = value
+		return
+	}
+	c.Memory.Write(addr, value)
+}
+
+func (c *CPU) setFlag(flag byte, value bool) {
+	if value {
+		c.P |= flag
+	} else {
+		c.P &^= flag
+	}
+}
+func (c *CPU) setZN(value byte) {
+	c.setFlag(flagZ, value == 0)
+	c.setFlag(flagN, value&0x80 != 0)
+}
+
+func (c *CPU) push(value byte) {
+	c.Memory.Write(0x100|uint16(c.S), value)
+	c.S--
+}
+func (c *CPU) pull() byte {
+	c.S++
+	return c.Memory.Read(0x100 | uint16(c.S))
+}
+func (c *CPU) push16(value uint16) {
+	c.push(byte(value >> 8))
+	c.push(byte(value))
+}
+func (c *CPU) pull16() uint16 {
+	lo := c.pull()
+	return uint16(lo) | uint16(c.pull())<<8
+}
+
+func (c *CPU) addWithCarry(value byte) {
+	carry := uint16(c.P & flagC)
+	if c.P&flagD != 0 {
+		lo := uint16(c.A&0x0F) + uint16(value&0x0F) + carry
+		if lo > 9 {
+			lo += 6
+		}
+		hi := uint16(c.A>>4) + uint16(value>>4)
+		if lo > 0x0F {
+			hi++
+		}
+		binary := uint16(c.A) + uint16(value) + carry
+		c.setFlag(flagZ, byte(binary) == 0)
+		c.setFlag(flagN, hi&0x08 != 0)
+		c.setFlag(flagV, (c.A^value)&0x80 == 0 && (uint16(c.A)^(hi<<4))&0x80 != 0)
+		if hi > 9 {
+			hi += 6
+		}
+		c.setFlag(flagC, hi > 0x0F)
+		c.A = byte(hi<<4 | lo&0x0F)
+		return
+	}
+	sum := uint16(c.A) + uint16(value) + carry
+	c.setFlag(flagC, sum > 0xFF)
+	c.setFlag(flagV, (c.A^value)&0x80 == 0 && (uint16(c.A)^sum)&0x80 != 0)
+	c.A = byte(sum)
+	c.setZN(c.A)
+}
+
+func (c *CPU) subtractWithBorrow(value byte) {
+	borrow := 1 - int(c.P&flagC)
+	diff := int(c.A) - int(value) - borrow
+	result := byte(diff)
+	// Flags are set as in the binary mode also in the decimal mode.
+	c.setFlag(flagV, (c.A^value)&0x80 != 0 && (c.A^result)&0x80 != 0)
+	c.setZN(result)
+	if c.P&flagD != 0 {
+		lo := int(c.A&0x0F) - int(value&0x0F) - borrow
+		hi := int(c.A>>4) - int(value>>4)
+		if lo < 0 {
+			lo -= 6
+			hi--
+		}
+		if hi < 0 {
+			hi -= 6
+		}
+		result = byte(hi<<4 | lo&0x0F)
+	}
+	c.setFlag(flagC, diff >= 0)
+	c.A = result
+}
+
+func (c *CPU) compare(register, value byte) {
+	c.setFlag(flagC, register >= value)
+	c.setZN(register - value)
+}
+
+func (c *CPU) branch(condition bool, addr uint16) {
+	if condition {
+		c.PC = addr
+	}
+}
+
+func adc(c *CPU, mode addressingMode, addr uint16) { c.addWithCarry(c.load(mode, addr)) }
+func sbc(c *CPU, mode addressingMode, addr uint16) { c.subtractWithBorrow(c.load(mode, addr)) }
+func and(c *CPU, mode addressingMode, addr uint16) { c.A &= c.load(mode, addr); c.setZN(c.A) }
+func ora(c *CPU, mode addressingMode, addr uint16) { c.A |= c.load(mode, addr); c.setZN(c.A) }
+func eor(c *CPU, mode addressingMode, addr uint16) { c.A ^= c.load(mode, addr); c.setZN(c.A) }
+func cmp(c *CPU, mode addressingMode, addr uint16) { c.compare(c.A, c.load(mode, addr)) }
+func cpx(c *CPU, mode addressingMode, addr uint16) { c.compare(c.X, c.load(mode, addr)) }
+func cpy(c *CPU, mode addressingMode, addr uint16) { c.compare(c.Y, c.load(mode, addr)) }
+func lda(c *CPU, mode addressingMode, addr uint16) { c.A = c.load(mode, addr); c.setZN(c.A) }
+func ldx(c *CPU, mode addressingMode, addr uint16) { c.X = c.load(mode, addr); c.setZN(c.X) }
+func ldy(c *CPU, mode addressingMode, addr uint16) { c.Y = c.load(mode, addr); c.setZN(c.Y) }
+func sta(c *CPU, mode addressingMode, addr uint16) { c.store(mode, addr, c.A) }
+func stx(c *CPU, mode addressingMode, addr uint16) { c.store(mode, addr, c.X) }
+func sty(c *CPU, mode addressingMode, addr uint16) { c.store(mode, addr, c.Y) }
+func bit(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr)
+	c.setFlag(flagZ, c.A&value == 0)
+	c.setFlag(flagN, value&0x80 != 0)
+	c.setFlag(flagV, value&0x40 != 0)
+}
+func asl(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr)
+	c.setFlag(flagC, value&0x80 != 0)
+	value <<= 1
+	c.store(mode, addr, value)
+	c.setZN(value)
+}
+func lsr(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr)
+	c.setFlag(flagC, value&1 != 0)
+	value >>= 1
+	c.store(mode, addr, value)
+	c.setZN(value)
+}
+func rol(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr)
+	carry := c.P & flagC
+	c.setFlag(flagC, value&0x80 != 0)
+	value = value<<1 | carry
+	c.store(mode, addr, value)
+	c.setZN(value)
+}
+func ror(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr)
+	carry := c.P & flagC
+	c.setFlag(flagC, value&1 != 0)
+	value = value>>1 | carry<<7
+	c.store(mode, addr, value)
+	c.setZN(value)
+}
+func inc(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr) + 1
+	c.store(mode, addr, value)
+	c.setZN(value)
+}
+func dec(c *CPU, mode addressingMode, addr uint16) {
+	value := c.load(mode, addr) - 1
+	c.store(mode, addr, value)
+	c.setZN(value)
+}
+func inx(c *CPU, _ addressingMode, _ uint16)    { c.X++; c.setZN(c.X) }
+func iny(c *CPU, _ addressingMode, _ uint16)    { c.Y++; c.setZN(c.Y) }
+func dex(c *CPU, _ addressingMode, _ uint16)    { c.X--; c.setZN(c.X) }
+func dey(c *CPU, _ addressingMode, _ uint16)    { c.Y--; c.setZN(c.Y) }
+func tax(c *CPU, _ addressingMode, _ uint16)    { c.X = c.A; c.setZN(c.X) }
+func tay(c *CPU, _ addressingMode, _ uint16)    { c.Y = c.A; c.setZN(c.Y) }
+func txa(c *CPU, _ addressingMode, _ uint16)    { c.A = c.X; c.setZN(c.A) }
+func tya(c *CPU, _ addressingMode, _ uint16)    { c.A = c.Y; c.setZN(c.A) }
+func tsx(c *CPU, _ addressingMode, _ uint16)    { c.X = c.S; c.setZN(c.X) }
+func txs(c *CPU, _ addressingMode, _ uint16)    { c.S = c.X }
+func pha(c *CPU, _ addressingMode, _ uint16)    { c.push(c.A) }
+func php(c *CPU, _ addressingMode, _ uint16)    { c.push(c.P | flagB | flagU) }
+func pla(c *CPU, _ addressingMode, _ uint16)    { c.A = c.pull(); c.setZN(c.A) }
+func plp(c *CPU, _ addressingMode, _ uint16)    { c.P = c.pull()&^flagB | flagU }
+func clc(c *CPU, _ addressingMode, _ uint16)    { c.P &^= flagC }
+func sec(c *CPU, _ addressingMode, _ uint16)    { c.P |= flagC }
+func cli(c *CPU, _ addressingMode, _ uint16)    { c.P &^= flagI }
+func sei(c *CPU, _ addressingMode, _ uint16)    { c.P |= flagI }
+func cld(c *CPU, _ addressingMode, _ uint16)    { c.P &^= flagD }
+func sed(c *CPU, _ addressingMode, _ uint16)    { c.P |= flagD }
+func clv(c *CPU, _ addressingMode, _ uint16)    { c.P &^= flagV }
+func nop(c *CPU, _ addressingMode, _ uint16)    {}
+func jmp(c *CPU, _ addressingMode, addr uint16) { c.PC = addr }
+func jsr(c *CPU, _ addressingMode, addr uint16) {
+	c.push16(c.PC - 1)
+	c.PC = addr
+}
+func rts(c *CPU, _ addressingMode, _ uint16) { c.PC = c.pull16() + 1 }
+func rti(c *CPU, _ addressingMode, _ uint16) {
+	c.P = c.pull()&^flagB | flagU
+	c.PC = c.pull16()
+}
+func brk(c *CPU, _ addressingMode, _ uint16) {
+	c.push16(c.PC + 1)
+	c.push(c.P | flagB | flagU)
+	c.P |= flagI
+	c.PC = c.read16(0xFFFE)
+}
+func bcc(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagC == 0, addr) }
+func bcs(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagC != 0, addr) }
+func bne(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagZ == 0, addr) }
+func beq(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagZ != 0, addr) }
+func bpl(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagN == 0, addr) }
+func bmi(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagN != 0, addr) }
+func bvc(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagV == 0, addr) }
+func bvs(c *CPU, _ addressingMode, addr uint16) { c.branch(c.P&flagV != 0, addr) }
+
+var instructions [256]instruction
+
+func init() {
+	// Opcodes of instructions reading or modifying memory, in the order of addressing modes:
+	// immediate, zero page, zero page,X, absolute, absolute,X, absolute,Y, (indirect,X), (indirect),Y.
+	type opcodes [8]int
+	const none = -1
+	modes := [8]addressingMode{immediate, zeroPage, zeroPageX, absolute, absoluteX, absoluteY, indirectX, indirectY}
+	add := func(name string, execute func(*CPU, addressingMode, uint16), codes opcodes) {
+		for i, code := range codes {
+			if code != none {
+				instructions[code] = instruction{name, modes[i], execute}
+			}
+		}
+	}
+	add("ADC", adc, opcodes{0x69, 0x65, 0x75, 0x6D, 0x7D, 0x79, 0x61, 0x71})
+	add("AND", and, opcodes{0x29, 0x25, 0x35, 0x2D, 0x3D, 0x39, 0x21, 0x31})
+	add("CMP", cmp, opcodes{0xC9, 0xC5, 0xD5, 0xCD, 0xDD, 0xD9, 0xC1, 0xD1})
+	add("EOR", eor, opcodes{0x49, 0x45, 0x55, 0x4D, 0x5D, 0x59, 0x41, 0x51})
+	add("LDA", lda, opcodes{0xA9, 0xA5, 0xB5, 0xAD, 0xBD, 0xB9, 0xA1, 0xB1})
+	add("ORA", ora, opcodes{0x09, 0x05, 0x15, 0x0D, 0x1D, 0x19, 0x01, 0x11})
+	add("SBC", sbc, opcodes{0xE9, 0xE5, 0xF5, 0xED, 0xFD, 0xF9, 0xE1, 0xF1})
+	add("STA", sta, opcodes{none, 0x85, 0x95, 0x8D, 0x9D, 0x99, 0x81, 0x91})
+	add("ASL", asl, opcodes{none, 0x06, 0x16, 0x0E, 0x1E, none, none, none})
+	add("LSR", lsr, opcodes{none, 0x46, 0x56, 0x4E, 0x5E, none, none, none})
+	add("ROL", rol, opcodes{none, 0x26, 0x36, 0x2E, 0x3E, none, none, none})
+	add("ROR", ror, opcodes{none, 0x66, 0x76, 0x6E, 0x7E, none, none, none})
+	add("INC", inc, opcodes{none, 0xE6, 0xF6, 0xEE, 0xFE, none, none, none})
+	add("DEC", dec, opcodes{none, 0xC6, 0xD6, 0xCE, 0xDE, none, none, none})
+	add("LDY", ldy, opcodes{0xA0, 0xA4, 0xB4, 0xAC, 0xBC, none, none, none})
+	add("STY", sty, opcodes{none, 0x84, 0x94, 0x8C, none, none, none, none})
+	add("CPX", cpx, opcodes{0xE0, 0xE4, none, 0xEC, none, none, none, none})
+	add("CPY", cpy, opcodes{0xC0, 0xC4, none, 0xCC, none, none, none, none})
+	add("BIT", bit, opcodes{none, 0x24, none, 0x2C, none, none, none, none})
+	// LDX and STX index zero page and absolute addresses with Y instead of X.
+	add("LDX", ldx, opcodes{0xA2, 0xA6, none, 0xAE, none, 0xBE, none, none})
+	add("STX", stx, opcodes{none, 0x86, none, 0x8E, none, none, none, none})
+	instructions[0xB6] = instruction{"LDX", zeroPageY, ldx}
+	instructions[0x96] = instruction{"STX", zeroPageY, stx}
+
+	for code, instr := range map[byte]instruction{
+		0x0A: {"ASL", accumulator, asl},
+		0x4A: {"LSR", accumulator, lsr},
+		0x2A: {"ROL", accumulator, rol},
+		0x6A: {"ROR", accumulator, ror},
+		0x4C: {"JMP", absolute, jmp},
+		0x6C: {"JMP", indirect, jmp},
+		0x20: {"JSR", absolute, jsr},
+		0x60: {"RTS", implied, rts},
+		0x40: {"RTI", implied, rti},
+		0x00: {"BRK", implied, brk},
+		0x90: {"BCC", relative, bcc},
+		0xB0: {"BCS", relative, bcs},
+		0xD0: {"BNE", relative, bne},
+		0xF0: {"BEQ", relative, beq},
+		0x10: {"BPL", relative, bpl},
+		0x30: {"BMI", relative, bmi},
+		0x50: {"BVC", relative, bvc},
+		0x70: {"BVS", relative, bvs},
+		0xE8: {"INX", implied, inx},
+		0xC8: {"INY", implied, iny},
+		0xCA: {"DEX", implied, dex},
+		0x88: {"DEY", implied, dey},
+		0xAA: {"TAX", implied, tax},
+		0xA8: {"TAY", implied, tay},
+		0x8A: {"TXA", implied, txa},
+		0x98: {"TYA", implied, tya},
+		0xBA: {"TSX", implied, tsx},
+		0x9A: {"TXS", implied, txs},
+		0x48: {"PHA", implied, pha},
+		0x08: {"PHP", implied, php},
+		0x68: {"PLA", implied, pla},
+		0x28: {"PLP", implied, plp},
+		0x18: {"CLC", implied, clc},
+		0x38: {"SEC", implied, sec},
+		0x58: {"CLI", implied, cli},
+		0x78: {"SEI", implied, sei},
+		0xD8: {"CLD", implied, cld},
+		0xF8: {"SED", implied, sed},
+		0xB8: {"CLV", implied, clv},
+		0xEA: {"NOP", implied, nop},
+	} {
+		instructions[code] = instr
+	}
+}
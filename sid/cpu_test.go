@@ -0,0 +1,112 @@
+package sid
+
+import "testing"
+
+type testMemory [0x10000]byte
+
+func (m *testMemory) Read(addr uint16) byte         { return m[addr] }
+func (m *testMemory) Write(addr uint16, value byte) { m[addr] = value }
+
+// runProgram runs the program loaded at 0x1000 until it executes BRK.
+func runProgram(t *testing.T, program []byte) (*CPU, *testMemory) {
+	t.Helper()
+	memory := &testMemory{}
+	copy(memory[0x1000:], program)
+	memory[0xFFFE], memory[0xFFFF] = 0x00, 0xF0
+	cpu := NewCPU(memory)
+	cpu.PC = 0x1000
+	for i := 0; cpu.PC != 0xF000; i++ {
+		if i > 100000 {
+			t.Fatal("Program didn't finish")
+		}
+		if err := cpu.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cpu, memory
+}
+
+func TestCPULoop(t *testing.T) {
+	// Sums numbers 1..10 using a subroutine.
+	cpu, memory := runProgram(t, []byte{
+		0xA9, 0x00, // LDA #0
+		0xA2, 0x0A, // LDX #10
+		0x20, 0x0D, 0x10, // loop: JSR add
+		0xCA,       // DEX
+		0xD0, 0xFA, // BNE loop
+		0x85, 0x80, // STA $80
+		0x00,       // BRK
+		0x86, 0x81, // add: STX $81
+		0x18,       // CLC
+		0x65, 0x81, // ADC $81
+		0x60, // RTS
+	})
+	if memory[0x80] != 55 {
+		t.Errorf("Expected sum 55, got %d", memory[0x80])
+	}
+	if cpu.S != 0xFF-3 {
+		t.Errorf("Unexpected stack pointer 0x%02X", cpu.S)
+	}
+}
+
+func TestCPUAddressingModes(t *testing.T) {
+	_, memory := runProgram(t, []byte{
+		0xA9, 0x00, // LDA #$00
+		0x85, 0x10, // STA $10
+		0xA9, 0x20, // LDA #$20
+		0x85, 0x11, // STA $11  ; pointer $2000 at $10
+		0xA0, 0x05, // LDY #5
+		0xA9, 0x42, // LDA #$42
+		0x91, 0x10, // STA ($10),Y  ; $2005
+		0xA2, 0x02, // LDX #2
+		0xA1, 0x0E, // LDA ($0E,X)  ; pointer at $10 -> $2000
+		0x8D, 0x00, 0x30, // STA $3000
+		0xBD, 0x03, 0x20, // LDA $2003,X  ; $2005
+		0x99, 0xFF, 0x2F, // STA $2FFF,Y  ; $3004
+		0x6C, 0xFF, 0x20, // JMP ($20FF)
+	})
+	if memory[0x2005] != 0x42 {
+		t.Errorf("Expected indirect indexed store, got 0x%02X", memory[0x2005])
+	}
+	if memory[0x3004] != 0x42 {
+		t.Errorf("Expected absolute indexed load and store, got 0x%02X", memory[0x3004])
+	}
+	// JMP ($20FF) reads the target address from $20FF and $2000, which aren't set,
+	// so it jumps to 0x0000 where BRK is.
+}
+
+func TestCPUDecimalMode(t *testing.T) {
+	cpu, _ := runProgram(t, []byte{
+		0xF8,       // SED
+		0x18,       // CLC
+		0xA9, 0x19, // LDA #$19
+		0x69, 0x28, // ADC #$28
+		0x85, 0x80, // STA $80
+		0x38,       // SEC
+		0xE9, 0x09, // SBC #$09
+		0xD8, // CLD
+		0x00, // BRK
+	})
+	if cpu.A != 0x38 {
+		t.Errorf("Expected 19+28-9=38 in decimal mode, got %02X", cpu.A)
+	}
+}
+
+func TestCPUFlags(t *testing.T) {
+	cpu, memory := runProgram(t, []byte{
+		0xA9, 0x7F, // LDA #$7F
+		0x69, 0x01, // ADC #$01  ; overflow
+		0x08,       // PHP
+		0x68,       // PLA
+		0x85, 0x80, // STA $80
+		0xA9, 0x10, // LDA #$10
+		0xC9, 0x20, // CMP #$20
+		0x00, // BRK
+	})
+	if memory[0x80]&(flagV|flagN) != flagV|flagN || memory[0x80]&flagC != 0 {
+		t.Errorf("Unexpected flags after overflow 0x%02X", memory[0x80])
+	}
+	if cpu.P&flagC != 0 {
+		t.Error("Expected carry to be cleared when comparing with a greater value")
+	}
+}
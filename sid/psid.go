@@ -0,0 +1,264 @@
+// Package sid plays Commodore 64 music stored in PSID files, emulating the 6502 processor
+// running the player routine of the tune and the SID sound chip.
+package sid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Tune is a tune read from a PSID file.
+type Tune struct {
+	Name, Author, Released string
+	// Number of songs in the tune and the default one (counting from 1).
+	Songs, StartSong int
+	LoadAddress      uint16
+	InitAddress      uint16
+	// Address of the routine to be called every frame, 0 if the tune installs an interrupt handler.
+	PlayAddress uint16
+	// Bit i is set if the song i+1 is timed by CIA timer instead of the vertical blank interrupt.
+	Speed uint32
+	// Set if the tune was made for NTSC machines.
+	NTSC bool
+	Data []byte
+}
+
+type psidHeader struct {
+	Magic       [4]byte
+	Version     uint16
+	DataOffset  uint16
+	LoadAddress uint16
+	InitAddress uint16
+	PlayAddress uint16
+	Songs       uint16
+	StartSong   uint16
+	Speed       uint32
+	Name        [32]byte
+	Author      [32]byte
+	Released    [32]byte
+}
+
+// Offset of the flags in the header of version 2 and newer.
+const psidFlagsOffset = 0x76
+
+var ErrNotPSID = errors.New("not a PSID file")
+
+// ParseTune parses contents of a PSID file.
+func ParseTune(data []byte) (*Tune, error) {
+	var header psidHeader
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &header); err != nil {
+		return nil, ErrNotPSID
+	}
+	switch string(header.Magic[:]) {
+	case "PSID":
+	case "RSID":
+		return nil, fmt.Errorf("RSID tunes need full emulation of Commodore 64, only PSID tunes are supported")
+	default:
+		return nil, ErrNotPSID
+	}
+	if header.Version < 1 || header.Version > 4 {
+		return nil, fmt.Errorf("unsupported PSID version %d", header.Version)
+	}
+	if int(header.DataOffset) > len(data) || header.DataOffset < psidFlagsOffset {
+		return nil, fmt.Errorf("invalid data offset %d", header.DataOffset)
+	}
+	tune := &Tune{
+		Name:        cString(header.Name[:]),
+		Author:      cString(header.Author[:]),
+		Released:    cString(header.Released[:]),
+		Songs:       int(header.Songs),
+		StartSong:   int(header.StartSong),
+		LoadAddress: header.LoadAddress,
+		InitAddress: header.InitAddress,
+		PlayAddress: header.PlayAddress,
+		Speed:       header.Speed,
+		Data:        data[header.DataOffset:],
+	}
+	if header.Version >= 2 && header.DataOffset >= psidFlagsOffset+2 {
+		flags := binary.BigEndian.Uint16(data[psidFlagsOffset:])
+		// Bits 2-3 are the video standard: 1 - PAL, 2 - NTSC.
+		tune.NTSC = flags>>2&3 == 2
+	}
+	if tune.LoadAddress == 0 {
+		// The load address is stored in the first two bytes of the data, like in C64 program files.
+		if len(tune.Data) < 2 {
+			return nil, fmt.Errorf("missing load address")
+		}
+		tune.LoadAddress = uint16(tune.Data[0]) | uint16(tune.Data[1])<<8
+		tune.Data = tune.Data[2:]
+	}
+	if int(tune.LoadAddress)+len(tune.Data) > 0x10000 {
+		return nil, fmt.Errorf("tune data doesn't fit in the memory")
+	}
+	if tune.InitAddress == 0 {
+		tune.InitAddress = tune.LoadAddress
+	}
+	if tune.Songs < 1 {
+		return nil, fmt.Errorf("no songs in the tune")
+	}
+	if tune.StartSong < 1 || tune.StartSong > tune.Songs {
+		tune.StartSong = 1
+	}
+	return tune, nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// Addresses of stubs of the kernal interrupt handler.
+const (
+	kernalIRQ       = 0xFF48
+	kernalIRQReturn = 0xEA31
+	kernalIRQExit   = 0xEA81
+)
+
+// Address the CPU returns to after finishing a called routine.
+// It's at the end of the kernal ROM, which PSID tunes don't use.
+const returnAddress = 0xFFF8
+
+// Maximum number of instructions executed by the init and play routines.
+const (
+	maxInitInstructions = 10000000
+	maxPlayInstructions = 1000000
+)
+
+// memory is the address space of Commodore 64, with SID mapped at 0xD400-0xD7FF.
+type memory struct {
+	ram    [0x10000]byte
+	chip   *Chip
+	raster byte
+}
+
+func (m *memory) Read(addr uint16) byte {
+	switch {
+	case addr >= 0xD400 && addr < 0xD800:
+		return m.chip.Read(byte(addr & 0x1F))
+	case addr == 0xD012:
+		// Tunes may wait for the raster line to change.
+		m.raster++
+		return m.raster
+	}
+	return m.ram[addr]
+}
+func (m *memory) Write(addr uint16, value byte) {
+	if addr >= 0xD400 && addr < 0xD800 {
+		m.chip.Write(byte(addr&0x1F), value)
+		return
+	}
+	m.ram[addr] = value
+}
+
+// Player plays a song of a tune.
+type Player struct {
+	tune   *Tune
+	cpu    *CPU
+	memory *memory
+	chip   *Chip
+
+	// Address of the routine called every frame.
+	playAddress uint16
+	// If set, the play routine is an interrupt handler.
+	playIRQ bool
+	// Number of samples per frame multiplied by the frame rate.
+	frameSamples, frameRate int
+	// Number of samples left until the next frame multiplied by the frame rate.
+	samplesLeft int
+}
+
+// NewPlayer loads the tune and initializes the song (counting from 1) for playing
+// at given sample rate.
+func NewPlayer(tune *Tune, song int, sampleRate int) (*Player, error) {
+	if song < 1 || song > tune.Songs {
+		return nil, fmt.Errorf("song number must be between 1 and %d", tune.Songs)
+	}
+	clock := PALClock
+	if tune.NTSC {
+		clock = NTSCClock
+	}
+	p := &Player{tune: tune, chip: NewChip(clock, sampleRate)}
+	p.memory = &memory{chip: p.chip}
+	ram := p.memory.ram[:]
+	// Default memory configuration with BASIC, kernal and I/O visible.
+	ram[0x01] = 0x37
+	// Kernal interrupt handler calls the handler stored in 0x0314, which by default
+	// returns through 0xEA31, restoring registers.
+	copy(ram[kernalIRQ:], []byte{0x48, 0x8A, 0x48, 0x98, 0x48, 0x6C, 0x14, 0x03})       // PHA TXA PHA TYA PHA JMP ($0314)
+	copy(ram[kernalIRQReturn:], []byte{0x4C, kernalIRQExit & 0xFF, kernalIRQExit >> 8}) // JMP $EA81
+	copy(ram[kernalIRQExit:], []byte{0x68, 0xA8, 0x68, 0xAA, 0x68, 0x40})               // PLA TAY PLA TAX PLA RTI
+	binary.LittleEndian.PutUint16(ram[0x0314:], kernalIRQReturn)
+	binary.LittleEndian.PutUint16(ram[0xFFFE:], kernalIRQ)
+	copy(ram[tune.LoadAddress:], tune.Data)
+	p.cpu = NewCPU(p.memory)
+
+	p.cpu.A = byte(song - 1)
+	if err := p.call(tune.InitAddress, false, maxInitInstructions); err != nil {
+		return nil, fmt.Errorf("error initializing song %d (%v)", song, err)
+	}
+	p.playAddress = tune.PlayAddress
+	if p.playAddress == 0 {
+		p.playIRQ = true
+		if ram[0x01]&2 != 0 {
+			p.playAddress = kernalIRQ
+		} else {
+			// Kernal is not visible, the tune installed its handler directly in the interrupt vector.
+			p.playAddress = binary.LittleEndian.Uint16(ram[0xFFFE:])
+		}
+	}
+	p.frameRate = 50
+	if tune.NTSC {
+		p.frameRate = 60
+	}
+	if tune.Speed&(1<<min(song-1, 31)) != 0 {
+		// Timed by CIA 1 timer A, 60Hz unless the tune changed the timer.
+		p.frameRate = 60
+		if timer := int(binary.LittleEndian.Uint16(ram[0xDC04:])); timer != 0 {
+			p.frameRate = clock / timer
+		}
+	}
+	p.frameSamples = sampleRate
+	return p, nil
+}
+
+// call runs the routine at given address until it returns.
+func (p *Player) call(addr uint16, irq bool, maxInstructions int) error {
+	if irq {
+		p.cpu.push16(returnAddress)
+		p.cpu.push(p.cpu.P)
+		p.cpu.P |= flagI
+	} else {
+		p.cpu.push16(returnAddress - 1)
+	}
+	p.cpu.PC = addr
+	for i := 0; i < maxInstructions; i++ {
+		if p.cpu.PC == returnAddress {
+			return nil
+		}
+		if err := p.cpu.Step(); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("routine at 0x%04X didn't return", addr)
+}
+
+// Generate fills the buffer with samples of the song, in range [-1, 1].
+func (p *Player) Generate(samples []float32) error {
+	for len(samples) > 0 {
+		if p.samplesLeft <= 0 {
+			if err := p.call(p.playAddress, p.playIRQ, maxPlayInstructions); err != nil {
+				return fmt.Errorf("error playing song (%v)", err)
+			}
+			p.samplesLeft += p.frameSamples
+		}
+		n := min(len(samples), (p.samplesLeft+p.frameRate-1)/p.frameRate)
+		p.chip.Generate(samples[:n])
+		p.samplesLeft -= n * p.frameRate
+		samples = samples[n:]
+	}
+	return nil
+}
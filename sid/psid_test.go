@@ -0,0 +1,106 @@
+package sid
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// makePSID returns a PSID file of version 2 with the code loaded at 0x1000.
+func makePSID(initAddress, playAddress uint16, code []byte) []byte {
+	data := make([]byte, 0x7C)
+	copy(data, "PSID")
+	binary.BigEndian.PutUint16(data[4:], 2)
+	binary.BigEndian.PutUint16(data[6:], 0x7C)
+	binary.BigEndian.PutUint16(data[8:], 0x1000)
+	binary.BigEndian.PutUint16(data[10:], initAddress)
+	binary.BigEndian.PutUint16(data[12:], playAddress)
+	binary.BigEndian.PutUint16(data[14:], 1)
+	binary.BigEndian.PutUint16(data[16:], 1)
+	copy(data[0x16:], "Test tune")
+	return append(data, code...)
+}
+
+// Init routine playing a sawtooth of 440Hz on the first voice at full volume.
+var initSawtooth = []byte{
+	0xA9, 0x45, 0x8D, 0x00, 0xD4, // LDA #$45, STA $D400
+	0xA9, 0x1D, 0x8D, 0x01, 0xD4, // LDA #$1D, STA $D401
+	0xA9, 0x00, 0x8D, 0x05, 0xD4, // LDA #$00, STA $D405 ; attack 2ms, decay 6ms
+	0xA9, 0xF0, 0x8D, 0x06, 0xD4, // LDA #$F0, STA $D406 ; full sustain
+	0xA9, 0x0F, 0x8D, 0x18, 0xD4, // LDA #$0F, STA $D418 ; full volume
+	0xA9, 0x21, 0x8D, 0x04, 0xD4, // LDA #$21, STA $D404 ; sawtooth, gate
+	0x60, // RTS
+}
+
+func TestParseTune(t *testing.T) {
+	tune, err := ParseTune(makePSID(0x1000, 0x1100, initSawtooth))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tune.Name != "Test tune" || tune.LoadAddress != 0x1000 || tune.Songs != 1 || tune.StartSong != 1 {
+		t.Errorf("Unexpected tune %+v", tune)
+	}
+	// Files cut by tools/splitter contain programs of the games, not music.
+	if _, err := ParseTune([]byte{0x18, 0x02, 0x0C}); !errors.Is(err, ErrNotPSID) {
+		t.Errorf("Expected ErrNotPSID, got %v", err)
+	}
+}
+
+func TestPlayer(t *testing.T) {
+	code := make([]byte, 0x200)
+	copy(code, initSawtooth)
+	// Play routine counting frames.
+	copy(code[0x100:], []byte{0xEE, 0x00, 0xC0, 0x60}) // INC $C000, RTS
+	tune, err := ParseTune(makePSID(0x1000, 0x1100, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player, err := NewPlayer(tune, 1, 44100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]float32, 44100)
+	if err := player.Generate(samples); err != nil {
+		t.Fatal(err)
+	}
+	if frames := player.memory.ram[0xC000]; frames != 50 {
+		t.Errorf("Expected 50 calls of the play routine per second, got %d", frames)
+	}
+	// Count periods of the sawtooth, by its sudden falls.
+	periods := 0
+	for i := 1; i < len(samples); i++ {
+		if samples[i]-samples[i-1] < -0.1 {
+			periods++
+		}
+	}
+	if periods < 438 || periods > 442 {
+		t.Errorf("Expected sawtooth of 440Hz, got %d periods in a second", periods)
+	}
+}
+
+func TestPlayerInterruptHandler(t *testing.T) {
+	code := []byte{
+		0xA9, 0x0B, 0x8D, 0x14, 0x03, // LDA #<handler, STA $0314
+		0xA9, 0x10, 0x8D, 0x15, 0x03, // LDA #>handler, STA $0315
+		0x60,             // RTS
+		0xEE, 0x00, 0xC0, // handler: INC $C000
+		0x4C, 0x31, 0xEA, // JMP $EA31
+	}
+	tune, err := ParseTune(makePSID(0x1000, 0, code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	player, err := NewPlayer(tune, 1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := player.Generate(make([]float32, 100)); err != nil {
+		t.Fatal(err)
+	}
+	if frames := player.memory.ram[0xC000]; frames != 5 {
+		t.Errorf("Expected the interrupt handler to be called 5 times in 0.1s, got %d", frames)
+	}
+	if player.cpu.S != 0xFF {
+		t.Errorf("Expected stack to be balanced, got stack pointer 0x%02X", player.cpu.S)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pwiecz/command_series/atr"
+	"github.com/pwiecz/command_series/lib"
+	"github.com/pwiecz/command_series/render"
+)
+
+var out = flag.String("out", "flashback.gif", "animated GIF file to write, or directory to write PNG files of every day to")
+var delay = flag.Int("delay", 100, "time every day is shown for in the animated GIF (in 100ths of a second)")
+
+// Renders every day of the flashback of a saved game to an animated GIF or a sequence of PNG files.
+func main() {
+	flag.Parse()
+	if len(flag.Args()) != 2 {
+		log.Fatalf("Usage: %s [flags] <game_disk_image> <saved_game>\n", os.Args[0])
+	}
+	fsys, err := openGameFS(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	gameData, err := lib.LoadGameData(fsys)
+	if err != nil {
+		log.Fatalf("Cannot load game data (%v)", err)
+	}
+	saveFile, err := os.Open(flag.Arg(1))
+	if err != nil {
+		log.Fatalf("Cannot open saved game %s (%v)", flag.Arg(1), err)
+	}
+	defer saveFile.Close()
+	reader := bufio.NewReader(saveFile)
+	scenario, err := readSaveHeader(reader, gameData)
+	if err != nil {
+		log.Fatalf("Cannot read saved game header (%v)", err)
+	}
+	var options lib.Options
+	if err := options.Read(reader); err != nil {
+		log.Fatalf("Cannot read options (%v)", err)
+	}
+	scenarioData, err := lib.LoadScenarioData(fsys, gameData.Scenarios[scenario].FilePrefix)
+	if err != nil {
+		log.Fatalf("Cannot load scenario data (%v)", err)
+	}
+	gameState := lib.NewGameState(lib.NewRandSource(1), gameData, scenarioData, scenario, 0, &options, lib.NewMessageSync())
+	if err := gameState.Load(reader); err != nil {
+		log.Fatalf("Cannot load saved game (%v)", err)
+	}
+	flashback := gameState.Flashback()
+	if len(flashback) == 0 {
+		log.Fatal("The saved game has no flashback yet")
+	}
+
+	scenarioInfo := gameData.Scenarios[scenario]
+	renderer := render.NewMapRenderer(gameData.Map,
+		scenarioInfo.MinX, scenarioInfo.MinY, scenarioInfo.MaxX, scenarioInfo.MaxY,
+		gameData.Sprites, gameData.Icons, &scenarioData.Data.DaytimePalette, &scenarioData.Data.NightPalette)
+	days := make([]*image.Paletted, 0, len(flashback))
+	for _, day := range flashback {
+		img := renderer.NewImage()
+		renderer.DrawFlashbackDay(img, day, options.UnitDisplay)
+		days = append(days, img)
+	}
+	if strings.HasSuffix(strings.ToLower(*out), ".gif") {
+		err = writeGIF(*out, days)
+	} else {
+		err = writePNGs(*out, days)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func openGameFS(filename string) (fs.FS, error) {
+	fileStat, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat file %s (%v)", filename, err)
+	}
+	if fileStat.IsDir() {
+		return os.DirFS(filename), nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %s (%v)", filename, err)
+	}
+	// The file must stay open while the game data is being read, it's closed when the tool exits.
+	fsys, err := atr.NewAtrFS(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open atr image file %s (%v)", filename, err)
+	}
+	return fsys, nil
+}
+
+// readSaveHeader reads the header written by the game before the options and the game state
+// and returns number of the scenario of the saved game.
+func readSaveHeader(reader *bufio.Reader, gameData *lib.GameData) (int, error) {
+	prefix, err := reader.ReadString(0)
+	if err != nil {
+		return 0, err
+	}
+	prefix = prefix[:len(prefix)-1]
+	var scenario, playerSide uint8
+	if err := binary.Read(reader, binary.LittleEndian, &scenario); err != nil {
+		return 0, err
+	}
+	if err := binary.Read(reader, binary.LittleEndian, &playerSide); err != nil {
+		return 0, err
+	}
+	if int(scenario) >= len(gameData.Scenarios) || gameData.Scenarios[scenario].FilePrefix != prefix {
+		return 0, fmt.Errorf("unknown scenario %s", prefix)
+	}
+	return int(scenario), nil
+}
+
+func writeGIF(filename string, days []*image.Paletted) error {
+	animation := &gif.GIF{}
+	for _, day := range days {
+		animation.Image = append(animation.Image, day)
+		animation.Delay = append(animation.Delay, *delay)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create file %s (%v)", filename, err)
+	}
+	if err := gif.EncodeAll(file, animation); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write file %s (%v)", filename, err)
+	}
+	return file.Close()
+}
+
+func writePNGs(dir string, days []*image.Paletted) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("cannot create directory %s (%v)", dir, err)
+	}
+	for i, day := range days {
+		filename := filepath.Join(dir, fmt.Sprintf("day_%03d.png", i+1))
+		if err := writePNG(filename, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writePNG(filename string, img image.Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create file %s (%v)", filename, err)
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write file %s (%v)", filename, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/pwiecz/command_series/sid"
+)
+
+var song = flag.Int("song", 0, "number of the song to play (counting from 1, 0 - the default song of the tune)")
+var duration = flag.Int("duration", 120, "length of the rendered song (in seconds)")
+var rate = flag.Int("rate", 44100, "sample rate (in Hz)")
+var out = flag.String("out", "tune.wav", "WAV file to write")
+
+// Renders a song of a PSID tune to a WAV file.
+func main() {
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		log.Fatalf("Usage: %s [flags] <tune.sid>\n", os.Args[0])
+	}
+	if *duration <= 0 || *rate <= 0 {
+		log.Fatal("Duration and sample rate must be positive")
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Cannot read file %s (%v)", flag.Arg(0), err)
+	}
+	tune, err := sid.ParseTune(data)
+	if err == sid.ErrNotPSID {
+		log.Fatalf("%s is not a PSID file (files written by tools/splitter contain programs of the games, decode them with tools/disasm)", flag.Arg(0))
+	} else if err != nil {
+		log.Fatalf("Cannot parse tune %s (%v)", flag.Arg(0), err)
+	}
+	if *song == 0 {
+		*song = tune.StartSong
+	}
+	fmt.Printf("%s by %s (%s), song %d of %d\n", tune.Name, tune.Author, tune.Released, *song, tune.Songs)
+	player, err := sid.NewPlayer(tune, *song, *rate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	samples := make([]float32, *duration**rate)
+	if err := player.Generate(samples); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeWAV(*out, samples, *rate); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeWAV writes the samples as a 16-bit mono WAV file.
+func writeWAV(filename string, samples []float32, rate int) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("cannot create file %s (%v)", filename, err)
+	}
+	writer := bufio.NewWriter(file)
+	dataSize := uint32(2 * len(samples))
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'}, 36 + dataSize, [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16),
+		uint16(1),        // PCM
+		uint16(1),        // mono
+		uint32(rate),     // sample rate
+		uint32(2 * rate), // byte rate
+		uint16(2),        // block align
+		uint16(16),       // bits per sample
+		[4]byte{'d', 'a', 't', 'a'}, dataSize,
+	}
+	for _, field := range header {
+		if err := binary.Write(writer, binary.LittleEndian, field); err != nil {
+			file.Close()
+			return fmt.Errorf("cannot write file %s (%v)", filename, err)
+		}
+	}
+	for _, sample := range samples {
+		value := int16(math.Max(-1, math.Min(1, float64(sample))) * math.MaxInt16)
+		if err := binary.Write(writer, binary.LittleEndian, value); err != nil {
+			file.Close()
+			return fmt.Errorf("cannot write file %s (%v)", filename, err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write file %s (%v)", filename, err)
+	}
+	return file.Close()
+}
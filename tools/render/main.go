@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/pwiecz/command_series/atr"
+	"github.com/pwiecz/command_series/lib"
+	"github.com/pwiecz/command_series/render"
+)
+
+var scenario = flag.Int("scenario", 0, "number of the scenario to render")
+var variant = flag.Int("variant", 0, "number of the variant of the scenario to render")
+var day = flag.Int("day", 0, "render the map at the end of given day of a game of computer against computer (0 - at the start of the game)")
+var seed = flag.Int64("seed", 1, "seed used to initialize random number generator")
+var night = flag.Bool("night", false, "use the night palette")
+var icons = flag.Bool("icons", false, "show units as icons instead of symbols")
+var cursor = flag.String("cursor", "", "draw the cursor at given map coordinates x,y")
+var out = flag.String("out", "map.png", "PNG file to write")
+
+// Renders the map of a scenario with cities and units to a PNG file without the user interface.
+func main() {
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		log.Fatalf("Usage: %s [flags] <game_disk_image>\n", os.Args[0])
+	}
+	if *day < 0 {
+		log.Fatal("Day must not be negative")
+	}
+	var cursorXY lib.MapCoords
+	if *cursor != "" {
+		if _, err := fmt.Sscanf(*cursor, "%d,%d", &cursorXY.X, &cursorXY.Y); err != nil {
+			log.Fatalf("Invalid cursor coordinates %s (%v)", *cursor, err)
+		}
+	}
+	filename := flag.Arg(0)
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("Cannot open file or directory %s (%v)", filename, err)
+	}
+	defer file.Close()
+	fileStat, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Cannot stat file %s (%v)", filename, err)
+	}
+	var fsys fs.FS
+	if fileStat.IsDir() {
+		fsys = os.DirFS(filename)
+	} else {
+		fsys, err = atr.NewAtrFS(file)
+		if err != nil {
+			log.Fatalf("Cannot open atr image file %s (%v)", filename, err)
+		}
+	}
+
+	gameData, err := lib.LoadGameData(fsys)
+	if err != nil {
+		log.Fatalf("Cannot load game data (%v)", err)
+	}
+	if *scenario < 0 || *scenario >= len(gameData.Scenarios) {
+		log.Fatalf("Scenario number must be between 0 and %d", len(gameData.Scenarios)-1)
+	}
+	scenarioData, err := lib.LoadScenarioData(fsys, gameData.Scenarios[*scenario].FilePrefix)
+	if err != nil {
+		log.Fatalf("Cannot load scenario data (%v)", err)
+	}
+	if *variant < 0 || *variant >= len(scenarioData.Variants) {
+		log.Fatalf("Variant number must be between 0 and %d", len(scenarioData.Variants)-1)
+	}
+
+	options := lib.DefaultOptions()
+	options.AlliedCommander = lib.Computer
+	options.GermanCommander = lib.Computer
+	if *icons {
+		options.UnitDisplay = lib.ShowAsIcons
+	}
+	sync := lib.NewMessageSync()
+	gameState := lib.NewGameState(lib.NewRandSource(*seed), gameData, scenarioData, *scenario, *variant, &options, sync)
+	go func() {
+		if !sync.Wait() {
+			return
+		}
+		if !gameState.Init() {
+			return
+		}
+		for gameState.Update() {
+		}
+	}()
+	// The game is paused while the following messages are not being read,
+	// so the state of the game can be read in between.
+	for {
+		if _, ok := sync.GetUpdate().(lib.Initialized); ok {
+			break
+		}
+	}
+	for daysElapsed := 0; daysElapsed < *day; {
+		switch sync.GetUpdate().(type) {
+		case lib.DailyUpdate:
+			daysElapsed++
+		case lib.GameOver:
+			log.Fatalf("The game ended after %d days", daysElapsed)
+		}
+	}
+	defer sync.Stop()
+
+	scenarioInfo := gameData.Scenarios[*scenario]
+	renderer := render.NewMapRenderer(gameData.Map,
+		scenarioInfo.MinX, scenarioInfo.MinY, scenarioInfo.MaxX, scenarioInfo.MaxY,
+		gameData.Sprites, gameData.Icons, &scenarioData.Data.DaytimePalette, &scenarioData.Data.NightPalette)
+	img := renderer.NewImage()
+	renderer.DrawMap(img, *night)
+	// Both sides are commanded by the computer, so all units are visible.
+	view := gameState.ViewFor(0)
+	var cities []lib.City
+	for _, city := range view.Cities() {
+		if city.VariantBitmap&(1<<*variant) == 0 {
+			cities = append(cities, city)
+		}
+	}
+	renderer.DrawCities(img, cities)
+	for _, unit := range view.Units() {
+		renderer.DrawUnit(img, unit.Type, unit.ColorPalette, options.UnitDisplay, unit.XY, *night)
+	}
+	if *cursor != "" {
+		renderer.DrawCursor(img, cursorXY)
+	}
+
+	outFile, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Cannot create file %s (%v)", *out, err)
+	}
+	defer outFile.Close()
+	if err := png.Encode(outFile, img); err != nil {
+		log.Fatalf("Cannot write file %s (%v)", *out, err)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/pwiecz/command_series/atr"
+	"github.com/pwiecz/command_series/lib"
+)
+
+var scenario = flag.Int("scenario", 0, "number of the scenario to play")
+var variant = flag.Int("variant", 0, "number of the variant of the scenario to play")
+var alliedAI = flag.Int("allied-ai", 0, "level of the allied computer commander (0 - original, 1 - cautious, 2 - aggressive, 3 - lookahead)")
+var germanAI = flag.Int("german-ai", 0, "level of the german computer commander (0 - original, 1 - cautious, 2 - aggressive, 3 - lookahead)")
+var seed = flag.Int64("seed", 1, "seed used to initialize random number generator")
+var decisionLog = flag.String("decision-log", "", "write decisions of the computer commanders to given file as JSON lines")
+
+// Plays a game of computer against computer without the user interface.
+func main() {
+	flag.Parse()
+	if len(flag.Args()) != 1 {
+		log.Fatalf("Usage: %s [flags] <game_disk_image>\n", os.Args[0])
+	}
+	if *alliedAI < 0 || *alliedAI > 3 || *germanAI < 0 || *germanAI > 3 {
+		log.Fatal("Computer commander level must be between 0 and 3")
+	}
+	filename := flag.Arg(0)
+	file, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("Cannot open file or directory %s (%v)", filename, err)
+	}
+	defer file.Close()
+	fileStat, err := file.Stat()
+	if err != nil {
+		log.Fatalf("Cannot stat file %s (%v)", filename, err)
+	}
+	var fsys fs.FS
+	if fileStat.IsDir() {
+		fsys = os.DirFS(filename)
+	} else {
+		fsys, err = atr.NewAtrFS(file)
+		if err != nil {
+			log.Fatalf("Cannot open atr image file %s (%v)", filename, err)
+		}
+	}
+
+	gameData, err := lib.LoadGameData(fsys)
+	if err != nil {
+		log.Fatalf("Cannot load game data (%v)", err)
+	}
+	if *scenario < 0 || *scenario >= len(gameData.Scenarios) {
+		log.Fatalf("Scenario number must be between 0 and %d", len(gameData.Scenarios)-1)
+	}
+	scenarioData, err := lib.LoadScenarioData(fsys, gameData.Scenarios[*scenario].FilePrefix)
+	if err != nil {
+		log.Fatalf("Cannot load scenario data (%v)", err)
+	}
+	if *variant < 0 || *variant >= len(scenarioData.Variants) {
+		log.Fatalf("Variant number must be between 0 and %d", len(scenarioData.Variants)-1)
+	}
+
+	options := lib.DefaultOptions()
+	options.AlliedCommander = lib.Computer
+	options.GermanCommander = lib.Computer
+	options.AlliedAILevel = lib.AILevel(*alliedAI)
+	options.GermanAILevel = lib.AILevel(*germanAI)
+	sync := lib.NewMessageSync()
+	gameState := lib.NewGameState(lib.NewRandSource(*seed), gameData, scenarioData, *scenario, *variant, &options, sync)
+	if *decisionLog != "" {
+		f, err := os.Create(*decisionLog)
+		if err != nil {
+			log.Fatalf("Cannot create decision log %s (%v)", *decisionLog, err)
+		}
+		defer f.Close()
+		writer := bufio.NewWriter(f)
+		defer writer.Flush()
+		gameState.SetDecisionLog(writer)
+	}
+
+	go func() {
+		if !sync.Wait() {
+			return
+		}
+		if !gameState.Init() {
+			return
+		}
+		for gameState.Update() {
+		}
+	}()
+	for {
+		if gameOver, ok := sync.GetUpdate().(lib.GameOver); ok {
+			sync.Stop()
+			fmt.Println(gameOver.Results)
+			break
+		}
+	}
+}
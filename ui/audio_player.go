@@ -4,51 +4,50 @@ import (
 	"sync"
 
 	"github.com/ebitengine/oto/v3"
+	"github.com/pwiecz/command_series/pokey"
 )
 
-// A trivial player generating strictly rectangular waves of given frequency on 4 channels.
+const sampleRate = 44100
+
+// A player of sound generated by an emulation of Atari's POKEY chip.
+// Channels are controlled like POKEY, by setting its registers.
 type AudioPlayer struct {
 	player *oto.Player
 	source *audioSource
 }
 
 type audioSource struct {
-	mutex       sync.Mutex
-	currentPos  int
-	frequencies [4]byte
-	origBuf     []byte
-	buf         []byte
+	mutex   sync.Mutex
+	pokey   *pokey.Pokey
+	samples []float32
+	// Previous input and output of the filter removing the constant offset from the signal.
+	lastLevel, lastOutput float64
 }
 
+// Pole of the filter removing the constant offset, a cutoff frequency of about 35Hz.
+const dcBlockerPole = 0.995
+
 func (p *audioSource) Read(buf []byte) (int, error) {
-	if len(p.buf) == 0 {
-		p.buf = p.origBuf
-		p.mutex.Lock()
-		freq := p.frequencies
-		p.mutex.Unlock()
-		for i := 0; i < len(p.buf); i++ {
-			channel := (p.currentPos + i) % 4
-			if freq[channel] == 0 {
-				p.buf[i] = 128
-			} else {
-				channelPos := (p.currentPos + i) / 4
-				channelLength := 44100 / int(freq[channel])
-				if channelPos%channelLength < channelLength/2 {
-					p.buf[i] = 0
-				} else {
-					p.buf[i] = 255
-				}
-			}
-		}
-		p.currentPos += len(p.buf)
+	// Two bytes of an unsigned 8-bit stereo sample.
+	n := len(buf) / 2
+	if cap(p.samples) < n {
+		p.samples = make([]float32, n)
 	}
-	n := copy(buf, p.buf)
-	p.buf = p.buf[n:]
-	return n, nil
-}
-
-func (p *audioSource) SetFrequency(channel int, freq byte) {
-	p.frequencies[channel] = freq
+	samples := p.samples[:n]
+	p.mutex.Lock()
+	p.pokey.Generate(samples)
+	p.mutex.Unlock()
+	for i, level := range samples {
+		// POKEY's output only ever rises above the silence, its constant offset is removed
+		// (like by the coupling capacitor of the Atari's audio output), so that the silence
+		// stays at the centre of the unsigned samples and the signal swings around it.
+		output := float64(level) - p.lastLevel + dcBlockerPole*p.lastOutput
+		p.lastLevel, p.lastOutput = float64(level), output
+		sample := byte(128 + int(max(-1, min(1, output))*127))
+		buf[2*i] = sample
+		buf[2*i+1] = sample
+	}
+	return 2 * n, nil
 }
 
 func NewAudioPlayer(context *oto.Context) *AudioPlayer {
@@ -56,7 +55,7 @@ func NewAudioPlayer(context *oto.Context) *AudioPlayer {
 		return &AudioPlayer{}
 	}
 	s := &audioSource{
-		origBuf: make([]byte, 4096),
+		pokey: pokey.New(pokey.NTSCClock, sampleRate),
 	}
 	p := &AudioPlayer{
 		source: s,
@@ -66,12 +65,40 @@ func NewAudioPlayer(context *oto.Context) *AudioPlayer {
 	return p
 }
 
+// SetFrequency sets the frequency register (AUDF) of the channel.
+// The channel plays at frequency of 63921/(2*(freq+1)) Hz.
 func (p *AudioPlayer) SetFrequency(channel int, freq byte) {
-	p.source.SetFrequency(channel, freq)
+	if p == nil || p.source == nil {
+		return
+	}
+	p.source.mutex.Lock()
+	defer p.source.mutex.Unlock()
+	p.source.pokey.SetAUDF(channel, freq)
+}
+
+// SetControl sets the control register (AUDC) of the channel.
+// Lower 4 bits are the volume, upper bits select the distortion (0xA0 - pure tone).
+func (p *AudioPlayer) SetControl(channel int, control byte) {
+	if p == nil || p.source == nil {
+		return
+	}
+	p.source.mutex.Lock()
+	defer p.source.mutex.Unlock()
+	p.source.pokey.SetAUDC(channel, control)
+}
+
+// SetAudioControl sets the register (AUDCTL) controlling clocks, joining and filtering of the channels.
+func (p *AudioPlayer) SetAudioControl(control byte) {
+	if p == nil || p.source == nil {
+		return
+	}
+	p.source.mutex.Lock()
+	defer p.source.mutex.Unlock()
+	p.source.pokey.SetAUDCTL(control)
 }
 
 func (p *AudioPlayer) Close() {
-	if p.player == nil {
+	if p == nil || p.player == nil {
 		return
 	}
 	p.player.Close()
@@ -2,7 +2,6 @@ package ui
 
 import (
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/pwiecz/command_series/lib"
 )
 
@@ -33,7 +32,7 @@ func NewFinalResult(result, difficulty, rank int, font *lib.Font, onRestartGame
 }
 
 func (s *FinalResult) Update() error {
-	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+	if isKeyJustPressed(ebiten.KeyEnter) {
 		s.onRestartGame()
 	}
 	return nil
@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/pwiecz/command_series/lib"
+)
+
+// newTestFont returns a font with a different pattern of pixels in every glyph,
+// so that widgets showing text can be tested without the disk images of the games.
+func newTestFont(t *testing.T) *lib.Font {
+	t.Helper()
+	// 128 sprites of 8x8 pixels, a byte per row.
+	data := make([]byte, 128*8)
+	for i := range data {
+		data[i] = byte(i/8*29+i%8*71) ^ 0x5a
+	}
+	sprites, err := lib.ParseSprites(bytes.NewReader(data), bytes.NewReader(data), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sprites.GameFont
+}
+
+// renderFixture draws widgets created by the function on an empty screen and returns the drawn frame.
+// Widgets are created and drawn in the ebiten main loop.
+func renderFixture(t *testing.T, draw func(screen *ebiten.Image)) *image.RGBA {
+	t.Helper()
+	if mainLoopCalls == nil {
+		t.Skip("Screenshot tests need a display")
+	}
+	var screenshot *image.RGBA
+	runInMainLoop(func() {
+		screen := ebiten.NewImage(336, 240)
+		draw(screen)
+		screenshot = image.NewRGBA(screen.Bounds())
+		screen.ReadPixels(screenshot.Pix)
+	})
+	return screenshot
+}
+
+// drawLabelReference draws text like a label of given bounds does, straight from pixels of glyphs of the font.
+// Text between asterisks is inverted.
+func drawLabelReference(img *image.RGBA, text string, x, y, width, height int, font *lib.Font, textColor, backgroundColor int) {
+	fontWidth := font.Size().X
+	numCells := (width + fontWidth - 1) / fontWidth
+	runes := make([]rune, numCells)
+	inverted := make([]bool, numCells)
+	for i := range runes {
+		runes[i] = ' '
+	}
+	cell, invert := 0, false
+	for _, r := range text {
+		if r == '*' {
+			invert = !invert
+			continue
+		}
+		if cell < numCells {
+			runes[cell], inverted[cell] = r, invert
+			cell++
+		}
+	}
+	for dy := 0; dy < height; dy++ {
+		for dx := 0; dx < width; dx++ {
+			c := lib.RGBPalette[backgroundColor]
+			glyph := font.Glyph(runes[dx/fontWidth])
+			if glyphXY := image.Pt(dx%fontWidth, dy); glyphXY.In(glyph.Bounds()) {
+				colors := [2]color.RGBA{lib.RGBPalette[backgroundColor], lib.RGBPalette[textColor]}
+				if inverted[dx/fontWidth] {
+					colors[0], colors[1] = colors[1], colors[0]
+				}
+				c = colors[glyph.ColorIndexAt(glyphXY.X, glyphXY.Y)]
+			}
+			img.SetRGBA(x+dx, y+dy, c)
+		}
+	}
+}
+
+// compareFixture checks the rendered frame against the reference image.
+func compareFixture(t *testing.T, name string, screenshot, reference *image.RGBA) {
+	t.Helper()
+	for y := screenshot.Bounds().Min.Y; y < screenshot.Bounds().Max.Y; y++ {
+		for x := screenshot.Bounds().Min.X; x < screenshot.Bounds().Max.X; x++ {
+			if screenshot.RGBAAt(x, y) == reference.RGBAAt(x, y) {
+				continue
+			}
+			actualPath := filepath.Join(os.TempDir(), fmt.Sprintf("command_series_fixture_%s.png", name))
+			if err := writeScreenshot(actualPath, screenshot); err != nil {
+				t.Error(err)
+			}
+			t.Fatalf("Fixture %s differs at (%d,%d), expected %v, got %v, the rendered screen is in %s",
+				name, x, y, reference.RGBAAt(x, y), screenshot.RGBAAt(x, y), actualPath)
+		}
+	}
+}
+
+func TestLabelFixtures(t *testing.T) {
+	font := newTestFont(t)
+	screenshot := renderFixture(t, func(screen *ebiten.Image) {
+		NewLabel("OPTION SELECTION", 24, 32, 300, 8, font).Draw(screen)
+		label := NewLabel("Then press *ENTER*.", 40, 136, 300, 8, font)
+		label.SetTextColor(0)
+		label.SetBackgroundColor(15)
+		label.Draw(screen)
+		// The label is redrawn with the new text.
+		label.SetText("*When*", 0)
+		label.Draw(screen)
+	})
+	reference := image.NewRGBA(screenshot.Bounds())
+	drawLabelReference(reference, "OPTION SELECTION", 24, 32, 300, 8, font, 15, 0)
+	drawLabelReference(reference, "*When* press *ENTER*.", 40, 136, 300, 8, font, 0, 15)
+	compareFixture(t, "labels", screenshot, reference)
+}
+
+func TestButtonFixture(t *testing.T) {
+	font := newTestFont(t)
+	screenshot := renderFixture(t, func(screen *ebiten.Image) {
+		button := NewButton("FAIR", 152, 80, 300, 8, font)
+		button.Draw(screen)
+		button.SetText("+ALLIED")
+		button.Draw(screen)
+	})
+	reference := image.NewRGBA(screenshot.Bounds())
+	drawLabelReference(reference, "+ALLIED", 152, 80, 300, 8, font, 0, 15)
+	compareFixture(t, "button", screenshot, reference)
+}
+
+func TestMessageBoxFixture(t *testing.T) {
+	font := newTestFont(t)
+	screenshot := renderFixture(t, func(screen *ebiten.Image) {
+		messageBox := NewMessageBox(0, 184, 336, 24, font)
+		messageBox.SetTextColor(2)
+		messageBox.SetRowBackground(1, 0x36)
+		messageBox.Print("MESSAGE FROM ...\nREINFORCEMENTS!", 2, 0)
+		messageBox.Print("*WE ARE ATTACKING*", 1, 2)
+		messageBox.Draw(screen)
+	})
+	reference := image.NewRGBA(screenshot.Bounds())
+	drawLabelReference(reference, "  MESSAGE FROM ...", 0, 184, 336, 8, font, 2, 0)
+	drawLabelReference(reference, "  REINFORCEMENTS!", 0, 192, 336, 8, font, 2, 0x36)
+	drawLabelReference(reference, " *WE ARE ATTACKING*", 0, 200, 336, 8, font, 2, 0)
+	compareFixture(t, "message_box", screenshot, reference)
+}
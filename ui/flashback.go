@@ -5,81 +5,190 @@ import (
 	"fmt"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/pwiecz/command_series/lib"
 )
 
+// Number of frames it takes units to move to their positions from the previous day.
+const flashbackMoveFrames = 40
+
+// Number of frames every day is shown for during continuous playback.
+const flashbackDayFrames = 100
+
 type Flashback struct {
-	mapView        *MapView
-	messageBox     *MessageBox
-	flashback      lib.FlashbackHistory
-	terrainTypeMap *lib.TerrainTypeMap
-	day            int
-	shownDay       int
+	mapView    *MapView
+	messageBox *MessageBox
+	flashback  lib.FlashbackHistory
+	day        int
+	shownDay   int
+	// Number of frames elapsed since the shown day was selected.
+	frame int
+	// Positions of units on the previous day, used to animate their movement.
+	previousXY map[flashbackUnitKey]lib.UnitCoords
+	frontLine  []lib.FrontLineSegment
+	// If set, days are shown one after another.
+	isPlaying bool
+	// Tells if the game can be rewound to given day (nil if it cannot be rewound at all).
+	canRewind func(day int) bool
+	// Day to which the game should be rewound (-1 if it should not).
+	rewindDay int
+}
+
+type flashbackUnitKey struct {
+	side, index int
 }
 
-func NewFlashback(mapView *MapView, messageBox *MessageBox, flashback lib.FlashbackHistory, terrainTypeMap *lib.TerrainTypeMap) *Flashback {
+func NewFlashback(mapView *MapView, messageBox *MessageBox, flashback lib.FlashbackHistory, canRewind func(day int) bool) *Flashback {
 	messageBox.Clear()
 	messageBox.Print("FLASHBACK: DAY 1", 2, 0)
-	messageBox.Print("* F2 * NEXT DAY", 2, 1)
-	messageBox.Print("* F3 * PREVIOUS DAY", 2, 2)
+	messageBox.Print("* F2 * NEXT DAY   * F3 * PREVIOUS DAY", 2, 1)
+	messageBox.Print("* F1 * PLAY / PAUSE", 2, 2)
 	messageBox.Print("* F4 * RETURN TO GAME", 2, 3)
+	if canRewind != nil {
+		messageBox.Print("* F5 * REWIND GAME TO THIS DAY", 2, 4)
+	}
 	return &Flashback{
-		mapView:        mapView,
-		messageBox:     messageBox,
-		flashback:      flashback,
-		terrainTypeMap: terrainTypeMap,
-		shownDay:       -1}
+		mapView:    mapView,
+		messageBox: messageBox,
+		flashback:  flashback,
+		shownDay:   -1,
+		canRewind:  canRewind,
+		rewindDay:  -1}
+}
+
+// RewindDay returns the day to which the player chose to rewind the game.
+func (f *Flashback) RewindDay() (int, bool) {
+	return f.rewindDay, f.rewindDay >= 0
 }
 
 func (f *Flashback) Update() error {
-	if inpututil.IsKeyJustPressed(ebiten.KeyF2) {
+	f.frame++
+	if isKeyJustPressed(ebiten.KeyF1) || isKeyJustPressed(ebiten.KeySpace) {
+		f.isPlaying = !f.isPlaying
+		if f.isPlaying && f.day+1 >= len(f.flashback) {
+			// Start from the beginning if the last day is shown.
+			f.day = 0
+		}
+	} else if isKeyJustPressed(ebiten.KeyF2) {
+		f.isPlaying = false
 		if f.day+1 < len(f.flashback) {
 			f.day++
 		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyF3) {
+	} else if isKeyJustPressed(ebiten.KeyF3) {
+		f.isPlaying = false
 		if f.day > 0 {
 			f.day--
 		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyF4) || inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
-		f.hideUnitsFromDay(f.shownDay)
+	} else if isKeyJustPressed(ebiten.KeyF4) || isKeyJustPressed(ebiten.KeyEscape) {
 		return errors.New("Exit")
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+	} else if isKeyJustPressed(ebiten.KeyF5) && f.canRewind != nil {
+		if !f.canRewind(f.day) {
+			f.messageBox.ClearRow(4)
+			f.messageBox.Print("CANNOT REWIND TO THIS DAY", 2, 4)
+			return nil
+		}
+		f.rewindDay = f.day
+		return errors.New("Exit")
+	} else if isKeyJustPressed(ebiten.KeyDown) {
 		curXY := f.mapView.GetCursorPosition()
 		f.mapView.SetCursorPosition(lib.MapCoords{X: curXY.X, Y: curXY.Y + 1})
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+	} else if isKeyJustPressed(ebiten.KeyUp) {
 		curXY := f.mapView.GetCursorPosition()
 		f.mapView.SetCursorPosition(lib.MapCoords{X: curXY.X, Y: curXY.Y - 1})
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+	} else if isKeyJustPressed(ebiten.KeyRight) {
 		curXY := f.mapView.GetCursorPosition()
 		f.mapView.SetCursorPosition(lib.MapCoords{X: curXY.X + 1, Y: curXY.Y})
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+	} else if isKeyJustPressed(ebiten.KeyLeft) {
 		curXY := f.mapView.GetCursorPosition()
 		f.mapView.SetCursorPosition(lib.MapCoords{X: curXY.X - 1, Y: curXY.Y})
 	}
+	if f.isPlaying && f.day == f.shownDay && f.frame >= flashbackDayFrames {
+		if f.day+1 < len(f.flashback) {
+			f.day++
+		} else {
+			f.isPlaying = false
+		}
+	}
+	if f.day != f.shownDay {
+		f.showDay(f.day)
+	}
 	return nil
 }
 
-func (f *Flashback) Draw(screen *ebiten.Image) {
-	if f.day != f.shownDay {
-		f.hideUnitsFromDay(f.shownDay)
-		if f.day < len(f.flashback) {
-			for _, unit := range f.flashback[f.day] {
-				f.terrainTypeMap.ShowUnitAt(unit.XY)
+func (f *Flashback) showDay(day int) {
+	// Animate movement only when going forward by a single day.
+	animate := day == f.shownDay+1 && day > 0
+	f.shownDay = day
+	f.frame = 0
+	f.previousXY = nil
+	f.frontLine = nil
+	if day < len(f.flashback) {
+		if animate {
+			f.previousXY = make(map[flashbackUnitKey]lib.UnitCoords)
+			for _, unit := range f.flashback[day-1].Units {
+				key := flashbackUnitKey{unit.Side, unit.Index}
+				if _, ok := f.previousXY[key]; ok {
+					// Units of games saved by older versions cannot be told apart, don't animate them.
+					f.previousXY = nil
+					break
+				}
+				f.previousXY[key] = unit.XY
 			}
 		}
-		f.messageBox.ClearRow(0)
-		f.messageBox.Print(fmt.Sprintf("FLASHBACK: DAY %d", f.day+1), 2, 0)
-		f.shownDay = f.day
+		f.frontLine = f.flashback[day].Units.FrontLine(f.mapView.MapBounds())
 	}
-	f.mapView.Draw(screen)
+	if !animate {
+		f.frame = flashbackMoveFrames
+	}
+	f.messageBox.ClearRow(0)
+	f.messageBox.Print(fmt.Sprintf("FLASHBACK: DAY %d", day+1), 2, 0)
 }
 
-func (f *Flashback) hideUnitsFromDay(day int) {
-	if day < 0 || day >= len(f.flashback) {
+func (f *Flashback) Draw(screen *ebiten.Image) {
+	f.mapView.Draw(screen)
+	if f.shownDay < 0 || f.shownDay >= len(f.flashback) {
 		return
 	}
-	for _, unit := range f.flashback[day] {
-		f.terrainTypeMap.HideUnitAt(unit.XY)
+	flashbackDay := f.flashback[f.shownDay]
+	moved := f.frame >= flashbackMoveFrames
+	if moved {
+		f.mapView.DrawFrontLine(f.frontLine, screen)
+	}
+	alpha := float64(lib.Min(f.frame, flashbackMoveFrames)) / flashbackMoveFrames
+	for _, unit := range flashbackDay.Units {
+		sprite := f.mapView.GetSpriteForUnit(lib.Unit{Type: unit.Type, ColorPalette: unit.ColorPalette})
+		xy := unit.XY.ToMapCoords()
+		previousXY, ok := f.previousXY[flashbackUnitKey{unit.Side, unit.Index}]
+		if !ok || moved {
+			f.mapView.DrawSpriteBetween(sprite, xy, xy, 0, screen)
+			continue
+		}
+		f.mapView.DrawSpriteBetween(sprite, previousXY.ToMapCoords(), xy, alpha, screen)
 	}
+	if !moved {
+		return
+	}
+	// Events of the day are marked after units reach their positions.
+	for _, event := range flashbackDay.Events {
+		xy := event.XY.ToMapCoords()
+		if !f.mapView.AreMapCoordsVisible(xy) {
+			continue
+		}
+		switch event.Type {
+		case lib.FlashbackBattle:
+			icon := lib.CircleIcons[(f.frame/4)%len(lib.CircleIcons)]
+			f.mapView.DrawSpriteBetween(f.mapView.GetSpriteFromIcon(icon), xy, xy, 0, screen)
+		case lib.FlashbackCapture:
+			f.drawIconAbove(lib.SmilingFace, xy, screen)
+		case lib.FlashbackSurrender:
+			f.drawIconAbove(lib.SurrenderingUnit, xy, screen)
+		case lib.FlashbackReinforcement:
+			f.drawIconAbove(lib.ExclamationMark, xy, screen)
+		}
+	}
+}
+
+// drawIconAbove draws the icon slightly above the tile, like icons shown with messages from units.
+func (f *Flashback) drawIconAbove(icon lib.IconType, xy lib.MapCoords, screen *ebiten.Image) {
+	x, y := f.mapView.MapCoordsToScreenCoords(xy)
+	f.mapView.drawSpriteAtCoords(f.mapView.GetSpriteFromIcon(icon), x, y-5*f.mapView.zoomY, screen)
 }
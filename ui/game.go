@@ -1,13 +1,18 @@
 package ui
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
 	"math/rand"
 
 	"github.com/ebitengine/oto/v3"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/pwiecz/command_series/lib"
+	"github.com/pwiecz/command_series/netplay"
+	"github.com/pwiecz/command_series/sid"
 )
 
 type SubGame interface {
@@ -18,6 +23,7 @@ type SubGame interface {
 type Game struct {
 	subGame          SubGame
 	fsys             fs.FS
+	randSource       *lib.RandSource
 	rand             *rand.Rand
 	gameData         *lib.GameData
 	selectedScenario int
@@ -25,31 +31,102 @@ type Game struct {
 	selectedVariant  int
 	options          *lib.Options
 
+	network NetworkOptions
+	host    *netplay.Host
+	client  *netplay.Client
+
+	turnOptions TurnOptions
+	turn        *turn
+
+	// If non-nil, decisions of the computer commander are logged to it.
+	decisionLog io.Writer
+
 	otoContext  *oto.Context
 	audioPlayer *AudioPlayer
+	musicPlayer *MusicPlayer
+	// If non-nil, played on the intro and the ending screens.
+	music *sid.Tune
+	// The title sequence is shown only once, not after restarting the game.
+	introShown bool
+	// If set, no audio is played (e.g. in tests, which may run without an audio device).
+	audioDisabled bool
 }
 
 var _ ebiten.Game = (*Game)(nil)
 
-func NewGame(fsys fs.FS, rand *rand.Rand) (*Game, error) {
+func NewGame(fsys fs.FS, randSource *lib.RandSource, network NetworkOptions, turnOptions TurnOptions) (*Game, error) {
 	game := &Game{
 		fsys:             fsys,
-		rand:             rand,
+		randSource:       randSource,
+		rand:             rand.New(randSource),
 		selectedScenario: -1,
 		selectedVariant:  -1,
+		network:          network,
+		turnOptions:      turnOptions,
 	}
 	game.subGame = NewGameLoading(fsys, game.onGameLoaded)
 	return game, nil
 }
 
+// SetDecisionLog makes games write decisions of the computer commander to the writer.
+func (g *Game) SetDecisionLog(writer io.Writer) {
+	g.decisionLog = writer
+}
+
+// SetMusic sets the tune played on the intro screen (its default song)
+// and the ending screen (the following song, if there is more than one).
+func (g *Game) SetMusic(tune *sid.Tune) error {
+	for _, song := range []int{tune.StartSong, endingSong(tune)} {
+		if _, err := sid.NewPlayer(tune, song, sampleRate); err != nil {
+			return err
+		}
+	}
+	g.music = tune
+	return nil
+}
+
+func endingSong(tune *sid.Tune) int {
+	return tune.StartSong%tune.Songs + 1
+}
+
+func (g *Game) playMusic(ending bool) {
+	if g.music == nil {
+		return
+	}
+	song := g.music.StartSong
+	if ending {
+		song = endingSong(g.music)
+	}
+	// The tune has been checked to be playable when it was set.
+	g.musicPlayer.Play(g.music, song)
+}
+
 func (g *Game) onGameLoaded(gameData *lib.GameData) {
 	g.gameData = gameData
+	if g.network.JoinAddress != "" {
+		g.subGame = NewNetworkConnection("CONNECTING ...", g.gameData.Sprites.IntroFont, g.joinGame, g.startGame)
+		return
+	}
+	if g.turnOptions.TurnFile != "" {
+		g.subGame = NewNetworkConnection("LOADING TURN ...", g.gameData.Sprites.IntroFont, g.loadTurn, g.startGame)
+		return
+	}
+	g.playMusic(false)
+	if !g.introShown {
+		g.introShown = true
+		g.subGame = NewIntro(g.gameData.Game, g.gameData.Sprites.IntroFont, g.showScenarioSelection)
+		return
+	}
+	g.showScenarioSelection()
+}
+func (g *Game) showScenarioSelection() {
 	g.subGame = NewScenarioSelection(g.gameData.Scenarios, g.gameData.Sprites.IntroFont, g.onScenarioSelected)
 }
 func (g *Game) onRestartGame() {
 	g.subGame = NewGameLoading(g.fsys, g.onGameLoaded)
 }
 func (g *Game) onScenarioSelected(selectedScenario int) {
+	g.musicPlayer.Stop()
 	g.selectedScenario = selectedScenario
 	g.subGame = NewScenarioLoading(g.fsys, g.gameData.Scenarios[selectedScenario], g.gameData.Sprites.IntroFont, g.onScenarioLoaded)
 }
@@ -70,14 +147,133 @@ func (g *Game) onVariantSelected(selectedVariant int) {
 }
 func (g *Game) onOptionsSelected(options *lib.Options) {
 	g.options = options
-	g.subGame = NewMainScreen(g, g.options, g.audioPlayer, g.rand, g.onGameOver)
+	if g.network.HostAddress != "" {
+		g.subGame = NewNetworkConnection("WAITING FOR THE OTHER PLAYER ...", g.gameData.Sprites.IntroFont, g.hostGame, g.startGame)
+		return
+	}
+	if g.turnOptions.Hours > 0 {
+		// The player selected in the options gives orders in the first turn.
+		side := 0
+		if g.options.AlliedCommander != lib.Player {
+			side = 1
+		}
+		g.options.AlliedCommander = lib.Player
+		g.options.GermanCommander = lib.Player
+		g.turn = &turn{
+			TurnHeader: lib.TurnHeader{Number: 1, Side: side, Hours: g.turnOptions.Hours, Variant: g.selectedVariant},
+			key:        []byte(g.turnOptions.Key)}
+	}
+	g.startGame()
+}
+func (g *Game) startGame() {
+	g.subGame = NewMainScreen(g, g.options, g.audioPlayer, g.randSource, g.onGameOver)
+}
+
+// hostGame waits for the other player to join. The host commands the side
+// selected as the player's side in the options, the other player commands the other side.
+func (g *Game) hostGame() error {
+	hostSide := 0
+	if g.options.AlliedCommander != lib.Player {
+		hostSide = 1
+	}
+	host, err := netplay.Accept(g.network.HostAddress, 1-hostSide)
+	if err != nil {
+		return err
+	}
+	g.options.AlliedCommander = lib.Player
+	g.options.GermanCommander = lib.Player
+	var options bytes.Buffer
+	if err := g.options.Write(&options); err != nil {
+		return err
+	}
+	if err := host.SendHello(netplay.Hello{
+		Scenario: g.selectedScenario,
+		Variant:  g.selectedVariant,
+		Options:  options.Bytes()}); err != nil {
+		return err
+	}
+	g.host = host
+	return nil
+}
+
+// joinGame connects to the host and loads the scenario selected by the host.
+func (g *Game) joinGame() error {
+	client, err := netplay.Join(g.network.JoinAddress)
+	if err != nil {
+		return err
+	}
+	hello, err := client.ReadHello()
+	if err != nil {
+		return err
+	}
+	if hello.Scenario < 0 || hello.Scenario >= len(g.gameData.Scenarios) {
+		return fmt.Errorf("invalid scenario %d selected by the host", hello.Scenario)
+	}
+	var options lib.Options
+	if err := options.Read(bytes.NewReader(hello.Options)); err != nil {
+		return fmt.Errorf("cannot read options (%v)", err)
+	}
+	scenarioData, err := lib.LoadScenarioData(g.fsys, g.gameData.Scenarios[hello.Scenario].FilePrefix)
+	if err != nil {
+		return err
+	}
+	if hello.Variant < 0 || hello.Variant >= len(scenarioData.Variants) {
+		return fmt.Errorf("invalid variant %d selected by the host", hello.Variant)
+	}
+	g.selectedScenario = hello.Scenario
+	g.scenarioData = scenarioData
+	g.selectedVariant = hello.Variant
+	g.options = &options
+	g.client = client
+	return nil
+}
+
+// loadTurn reads the turn file and loads the scenario it has been saved in.
+func (g *Game) loadTurn() error {
+	turn, err := readTurnFile(g.turnOptions.TurnFile, []byte(g.turnOptions.Key))
+	if err != nil {
+		return err
+	}
+	reader := bufio.NewReader(bytes.NewReader(turn.game))
+	prefix, scenario, _, err := readSaveHeader(reader)
+	if err != nil {
+		return fmt.Errorf("cannot read saved game (%v)", err)
+	}
+	if scenario >= len(g.gameData.Scenarios) || g.gameData.Scenarios[scenario].FilePrefix != prefix {
+		return fmt.Errorf("turn file saved in unknown scenario %s", prefix)
+	}
+	var options lib.Options
+	if err := options.Read(reader); err != nil {
+		return fmt.Errorf("cannot read options (%v)", err)
+	}
+	scenarioData, err := lib.LoadScenarioData(g.fsys, prefix)
+	if err != nil {
+		return err
+	}
+	if turn.Variant >= len(scenarioData.Variants) {
+		return fmt.Errorf("invalid variant %d in turn file", turn.Variant)
+	}
+	g.selectedScenario = scenario
+	g.scenarioData = scenarioData
+	g.selectedVariant = turn.Variant
+	g.options = &options
+	g.turn = turn
+	return nil
 }
 func (g *Game) onGameOver(result, balance, rank int) {
-	g.subGame = NewFinalResult(result, balance, rank, g.gameData.Sprites.IntroFont, g.onRestartGame)
+	g.host = nil
+	g.client = nil
+	g.turn = nil
+	// Start a regular game after restart.
+	g.turnOptions.TurnFile = ""
+	g.subGame = NewFinalResult(result, balance, rank, g.gameData.Sprites.IntroFont, func() {
+		g.subGame = NewEndingCeremony(g.gameData.Game, result, rank, g.gameData.Sprites.IntroFont, g.onRestartGame)
+	})
+	g.playMusic(true)
 }
 
 func (g *Game) Update() error {
-	if g.otoContext == nil {
+	if g.otoContext == nil && !g.audioDisabled {
 		var err error
 		var ready chan struct{}
 		opts := &oto.NewContextOptions{}
@@ -90,6 +286,7 @@ func (g *Game) Update() error {
 		}
 		<-ready
 		g.audioPlayer = NewAudioPlayer(g.otoContext)
+		g.musicPlayer = NewMusicPlayer(g.otoContext)
 	}
 	if g.subGame != nil {
 		return g.subGame.Update()
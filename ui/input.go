@@ -0,0 +1,30 @@
+package ui
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// keyboardInput tells the state of the keyboard.
+type keyboardInput interface {
+	IsKeyPressed(key ebiten.Key) bool
+	IsKeyJustPressed(key ebiten.Key) bool
+	IsKeyJustReleased(key ebiten.Key) bool
+	// KeyPressDuration returns number of frames for which the key has been pressed.
+	KeyPressDuration(key ebiten.Key) int
+}
+
+type ebitenKeyInput struct{}
+
+func (ebitenKeyInput) IsKeyPressed(key ebiten.Key) bool      { return ebiten.IsKeyPressed(key) }
+func (ebitenKeyInput) IsKeyJustPressed(key ebiten.Key) bool  { return inpututil.IsKeyJustPressed(key) }
+func (ebitenKeyInput) IsKeyJustReleased(key ebiten.Key) bool { return inpututil.IsKeyJustReleased(key) }
+func (ebitenKeyInput) KeyPressDuration(key ebiten.Key) int   { return inpututil.KeyPressDuration(key) }
+
+// Keyboard input read by all screens, replaced with scripted input in tests.
+var keyInput keyboardInput = ebitenKeyInput{}
+
+func isKeyPressed(key ebiten.Key) bool      { return keyInput.IsKeyPressed(key) }
+func isKeyJustPressed(key ebiten.Key) bool  { return keyInput.IsKeyJustPressed(key) }
+func isKeyJustReleased(key ebiten.Key) bool { return keyInput.IsKeyJustReleased(key) }
+func keyPressDuration(key ebiten.Key) int   { return keyInput.KeyPressDuration(key) }
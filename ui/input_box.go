@@ -2,7 +2,6 @@ package ui
 
 import (
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/pwiecz/command_series/lib"
 )
 
@@ -35,45 +34,45 @@ func (i *InputBox) SetBackgroundColor(backgroundColor int) {
 
 func (i *InputBox) Update() {
 	modified := false
-	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+	if isKeyJustPressed(ebiten.KeyEnter) {
 		i.onEnter(i.text)
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+	} else if isKeyJustPressed(ebiten.KeyEscape) {
 		i.onEnter("")
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+	} else if isKeyJustPressed(ebiten.KeyLeft) {
 		if i.cursorPosition > 0 {
 			i.cursorPosition--
 			modified = true
 		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+	} else if isKeyJustPressed(ebiten.KeyRight) {
 		if i.cursorPosition < len(i.text) {
 			i.cursorPosition++
 			modified = true
 		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyHome) {
+	} else if isKeyJustPressed(ebiten.KeyHome) {
 		i.cursorPosition = 0
 		modified = true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyEnd) {
+	} else if isKeyJustPressed(ebiten.KeyEnd) {
 		i.cursorPosition = len(i.text)
 		modified = true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+	} else if isKeyJustPressed(ebiten.KeyBackspace) {
 		if i.cursorPosition > 0 {
 			i.text = i.text[:i.cursorPosition-1] + i.text[i.cursorPosition:]
 			i.cursorPosition--
 			modified = true
 		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyDelete) {
+	} else if isKeyJustPressed(ebiten.KeyDelete) {
 		if i.cursorPosition < len(i.text) {
 			i.text = i.text[:i.cursorPosition] + i.text[i.cursorPosition+1:]
 			modified = true
 		}
 	} else if len(i.text) < i.width {
-		if inpututil.IsKeyJustPressed(ebiten.KeyMinus) {
+		if isKeyJustPressed(ebiten.KeyMinus) {
 			i.insertStringAtCursor("-")
 			modified = true
 		} else {
 			for k := ebiten.Key(0); k < ebiten.KeyMax; k++ {
 				// hacky...
-				if inpututil.IsKeyJustPressed(k) && len(k.String()) == 1 {
+				if isKeyJustPressed(k) && len(k.String()) == 1 {
 					i.insertStringAtCursor(k.String())
 					modified = true
 					break
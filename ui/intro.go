@@ -1,29 +1,227 @@
 package ui
 
 import (
+	"image"
+	"image/color"
+
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/pwiecz/command_series/lib"
 )
 
+// Intro is the animated title sequence shown before the scenario selection,
+// or the ceremony shown after the final result of the game.
 type Intro struct {
-	font       *lib.Font
-	ebitenFont map[rune]*ebiten.Image
+	font    *lib.Font
+	palette color.Palette
+	glyphs  map[rune]*ebiten.Image
+
+	flag            [][]rune
+	pole            *Rectangle
+	labels          []*Label
+	blinkLabel      *Label
+	backgroundColor int
+
+	ending bool
+	// Position of the top of the flag at the end of the ceremony.
+	flagTopY int
+	// Set if the crowd cheers during the ceremony.
+	cheering bool
+
+	frame int
+	// Set if a key has been pressed while the sequence was shown.
+	keyPressed bool
+	onDone     func()
 }
 
-func NewIntro(font *lib.Font) *Intro {
+var _ SubGame = (*Intro)(nil)
+
+// Colors (indices of Atari palette) of the background and the flag of every game.
+// The flag glyphs use 4 colors, the first one being the background.
+var introColors = map[lib.Game][4]int{
+	lib.Crusade:  {0x92, 0x44, 0x0E, 0x84},
+	lib.Decision: {0x96, 0x44, 0x0E, 0x84},
+	lib.Conflict: {0xB6, 0x44, 0x0E, 0x84},
+}
+
+var gameTitles = map[lib.Game]string{
+	lib.Crusade:  "CRUSADE IN EUROPE",
+	lib.Decision: "DECISION IN THE DESERT",
+	lib.Conflict: "CONFLICT IN VIETNAM",
+}
+
+// Layout and timing of the sequences (in pixels and frames).
+const (
+	introScreenWidth  = 336
+	introFlagTopY     = 24
+	introHalfMastY    = 72
+	introCrowdY       = 200
+	introRowFrames    = 8
+	introCrowdSpeed   = 2
+	introBlinkFrames  = 30
+	introFrames       = 600
+	endingRiseFrames  = 2
+	endingCheerFrames = 15
+	endingFrames      = 900
+)
+
+// NewIntro creates the title sequence of the game.
+func NewIntro(game lib.Game, font *lib.Font, onDone func()) *Intro {
+	i := newIntro(game, font, onDone)
+	title := gameTitles[game]
+	titleLabel := NewLabel(title, float64(introScreenWidth-len(title)*font.Size().X)/2, 136, len(title)*font.Size().X, 8, font)
+	i.blinkLabel = NewLabel("PRESS ANY KEY", float64(introScreenWidth-13*font.Size().X)/2, 156, 13*font.Size().X, 8, font)
+	i.labels = []*Label{titleLabel}
+	for _, label := range append(i.labels, i.blinkLabel) {
+		label.SetTextColor(15)
+		label.SetBackgroundColor(i.backgroundColor)
+	}
+	return i
+}
+
+// NewEndingCeremony creates the ceremony after the game with given result and rank.
+// The flag is raised after a victory and lowered to half-mast after a defeat.
+func NewEndingCeremony(game lib.Game, result, rank int, font *lib.Font, onDone func()) *Intro {
+	i := newIntro(game, font, onDone)
+	i.ending = true
+	// Results from ADVANTAGE up are victories.
+	i.cheering = result >= 5
+	if i.cheering {
+		i.flagTopY = introFlagTopY
+	} else {
+		i.flagTopY = introHalfMastY
+	}
+	lines := []string{resultStrings[result], "YOUR RANK: " + rankStrings[rank]}
+	for y, line := range lines {
+		width := len(line) * font.Size().X
+		label := NewLabel(line, float64(introScreenWidth-width)/2, float64(2+y*9), width, 8, font)
+		label.SetTextColor(15)
+		label.SetBackgroundColor(i.backgroundColor)
+		i.labels = append(i.labels, label)
+	}
+	return i
+}
+
+func newIntro(game lib.Game, font *lib.Font, onDone func()) *Intro {
+	colors := introColors[game]
+	palette := make(color.Palette, len(colors))
+	for i, c := range colors {
+		palette[i] = lib.RGBPalette[c]
+	}
+	flag := GetCrusadeFlagImage()
+	if game == lib.Decision {
+		flag = GetDecisionFlagImage()
+	}
+	flagX := (introScreenWidth - len(flag[0])*font.Size().X) / 2
+	pole := NewRectangle(float64(flagX-4), introFlagTopY-4, 2, introCrowdY-introFlagTopY+4)
+	pole.SetColor(12)
 	return &Intro{
-		font:       font,
-		ebitenFont: make(map[rune]*ebiten.Image)}
+		font:            font,
+		palette:         palette,
+		glyphs:          make(map[rune]*ebiten.Image),
+		flag:            flag,
+		pole:            pole,
+		backgroundColor: colors[0],
+		flagTopY:        introFlagTopY,
+		onDone:          onDone,
+	}
 }
 
+// getSprite returns the glyph of the intro font drawn in the colors of the game.
 func (i *Intro) getSprite(r rune) *ebiten.Image {
-	if e, ok := i.ebitenFont[r]; ok {
+	if r < ' ' {
+		r += 1000
+	}
+	if e, ok := i.glyphs[r]; ok {
 		return e
 	}
-	e := ebiten.NewImageFromImage(i.font.Glyph(r))
-	i.ebitenFont[r] = e
+	glyph := *i.font.Glyph(r)
+	glyph.Palette = i.palette
+	e := ebiten.NewImageFromImage(&glyph)
+	i.glyphs[r] = e
 	return e
 }
+
+func (i *Intro) Update() error {
+	i.frame++
+	done := i.frame >= introFrames
+	if i.ending {
+		done = i.frame >= endingFrames
+	}
+	for k := ebiten.Key(0); k <= ebiten.KeyMax; k++ {
+		if isKeyJustPressed(k) {
+			i.keyPressed = true
+		}
+		// Finish after the key is released, so that it's not handled by the next screen,
+		// and ignore keys pressed before the sequence started.
+		if i.keyPressed && isKeyJustReleased(k) {
+			done = true
+		}
+	}
+	if inpututil.IsMouseButtonJustReleased(ebiten.MouseButtonLeft) || len(inpututil.AppendJustReleasedTouchIDs(nil)) > 0 {
+		done = true
+	}
+	if done {
+		i.onDone()
+	}
+	return nil
+}
+
+func (i *Intro) drawRows(rows [][]rune, x, y int, screen *ebiten.Image) {
+	size := i.font.Size()
+	var opts ebiten.DrawImageOptions
+	for row, line := range rows {
+		opts.GeoM.Reset()
+		opts.GeoM.Translate(float64(x), float64(y+row*size.Y))
+		for _, r := range line {
+			screen.DrawImage(i.getSprite(r), &opts)
+			opts.GeoM.Translate(float64(size.X), 0)
+		}
+	}
+}
+
+func (i *Intro) Draw(screen *ebiten.Image) {
+	screen.Fill(i.palette[0])
+	size := i.font.Size()
+	i.pole.Draw(screen)
+	flagX := (introScreenWidth - len(i.flag[0])*size.X) / 2
+	// The flag is hidden behind the crowd.
+	sky := screen.SubImage(image.Rect(0, 0, introScreenWidth, introCrowdY)).(*ebiten.Image)
+	crowd := GetPeopleRows()
+	crowdX := (introScreenWidth - len(crowd[0])*size.X) / 2
+	if !i.ending {
+		// The flag unfurls row by row, while the crowd marches in from the right.
+		rows := min(len(i.flag), i.frame/introRowFrames)
+		i.drawRows(i.flag[:rows], flagX, introFlagTopY, sky)
+		crowdX = max(crowdX, introScreenWidth-i.frame*introCrowdSpeed)
+		i.drawRows(crowd, crowdX, introCrowdY, screen)
+		if rows == len(i.flag) {
+			for _, label := range i.labels {
+				label.Draw(screen)
+			}
+			if (i.frame/introBlinkFrames)%2 == 0 {
+				i.blinkLabel.Draw(screen)
+			}
+		}
+		return
+	}
+	// The flag rises from behind the crowd up to the top of the pole, or half-mast.
+	flagY := max(i.flagTopY, introCrowdY-i.frame/endingRiseFrames)
+	i.drawRows(i.flag, flagX, flagY, sky)
+	for row, line := range crowd {
+		y := introCrowdY + row*size.Y
+		if i.cheering && (i.frame/endingCheerFrames+row)%2 == 0 {
+			y--
+		}
+		i.drawRows([][]rune{line}, crowdX, y, screen)
+	}
+	if flagY == i.flagTopY {
+		for _, label := range i.labels {
+			label.Draw(screen)
+		}
+	}
+}
+
 func GetDecisionFlagImage() [][]rune {
 	return [][]rune{
 		[]rune{5, 6, 7, 8, 8, 8, 8, ' ', 9, 9, 9, 9, ' ', 8, 8, 8, 8, 10, 11, 12},
@@ -63,33 +261,3 @@ func GetPeopleRows() [][]rune {
 		[]rune{23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, ' ', ' ', ' ', ' ', 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23, 24, 23},
 	}
 }
-func (i *Intro) Draw(screen *ebiten.Image) {
-	str := GetCrusadeFlagImage()
-	opts := ebiten.DrawImageOptions{}
-	width := float64(i.font.Size().X)
-	height := float64(i.font.Size().Y)
-	for _, line := range str {
-		for _, r := range line {
-			if r < ' ' {
-				r += 1000
-			}
-			s := i.getSprite(r)
-			screen.DrawImage(s, &opts)
-			opts.GeoM.Translate(width, 0)
-		}
-		opts.GeoM.Translate(-width*float64(len(line)), height)
-	}
-	opts.GeoM.Translate(0, height*6)
-	for _, line := range GetPeopleRows() {
-		for _, r := range line {
-			if r < ' ' {
-				r += 1000
-			}
-			s := i.getSprite(r)
-			screen.DrawImage(s, &opts)
-			opts.GeoM.Translate(width, 0)
-		}
-		opts.GeoM.Translate(-width*float64(len(line)), height)
-	}
-
-}
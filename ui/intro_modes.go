@@ -6,7 +6,6 @@ import (
 	"io/fs"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/pwiecz/command_series/lib"
 )
 
@@ -42,7 +41,7 @@ func NewScenarioSelection(scenarios []lib.Scenario, font *lib.Font, onScenarioSe
 
 func (s *ScenarioSelection) Update() error {
 	for i, button := range s.buttons {
-		if button.Update() || (i < 10 && inpututil.IsKeyJustReleased(numToKey(i+1))) {
+		if button.Update() || (i < 10 && isKeyJustReleased(numToKey(i+1))) {
 			s.onScenarioSelected(i)
 			return nil
 		}
@@ -91,7 +90,7 @@ func NewVariantSelection(variants []lib.Variant, font *lib.Font, onVariantSelect
 
 func (s *VariantSelection) Update() error {
 	for i, button := range s.buttons {
-		if button.Update() || (i < 10 && inpututil.IsKeyJustReleased(numToKey(i+1))) {
+		if button.Update() || (i < 10 && isKeyJustReleased(numToKey(i+1))) {
 			s.onVariantSelected(i)
 			return nil
 		}
@@ -2,7 +2,6 @@ package ui
 
 import (
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 )
 
 type Command int
@@ -38,6 +37,9 @@ const (
 	Save
 	Load
 	TurboMode
+	ShowObjectives
+	ShowInfluenceMap
+	ShowAIMap
 )
 
 type CommandBuffer struct {
@@ -58,65 +60,71 @@ func (b *CommandBuffer) Update() {
 }
 
 func (b *CommandBuffer) triggeredCommand() (Command, bool) {
-	if ebiten.IsKeyPressed(ebiten.KeyControl) && inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+	if isKeyPressed(ebiten.KeyControl) && isKeyJustPressed(ebiten.KeyQ) {
 		return Quit, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyF) {
+	} else if isKeyJustPressed(ebiten.KeyF) {
 		return Freeze, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeySlash) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+	} else if isKeyJustPressed(ebiten.KeySlash) && isKeyPressed(ebiten.KeyShift) {
 		return StatusReport, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+	} else if isKeyJustPressed(ebiten.KeySpace) {
 		return UnitInfo, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyG) {
+	} else if isKeyJustPressed(ebiten.KeyG) {
 		return GeneralInfo, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyC) {
+	} else if isKeyJustPressed(ebiten.KeyC) {
 		return CityInfo, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyT) {
+	} else if isKeyJustPressed(ebiten.KeyT) {
 		return HideUnits, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyO) {
+	} else if isKeyJustPressed(ebiten.KeyO) {
 		return ShowOverviewMap, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyB) {
+	} else if isKeyJustPressed(ebiten.KeyB) {
 		return ShowFlashback, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyW) {
+	} else if isKeyJustPressed(ebiten.KeyW) {
 		return Who, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyComma) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+	} else if isKeyJustPressed(ebiten.KeyComma) && isKeyPressed(ebiten.KeyShift) {
 		return DecreaseSpeed, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyPeriod) && ebiten.IsKeyPressed(ebiten.KeyShift) {
+	} else if isKeyJustPressed(ebiten.KeyPeriod) && isKeyPressed(ebiten.KeyShift) {
 		return IncreaseSpeed, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyU) {
+	} else if isKeyJustPressed(ebiten.KeyU) {
 		return SwitchUnitDisplay, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyQ) {
+	} else if isKeyJustPressed(ebiten.KeyQ) {
 		return SwitchSides, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyR) {
+	} else if isKeyJustPressed(ebiten.KeyR) {
 		return Reserve, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyD) {
+	} else if isKeyJustPressed(ebiten.KeyD) {
 		return Defend, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyA) {
+	} else if isKeyJustPressed(ebiten.KeyA) {
 		return Attack, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+	} else if isKeyJustPressed(ebiten.KeyM) {
 		return Move, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyH) {
+	} else if isKeyJustPressed(ebiten.KeyH) {
 		return SetObjective, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+	} else if isKeyJustPressed(ebiten.KeyDown) {
 		return ScrollDown, true
-	} else if inpututil.KeyPressDuration(ebiten.KeyDown)%12 == 1 {
+	} else if keyPressDuration(ebiten.KeyDown)%12 == 1 {
 		return ScrollDownFast, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+	} else if isKeyJustPressed(ebiten.KeyUp) {
 		return ScrollUp, true
-	} else if inpututil.KeyPressDuration(ebiten.KeyUp)%12 == 1 {
+	} else if keyPressDuration(ebiten.KeyUp)%12 == 1 {
 		return ScrollUpFast, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+	} else if isKeyJustPressed(ebiten.KeyRight) {
 		return ScrollRight, true
-	} else if inpututil.KeyPressDuration(ebiten.KeyRight)%12 == 1 {
+	} else if keyPressDuration(ebiten.KeyRight)%12 == 1 {
 		return ScrollRightFast, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+	} else if isKeyJustPressed(ebiten.KeyLeft) {
 		return ScrollLeft, true
-	} else if inpututil.KeyPressDuration(ebiten.KeyLeft)%12 == 1 {
+	} else if keyPressDuration(ebiten.KeyLeft)%12 == 1 {
 		return ScrollLeftFast, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyS) {
+	} else if isKeyJustPressed(ebiten.KeyS) {
 		return Save, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyL) {
+	} else if isKeyJustPressed(ebiten.KeyL) {
 		return Load, true
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyF12) {
+	} else if isKeyJustPressed(ebiten.KeyV) {
+		return ShowObjectives, true
+	} else if isKeyJustPressed(ebiten.KeyI) {
+		return ShowInfluenceMap, true
+	} else if isKeyJustPressed(ebiten.KeyX) {
+		return ShowAIMap, true
+	} else if isKeyJustPressed(ebiten.KeyF12) {
 		return TurboMode, true
 	}
 	return 0, false
@@ -48,11 +48,11 @@ func (l *ListBox) SetBackgroundColor(backgroundColor int) {
 }
 func (l *ListBox) Update() {
 	modified := false
-	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+	if isKeyJustPressed(ebiten.KeyEnter) {
 		l.onEnter(l.items[l.selectedItem])
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyEscape) {
+	} else if isKeyJustPressed(ebiten.KeyEscape) {
 		l.onEnter("")
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyDown) {
+	} else if isKeyJustPressed(ebiten.KeyDown) {
 		if l.selectedItem+1 < len(l.items) {
 			l.selectedItem++
 			for l.selectedItem-l.topItem >= l.height {
@@ -60,7 +60,7 @@ func (l *ListBox) Update() {
 			}
 			modified = true
 		}
-	} else if inpututil.IsKeyJustPressed(ebiten.KeyUp) {
+	} else if isKeyJustPressed(ebiten.KeyUp) {
 		if l.selectedItem > 0 {
 			l.selectedItem--
 			for l.selectedItem < l.topItem {
@@ -2,10 +2,10 @@ package ui
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,6 +13,7 @@ import (
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/pwiecz/command_series/lib"
+	"github.com/pwiecz/command_series/netplay"
 )
 
 type MainScreen struct {
@@ -46,6 +47,16 @@ type MainScreen struct {
 	sync    *lib.MessageSync
 	started bool
 
+	// Sync used by the game state. It differs from sync only when hosting a network game.
+	gameSync *lib.MessageSync
+	// Set when playing over the network, at most one of them is non-nil.
+	host   *netplay.Host
+	client *netplay.Client
+	// Set when playing a play-by-email game.
+	turn *turn
+	// Number of days elapsed at the end of the day to which the game is to be rewound (0 if it isn't).
+	rewindDay int
+
 	overviewMap *OverviewMap
 	inputBox    *InputBox
 	listBox     *ListBox
@@ -54,13 +65,27 @@ type MainScreen struct {
 
 	gameOver bool
 
+	// Overlays shown when the computer plays against the computer.
+	showObjectives bool
+	influenceMap   int // index in influenceMapNames
+	// Strategy map of one side shown for debugging the computer commander,
+	// 0 if not shown, otherwise 1 + side*len(aiMapNames) + index in aiMapNames.
+	aiMap int
+	// Playback speed when the computer plays against the computer, as power of two
+	// of the normal speed. It's changed once the game speed is already the fastest or the slowest.
+	playbackSpeed int
+	slowFrames    int
+
 	touchIDs        []ebiten.TouchID // store it here to avoid reallocating it for each Update
 	pressedTouchIDs []ebiten.TouchID // store it here to avoid reallocating it for each Update
 }
 
 var _ SubGame = (*MainScreen)(nil)
 
-func NewMainScreen(g *Game, options *lib.Options, audioPlayer *AudioPlayer, rand *rand.Rand, onGameOver func(int, int, int)) *MainScreen {
+var influenceMapNames = []string{"OFF", "TROOPS", "INFLUENCE", "DEFENCE"}
+var aiMapNames = []string{"TROOPS", "INFLUENCE", "DEFENCE", "TROOPS 4X4", "INFLUENCE 4X4"}
+
+func NewMainScreen(g *Game, options *lib.Options, audioPlayer *AudioPlayer, randSource *lib.RandSource, onGameOver func(int, int, int)) *MainScreen {
 	scenario := &g.gameData.Scenarios[g.selectedScenario]
 	for x := scenario.MinX - 1; x <= scenario.MaxX+1; x++ {
 		g.gameData.Map.SetTile(lib.MapCoords{X: x, Y: scenario.MinY - 1}, 12)
@@ -78,23 +103,64 @@ func NewMainScreen(g *Game, options *lib.Options, audioPlayer *AudioPlayer, rand
 		audioPlayer:      audioPlayer,
 		commandBuffer:    NewCommandBuffer(20),
 		sync:             lib.NewMessageSync(),
+		host:             g.host,
+		client:           g.client,
+		turn:             g.turn,
 		onGameOver:       onGameOver}
 	if options.AlliedCommander == lib.Player {
 		s.playerSide = 0
 	} else {
 		s.playerSide = 1
 	}
-	s.gameState = lib.NewGameState(rand, g.gameData, g.scenarioData, g.selectedScenario, g.selectedVariant, s.options, s.sync)
+	s.gameSync = s.sync
+	if s.host != nil {
+		s.playerSide = 1 - s.host.RemoteSide()
+		s.gameSync = lib.NewMessageSync()
+	} else if s.client != nil {
+		s.playerSide = s.client.Side()
+	} else if s.turn != nil {
+		s.playerSide = s.turn.Side
+	}
+	s.gameState = lib.NewGameState(randSource, g.gameData, g.scenarioData, g.selectedScenario, g.selectedVariant, s.options, s.gameSync)
+	// Units, cities and scenario data are modified by the game on its own copies.
+	s.scenarioData = s.gameState.ScenarioData()
+	if (s.isNetworkGame() || (s.turn != nil && s.turn.game == nil)) && s.playerSide == 1 {
+		// Both sides are commanded by players, show units as seen by the local player.
+		s.gameState.SwitchSides()
+	}
+	script, scriptErr := readScenarioScript(scenario.FilePrefix)
+	if script != nil {
+		s.gameState.SetScript(script)
+	}
+	victoryRules, victoryRulesErr := readVictoryRules(scenario.FilePrefix)
+	if victoryRules != nil {
+		victoryRulesErr = s.gameState.SetVictoryRules(victoryRules.ForVariant(g.selectedVariant))
+	}
+	weatherTable, weatherTableErr := readWeatherTable(scenario.FilePrefix)
+	if weatherTable != nil {
+		weatherTableErr = s.gameState.SetWeatherTable(*weatherTable)
+	}
+	if g.decisionLog != nil {
+		s.gameState.SetDecisionLog(g.decisionLog)
+	}
 	s.mapView = NewMapView(
 		8, 72, 320, 19*8,
-		g.gameData.Map, s.gameState.TerrainTypeMap(), g.scenarioData.Units,
+		g.gameData.Map, s.gameState.TerrainTypeMap(), s.scenarioData.Units,
 		scenario.MinX, scenario.MinY, scenario.MaxX, scenario.MaxY,
 		&g.gameData.Sprites.TerrainTiles,
 		&g.gameData.Sprites.UnitSymbolSprites, &g.gameData.Sprites.UnitIconSprites,
-		&g.gameData.Icons.Sprites, &g.scenarioData.Data.DaytimePalette, &g.scenarioData.Data.NightPalette)
+		&g.gameData.Icons.Sprites, &s.scenarioData.Data.DaytimePalette, &s.scenarioData.Data.NightPalette)
 	s.mapView.SetCursorPosition(lib.MapCoords{X: scenario.MinX + 10, Y: scenario.MinY + 9})
 	s.messageBox = NewMessageBox(0, 22, 336, 40, g.gameData.Sprites.GameFont)
 	s.messageBox.Print("PREPARE FOR BATTLE!", 12, 1)
+	if scriptErr != nil {
+		s.messageBox.Print("CANNOT LOAD SCENARIO SCRIPT", 2, 3)
+	}
+	if victoryRulesErr != nil {
+		s.messageBox.Print("CANNOT LOAD VICTORY RULES", 2, 4)
+	} else if weatherTableErr != nil {
+		s.messageBox.Print("CANNOT LOAD WEATHER TABLE", 2, 4)
+	}
 	s.statusBar = NewMessageBox(0, 62, 376, 8, g.gameData.Sprites.GameFont)
 	s.statusBar.SetTextColor(16)
 	s.statusBar.SetRowBackground(0, 30)
@@ -113,7 +179,7 @@ func (s *MainScreen) Update() error {
 			if k == ebiten.KeyAlt || k == ebiten.KeyControl || k == ebiten.KeyShift /*|| k == ebiten.KeySuper*/ {
 				continue
 			}
-			if inpututil.IsKeyJustPressed(k) {
+			if isKeyJustPressed(k) {
 				s.overviewMap = nil
 				if s.areUnitsHidden {
 					s.toggleHideUnits()
@@ -128,8 +194,15 @@ func (s *MainScreen) Update() error {
 	}
 	if s.flashback != nil {
 		if s.flashback.Update() != nil {
+			if day, ok := s.flashback.RewindDay(); ok {
+				// Flashback of day n shows units at the end of the day.
+				s.rewindDay = day + 1
+			}
 			s.flashback = nil
 			s.messageBox.Clear()
+			if s.rewindDay > 0 {
+				s.messageBox.Print("REWINDING ...", 2, 0)
+			}
 			if s.areUnitsHidden {
 				s.toggleHideUnits()
 			}
@@ -146,19 +219,14 @@ func (s *MainScreen) Update() error {
 	}
 	if !s.started && !s.areUnitsHidden {
 		s.idleTicksLeft = 100
-		go func() {
-			if !s.sync.Wait() {
-				return
-			}
-			if !s.gameState.Init() {
-				return
-			}
-			for {
-				if !s.gameState.Update() {
-					return
-				}
+		if s.client != nil {
+			go s.client.Run(s.gameState, s.scenarioData.Data, s.sync)
+		} else {
+			go s.runGame()
+			if s.host != nil {
+				go s.host.Run(s.gameState, s.gameSync, s.sync)
 			}
-		}()
+		}
 		s.started = true
 	}
 	s.commandBuffer.Update()
@@ -176,9 +244,13 @@ func (s *MainScreen) Update() error {
 		case cmd := <-s.commandBuffer.Commands:
 			switch cmd {
 			case Freeze:
-				if s.gameOver {
+				if s.gameOver || (s.turn != nil && s.turn.ended) {
 					break
 				}
+				if s.turn != nil && s.turn.givingOrders {
+					s.turn.givingOrders = false
+					s.messageBox.Clear()
+				}
 				s.isFrozen = !s.isFrozen
 				s.idleTicksLeft = 0
 				s.statusBar.Clear()
@@ -196,6 +268,11 @@ func (s *MainScreen) Update() error {
 					s.onGameOver(result, balance, rank)
 				}
 			case UnitInfo:
+				if s.aiMap > 0 && s.isSpectator() {
+					s.showObjectiveDecision()
+					s.idleTicksLeft = s.options.Speed.DelayTicks()
+					break
+				}
 				s.showUnitInfo()
 				s.idleTicksLeft = s.options.Speed.DelayTicks()
 			case GeneralInfo:
@@ -232,6 +309,9 @@ func (s *MainScreen) Update() error {
 				s.idleTicksLeft = s.options.Speed.DelayTicks()
 				s.options.UnitDisplay = 1 - s.options.UnitDisplay
 			case SwitchSides:
+				if s.isNetworkGame() || s.turn != nil {
+					break
+				}
 				s.playerSide = 1 - s.playerSide
 				s.orderedUnit = nil
 				s.gameState.SwitchSides()
@@ -311,17 +391,49 @@ func (s *MainScreen) Update() error {
 				curXY := s.mapView.GetCursorPosition()
 				s.mapView.SetCursorPosition(lib.MapCoords{X: curXY.X - 2, Y: curXY.Y})
 			case Save:
-				if s.gameOver {
+				if s.gameOver || s.client != nil {
 					break
 				}
 				s.saveGame()
 			case Load:
-				if s.gameOver {
+				if s.gameOver || s.isNetworkGame() || s.turn != nil {
 					break
 				}
 				s.loadGame()
 			case TurboMode:
 				s.turboMode = !s.turboMode
+			case ShowObjectives:
+				if !s.isSpectator() {
+					break
+				}
+				s.showObjectives = !s.showObjectives
+				s.messageBox.Clear()
+				if s.showObjectives {
+					s.messageBox.Print("ORDERS: ATTACK - RED, DEFEND - BLUE,", 2, 0)
+					s.messageBox.Print("MOVE - WHITE, RESERVE - GREY", 2, 1)
+				}
+			case ShowInfluenceMap:
+				if !s.isSpectator() {
+					break
+				}
+				s.influenceMap = (s.influenceMap + 1) % len(influenceMapNames)
+				s.aiMap = 0
+				s.messageBox.Clear()
+				s.messageBox.Print("INFLUENCE MAP: "+influenceMapNames[s.influenceMap], 2, 0)
+			case ShowAIMap:
+				if !s.isSpectator() {
+					break
+				}
+				s.aiMap = (s.aiMap + 1) % (1 + 2*len(aiMapNames))
+				s.influenceMap = 0
+				s.messageBox.Clear()
+				if s.aiMap == 0 {
+					s.messageBox.Print("AI MAP: OFF", 2, 0)
+				} else {
+					side, mapIndex := (s.aiMap-1)/len(aiMapNames), (s.aiMap-1)%len(aiMapNames)
+					s.messageBox.Print(fmt.Sprintf("AI MAP: %s %s", s.scenarioData.Data.Sides[side], aiMapNames[mapIndex]), 2, 0)
+					s.messageBox.Print("PRESS SPACE FOR OBJECTIVE OF THE UNIT", 2, 1)
+				}
 			}
 		default:
 		}
@@ -369,12 +481,27 @@ func (s *MainScreen) Update() error {
 		s.idleTicksLeft = 0
 	}
 	if s.idleTicksLeft > 0 {
-		s.idleTicksLeft--
+		s.idleTicksLeft -= s.playbackTicks()
 		return nil
 	}
 loop:
 	for {
 		update := s.sync.GetUpdate()
+		if s.turn != nil && s.turn.over {
+			// Turn can be saved only after a unit update, when the game
+			// can be resumed from the saved state.
+			if _, ok := update.(lib.MessageFromUnit); ok || update == nil {
+				s.endTurn()
+				break loop
+			}
+		}
+		if s.rewindDay > 0 {
+			// Similarly the game can be rewound only after a unit update.
+			if _, ok := update.(lib.MessageFromUnit); ok || update == nil {
+				s.rewind()
+				break loop
+			}
+		}
 		if update == nil {
 			// some delay to "simulate" computation time
 			s.idleTicksLeft = 15
@@ -383,6 +510,9 @@ loop:
 		switch message := update.(type) {
 		case lib.Initialized:
 			s.idleTicksLeft = 60
+			if s.turn != nil {
+				s.startTurn()
+			}
 			s.statusBar.Clear()
 			s.statusBar.Print(s.dateTimeString(), 2, 0)
 			break loop
@@ -430,10 +560,38 @@ loop:
 		case lib.WeatherForecast:
 			s.messageBox.Clear()
 			s.messageBox.Print(fmt.Sprintf("WEATHER FORECAST: %s", s.scenarioData.Data.Weather[message.Weather]), 2, 0)
+			if len(message.Forecast) > 0 {
+				forecast := make([]string, 0, len(message.Forecast))
+				for _, weather := range message.Forecast {
+					forecast = append(forecast, s.scenarioData.Data.Weather[weather])
+				}
+				s.messageBox.Print("NEXT DAYS: "+strings.Join(forecast, ", "), 2, 4)
+			}
 		case lib.SupplyDistributionStart:
 			s.mapView.HideIcon()
 			s.messageBox.Print("* SUPPLY DISTRIBUTION *", 2, 1)
 		case lib.SupplyDistributionEnd:
+		case lib.ScriptMessage:
+			s.messageBox.Clear()
+			s.messageBox.Print(message.Text, 2, 0)
+			s.idleTicksLeft = s.options.Speed.DelayTicks()
+			break loop
+		case netplay.ConnectionLost:
+			s.messageBox.Clear()
+			s.messageBox.Print("CONNECTION WITH THE OTHER PLAYER LOST.", 2, 0)
+			s.idleTicksLeft = s.options.Speed.DelayTicks()
+			if s.client != nil {
+				// The game cannot continue without the host.
+				s.gameOver = true
+				s.statusBar.Print("GAME OVER, PRESS '?' FOR RESULTS.", 2, 0)
+				s.sync.Stop()
+			}
+			break loop
+		case lib.ScriptError:
+			s.messageBox.Clear()
+			s.messageBox.Print("SCENARIO SCRIPT ERROR, SCRIPT DISABLED.", 2, 0)
+			s.idleTicksLeft = s.options.Speed.DelayTicks()
+			break loop
 		case lib.DailyUpdate:
 			s.messageBox.Print(fmt.Sprintf("%d DAYS REMAINING.", message.DaysRemaining), 2, 2)
 			supplyLevelNames := []string{"CRITICAL", "SUFFICIENT", "AMPLE"}
@@ -448,6 +606,12 @@ loop:
 				s.showStatusReport()
 				s.idleTicksLeft = s.options.Speed.DelayTicks()
 			}
+			if s.turn != nil && s.gameState.Minute() == 0 {
+				s.turn.hoursPlayed++
+				if s.turn.hoursPlayed >= s.turn.Hours {
+					s.turn.over = true
+				}
+			}
 		default:
 			return fmt.Errorf("unknown message: %v", message)
 		}
@@ -488,22 +652,134 @@ func (s *MainScreen) tryGiveOrderAtMapCoords(xy lib.MapCoords, order lib.OrderTy
 	}
 }
 func (s *MainScreen) giveOrder(unit lib.Unit, order lib.OrderType) {
-	unit.Order = order
-	unit.HasLocalCommand = false
+	if _, err := s.issueOrder(lib.UnitOrder{Side: unit.Side, Index: unit.Index, Order: order}); err != nil {
+		return
+	}
 	switch order {
 	case lib.Reserve:
-		unit.Objective.X = 0
 		s.messageBox.Print("RESERVE", 2, 0)
 	case lib.Attack:
-		unit.Objective.X = 0
 		s.messageBox.Print("ATTACKING", 2, 0)
 	case lib.Defend:
-		unit.Objective = unit.XY
 		s.messageBox.Print("DEFENDING", 2, 0)
 	case lib.Move:
 		s.messageBox.Print("MOVE WHERE ?", 2, 0)
 	}
-	s.scenarioData.Units[unit.Side][unit.Index] = unit
+}
+
+// issueOrder applies the order and, when playing over the network, sends it to the host.
+func (s *MainScreen) issueOrder(order lib.UnitOrder) (lib.Unit, error) {
+	if s.turn != nil && !s.turn.givingOrders {
+		s.messageBox.Clear()
+		s.messageBox.Print("WAIT FOR YOUR NEXT TURN.", 2, 0)
+		return lib.Unit{}, fmt.Errorf("orders can be given only at the beginning of a turn")
+	}
+	unit, err := s.scenarioData.Units.ApplyOrder(order)
+	if err != nil {
+		return unit, err
+	}
+	if s.client != nil {
+		if err := s.client.SendOrder(order); err != nil {
+			return unit, err
+		}
+	}
+	return unit, nil
+}
+func (s *MainScreen) isSpectator() bool {
+	return s.options.AlliedCommander == lib.Computer && s.options.GermanCommander == lib.Computer
+}
+
+// playbackTicks returns the number of idle ticks passing in a single frame.
+func (s *MainScreen) playbackTicks() int {
+	if !s.isSpectator() || s.playbackSpeed == 0 {
+		return 1
+	}
+	if s.playbackSpeed > 0 {
+		return 1 << s.playbackSpeed
+	}
+	s.slowFrames++
+	if s.slowFrames%(1<<-s.playbackSpeed) == 0 {
+		return 1
+	}
+	return 0
+}
+func (s *MainScreen) isNetworkGame() bool {
+	return s.host != nil || s.client != nil
+}
+
+// startTurn loads the game from the turn file, if there is one, and lets the player give orders.
+func (s *MainScreen) startTurn() {
+	if s.turn.game != nil {
+		err := s.loadTurnGame()
+		s.turn.game = nil
+		if err != nil {
+			s.turn.ended = true
+			s.isFrozen = true
+			s.messageBox.Clear()
+			s.messageBox.Print("CANNOT LOAD TURN FILE", 2, 0)
+			return
+		}
+	}
+	s.turn.givingOrders = true
+	s.isFrozen = true
+	s.messageBox.Clear()
+	s.messageBox.Print(fmt.Sprintf("%s PLAYER, TURN %d:", s.scenarioData.Data.Sides[s.playerSide], s.turn.Number), 2, 0)
+	s.messageBox.Print("GIVE ORDERS, THEN PRESS \"F\"", 2, 1)
+}
+func (s *MainScreen) loadTurnGame() error {
+	reader := bufio.NewReader(bytes.NewReader(s.turn.game))
+	if _, _, _, err := readSaveHeader(reader); err != nil {
+		return err
+	}
+	if err := s.options.Read(reader); err != nil {
+		return err
+	}
+	if !s.areUnitsHidden {
+		s.toggleHideUnits()
+	}
+	defer s.toggleHideUnits()
+	if err := s.gameState.Load(reader); err != nil {
+		return err
+	}
+	// The game has been saved as seen by the other player.
+	s.gameState.SwitchSides()
+	return nil
+}
+
+// endTurn writes the turn file for the other player. The game stays paused in a state
+// from which the other player resumes it.
+func (s *MainScreen) endTurn() {
+	s.turn.ended = true
+	s.isFrozen = true
+	s.messageBox.Clear()
+	var game bytes.Buffer
+	if err := s.writeGame(&game, 1-s.playerSide); err != nil {
+		s.messageBox.Print("DISK ERROR: CANNOT SAVE TURN", 2, 0)
+		return
+	}
+	filename, err := s.turn.writeNextTurn(s.gameData.Scenarios[s.selectedScenario].FilePrefix, game.Bytes())
+	if err != nil {
+		s.messageBox.Print("DISK ERROR: CANNOT SAVE TURN", 2, 0)
+		return
+	}
+	s.messageBox.Print(fmt.Sprintf("TURN %d SAVED TO FILE:", s.turn.Number+1), 2, 0)
+	s.messageBox.Print(filepath.Base(filename), 2, 1)
+	s.messageBox.Print("SEND IT TO THE OTHER PLAYER.", 2, 2)
+	s.statusBar.Clear()
+	s.statusBar.Print("TURN OVER, PRESS CTRL-Q TO QUIT.", 2, 0)
+}
+func (s *MainScreen) runGame() {
+	if !s.gameSync.Wait() {
+		return
+	}
+	if !s.gameState.Init() {
+		return
+	}
+	for {
+		if !s.gameState.Update() {
+			return
+		}
+	}
 }
 func (s *MainScreen) pickOrder(xy lib.UnitCoords) {
 	s.messageBox.Clear()
@@ -543,8 +819,10 @@ func (s *MainScreen) trySetObjective(xy lib.MapCoords) {
 
 }
 func (s *MainScreen) setObjective(unit lib.Unit, xy lib.UnitCoords) {
-	unit.Objective = xy
-	unit.HasLocalCommand = false
+	unit, err := s.issueOrder(lib.UnitOrder{Side: unit.Side, Index: unit.Index, SetObjective: true, Objective: xy})
+	if err != nil {
+		return
+	}
 	s.messageBox.Clear()
 	s.messageBox.Print(fmt.Sprintf("*WHO * %s", unit.FullName()), 2, 0)
 	s.messageBox.Print("OBJECTIVE HERE.", 2, 1)
@@ -552,7 +830,6 @@ func (s *MainScreen) setObjective(unit lib.Unit, xy lib.UnitCoords) {
 	if distance > 0 {
 		s.messageBox.Print(fmt.Sprintf("DISTANCE: %d MILES.", distance*s.scenarioData.Data.HexSizeInMiles), 2, 2)
 	}
-	s.scenarioData.Units[unit.Side][unit.Index] = unit
 	s.orderedUnit = nil
 }
 func (s *MainScreen) showUnitInfo() {
@@ -560,7 +837,7 @@ func (s *MainScreen) showUnitInfo() {
 		return
 	}
 	cursorXY := s.mapView.GetCursorPosition()
-	unit, ok := s.scenarioData.Units.FindUnitAt(cursorXY.ToUnitCoords())
+	unit, ok := s.gameState.ViewFor(s.playerSide).FindUnitAt(cursorXY.ToUnitCoords())
 	if !ok {
 		return
 	}
@@ -629,12 +906,43 @@ func numberToGeneralRating(num int) string {
 	ratings := []string{"FAIR", "GOOD", "EXCELLNT"}
 	return ratings[(num-10)/2]
 }
+
+// objectiveDecisionAtCursor returns the most recent objective decision of the unit under the cursor.
+func (s *MainScreen) objectiveDecisionAtCursor() (lib.ObjectiveDecision, bool) {
+	unit, ok := s.gameState.ViewFor(s.playerSide).FindUnitAt(s.mapView.GetCursorPosition().ToUnitCoords())
+	if !ok {
+		return lib.ObjectiveDecision{}, false
+	}
+	return s.gameState.ObjectiveDecision(unit.Side, unit.Index)
+}
+func (s *MainScreen) showObjectiveDecision() {
+	s.messageBox.Clear()
+	decision, ok := s.objectiveDecisionAtCursor()
+	if !ok {
+		s.messageBox.Print("NO OBJECTIVE CHOSEN BY AI", 2, 0)
+		return
+	}
+	s.messageBox.Print(fmt.Sprintf("*%s* OBJ %d,%d SCORE %d", decision.Order, decision.Objective.X, decision.Objective.Y, decision.Score), 2, 0)
+	line, row := "", 1
+	for _, candidate := range decision.SortedCandidates() {
+		text := fmt.Sprintf("%d,%d:%d ", candidate.XY.X, candidate.XY.Y, candidate.Score)
+		if len(line)+len(text) > 40 {
+			if row == 4 {
+				break
+			}
+			s.messageBox.Print(line, 2, row)
+			line, row = "", row+1
+		}
+		line += text
+	}
+	s.messageBox.Print(line, 2, row)
+}
 func (s *MainScreen) showGeneralInfo() {
 	if s.areUnitsHidden {
 		return
 	}
 	cursorXY := s.mapView.GetCursorPosition()
-	unit, ok := s.scenarioData.Units.FindUnitAt(cursorXY.ToUnitCoords())
+	unit, ok := s.gameState.ViewFor(s.playerSide).FindUnitAt(cursorXY.ToUnitCoords())
 	if !ok {
 		return
 	}
@@ -691,13 +999,40 @@ func (s *MainScreen) showOverviewMap() {
 	if !s.areUnitsHidden {
 		s.toggleHideUnits()
 	}
-	s.overviewMap = NewOverviewMap(s.gameData.Map, s.scenarioData.Units, s.gameData.Generic, s.scenarioData.Data, s.gameState.IsUnitVisible)
+	s.overviewMap = NewOverviewMap(s.gameData.Map, s.scenarioData.Units, s.gameData.Generic, s.scenarioData.Data, s.gameState.ViewFor(s.playerSide).IsUnitVisible)
 }
 func (s *MainScreen) showFlashback() {
 	if !s.areUnitsHidden {
 		s.toggleHideUnits()
 	}
-	s.flashback = NewFlashback(s.mapView, s.messageBox, s.gameState.Flashback(), s.gameState.TerrainTypeMap())
+	var canRewind func(int) bool
+	if !s.isNetworkGame() && s.turn == nil && !s.gameOver {
+		canRewind = func(day int) bool { return s.gameState.CanRewindTo(day + 1) }
+	}
+	s.flashback = NewFlashback(s.mapView, s.messageBox, s.gameState.Flashback(), canRewind)
+}
+
+// rewind restores the game from the end of the day chosen in the flashback.
+func (s *MainScreen) rewind() {
+	daysElapsed := s.rewindDay
+	s.rewindDay = 0
+	unitsHidden := s.areUnitsHidden
+	if !unitsHidden {
+		s.toggleHideUnits()
+	}
+	err := s.gameState.RewindTo(daysElapsed)
+	if !unitsHidden {
+		s.toggleHideUnits()
+	}
+	s.messageBox.Clear()
+	if err != nil {
+		s.messageBox.Print("CANNOT REWIND THE GAME", 2, 0)
+		return
+	}
+	s.lastMessageFromUnit = nil
+	s.messageBox.Print(fmt.Sprintf("GAME REWOUND TO THE END OF DAY %d", daysElapsed), 2, 0)
+	s.statusBar.Clear()
+	s.statusBar.Print(s.dateTimeString(), 2, 0)
 }
 func (s *MainScreen) showLastMessageUnit() {
 	if s.lastMessageFromUnit == nil {
@@ -715,12 +1050,28 @@ func (s *MainScreen) decreaseGameSpeed() {
 }
 func (s *MainScreen) changeGameSpeed(faster bool) {
 	if faster {
-		s.options.Speed = s.options.Speed.Faster()
+		if s.isSpectator() && (s.playbackSpeed < 0 || (s.options.Speed == lib.Fast && s.playbackSpeed < 3)) {
+			s.playbackSpeed++
+		} else {
+			s.options.Speed = s.options.Speed.Faster()
+		}
 	} else {
-		s.options.Speed = s.options.Speed.Slower()
+		if s.isSpectator() && s.options.Speed == lib.Slow && s.playbackSpeed > -2 {
+			s.playbackSpeed--
+		} else if s.isSpectator() && s.playbackSpeed > 0 {
+			s.playbackSpeed--
+		} else {
+			s.options.Speed = s.options.Speed.Slower()
+		}
+	}
+	speed := s.options.Speed.String()
+	if s.playbackSpeed > 0 {
+		speed += fmt.Sprintf(" X%d", 1<<s.playbackSpeed)
+	} else if s.playbackSpeed < 0 {
+		speed += fmt.Sprintf(" X1/%d", 1<<-s.playbackSpeed)
 	}
 	s.messageBox.Clear()
-	s.messageBox.Print("SPEED: "+s.options.Speed.String(), 2, 0)
+	s.messageBox.Print("SPEED: "+speed, 2, 0)
 }
 
 func (s *MainScreen) dateTimeString() string {
@@ -768,32 +1119,32 @@ func (s *MainScreen) saveGameToFile(filename string) {
 		return
 	}
 	defer file.Close()
-	scenarioFilePrefix := s.gameData.Scenarios[s.selectedScenario].FilePrefix
-	if _, err := file.Write([]byte(scenarioFilePrefix)); err != nil {
+	if err := s.writeGame(file, s.playerSide); err != nil {
 		s.messageBox.Print("DISK ERROR: 4", 2, 4)
 		return
 	}
-	if _, err := file.Write([]byte{0}); err != nil {
-		s.messageBox.Print("DISK ERROR: 5", 2, 4)
-		return
+	s.messageBox.Print("COMPLETED", 2, 4)
+}
+
+// writeGame writes the game in the save file format.
+func (s *MainScreen) writeGame(writer io.Writer, playerSide int) error {
+	scenarioFilePrefix := s.gameData.Scenarios[s.selectedScenario].FilePrefix
+	if _, err := writer.Write([]byte(scenarioFilePrefix)); err != nil {
+		return err
 	}
-	if err := binary.Write(file, binary.LittleEndian, uint8(s.selectedScenario)); err != nil {
-		s.messageBox.Print("DISK ERROR: 6", 2, 4)
-		return
+	if _, err := writer.Write([]byte{0}); err != nil {
+		return err
 	}
-	if err := binary.Write(file, binary.LittleEndian, uint8(s.playerSide)); err != nil {
-		s.messageBox.Print("DISK ERROR: 7", 2, 4)
-		return
+	if err := binary.Write(writer, binary.LittleEndian, uint8(s.selectedScenario)); err != nil {
+		return err
 	}
-	if err := s.options.Write(file); err != nil {
-		s.messageBox.Print("DISK ERROR: 8", 2, 4)
-		return
+	if err := binary.Write(writer, binary.LittleEndian, uint8(playerSide)); err != nil {
+		return err
 	}
-	if err := s.gameState.Save(file); err != nil {
-		s.messageBox.Print("DISK ERROR: 9", 2, 4)
-		return
+	if err := s.options.Write(writer); err != nil {
+		return err
 	}
-	s.messageBox.Print("COMPLETED", 2, 4)
+	return s.gameState.Save(writer)
 }
 func (s *MainScreen) loadGame() {
 	s.messageBox.Clear()
@@ -837,36 +1188,25 @@ func (s *MainScreen) loadGameFromFile(filename string) {
 	}
 	defer file.Close()
 	reader := bufio.NewReader(file)
-	prefix, err := reader.ReadString(0)
+	prefix, selectedScenario, playerSide, err := readSaveHeader(reader)
 	if err != nil {
 		s.messageBox.Print("DISK ERROR: 3", 2, 4)
 		return
 	}
-	var selectedScenario, playerSide uint8
-	if err := binary.Read(reader, binary.LittleEndian, &selectedScenario); err != nil {
-		s.messageBox.Print("DISK ERROR: 4", 2, 4)
-		return
-	}
-	if err := binary.Read(reader, binary.LittleEndian, &playerSide); err != nil {
-		s.messageBox.Print("DISK ERROR: 5", 2, 5)
-		return
-	}
-	// strip the 0 delimiter from the end of the prefix
-	prefix = prefix[:len(prefix)-1]
 	scenarioFound := false
 	for i, scenario := range s.gameData.Scenarios {
 		if scenario.FilePrefix == prefix {
-			if i == int(selectedScenario) {
+			if i == selectedScenario {
 				scenarioFound = true
 			}
 			break
 		}
 	}
-	if !scenarioFound || int(selectedScenario) != s.selectedScenario {
+	if !scenarioFound || selectedScenario != s.selectedScenario {
 		s.messageBox.Print("*WARNING:*   SCENARIO MISMATCH", 2, 4)
 		return
 	}
-	s.playerSide = int(playerSide)
+	s.playerSide = playerSide
 	if err := s.options.Read(reader); err != nil {
 		s.messageBox.Print("DISK ERROR: 6", 2, 4)
 		return
@@ -909,7 +1249,57 @@ func (s *MainScreen) Draw(screen *ebiten.Image) {
 		s.separatorRect.SetColor(int(s.scenarioData.Data.NightPalette[0]))
 	}
 	s.mapView.SetIsNight(s.gameState.IsNight())
+	if differences, ok := s.gameState.LocalWeatherDifferences(); ok {
+		s.mapView.SetLocalWeatherDifferences(&differences)
+	} else {
+		s.mapView.SetLocalWeatherDifferences(nil)
+	}
 	s.mapView.SetUnitDisplay(s.options.UnitDisplay)
+	s.mapView.SetObjectiveDecision(nil)
+	if s.isSpectator() && s.aiMap > 0 {
+		side, mapIndex := (s.aiMap-1)/len(aiMapNames), (s.aiMap-1)%len(aiMapNames)
+		var maps [2][16][16]int
+		influenceMaps := s.gameState.InfluenceMaps(side)
+		switch mapIndex {
+		case 0:
+			maps[side] = influenceMaps.Troops
+		case 1:
+			maps[side] = influenceMaps.Influence
+		case 2:
+			maps[side] = influenceMaps.Defence
+		case 3, 4:
+			aggregated := influenceMaps.AggregatedTroops
+			if mapIndex == 4 {
+				aggregated = influenceMaps.AggregatedInfluence
+			}
+			for x := 0; x < 16; x++ {
+				for y := 0; y < 16; y++ {
+					maps[side][x][y] = aggregated[x/4][y/4]
+				}
+			}
+		}
+		s.mapView.SetInfluenceMaps(&maps)
+		if decision, ok := s.objectiveDecisionAtCursor(); ok {
+			s.mapView.SetObjectiveDecision(&decision)
+		}
+	} else if s.isSpectator() && s.influenceMap > 0 {
+		var maps [2][16][16]int
+		for side := range maps {
+			influenceMaps := s.gameState.InfluenceMaps(side)
+			switch s.influenceMap {
+			case 1:
+				maps[side] = influenceMaps.Troops
+			case 2:
+				maps[side] = influenceMaps.Influence
+			case 3:
+				maps[side] = influenceMaps.Defence
+			}
+		}
+		s.mapView.SetInfluenceMaps(&maps)
+	} else {
+		s.mapView.SetInfluenceMaps(nil)
+	}
+	s.mapView.SetShowObjectives(s.isSpectator() && s.showObjectives)
 
 	if s.flashback != nil {
 		s.flashback.Draw(screen)
@@ -2,9 +2,11 @@ package ui
 
 import (
 	"image"
+	"image/color"
 	"math"
 
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 	"github.com/pwiecz/command_series/lib"
 )
 
@@ -29,6 +31,14 @@ type MapView struct {
 	iconDx, iconDy    float64
 
 	isNight bool
+	// Differences between the local and the global weather (nil if there are none).
+	localWeather *[16][16]int
+	// Strategy level maps of both sides shown over the map (nil if not shown).
+	influenceMaps *[2][16][16]int
+	// If set, orders and objectives of all units are shown.
+	showObjectives bool
+	// Objective decision of the computer commander shown over the map (nil if not shown).
+	objectiveDecision *lib.ObjectiveDecision
 
 	x, y          float64
 	width, height float64
@@ -118,6 +128,18 @@ func (v *MapView) SetIsNight(isNight bool) {
 	v.isNight = isNight
 	v.unitSprites.SetIsNight(isNight)
 }
+func (v *MapView) SetLocalWeatherDifferences(differences *[16][16]int) {
+	v.localWeather = differences
+}
+func (v *MapView) SetInfluenceMaps(maps *[2][16][16]int) {
+	v.influenceMaps = maps
+}
+func (v *MapView) SetShowObjectives(showObjectives bool) {
+	v.showObjectives = showObjectives
+}
+func (v *MapView) SetObjectiveDecision(decision *lib.ObjectiveDecision) {
+	v.objectiveDecision = decision
+}
 func (v *MapView) ScreenCoordsToUnitCoords(screenX, screenY int) lib.UnitCoords {
 	imageX := (float64(screenX)-v.x)/v.zoomX + v.subimageDx
 	imageY := (float64(screenY)-v.y)/v.zoomY + v.subimageDy
@@ -136,6 +158,11 @@ func (v *MapView) AreMapCoordsVisible(mapXY lib.MapCoords) bool {
 	x, y := v.MapCoordsToScreenCoords(mapXY)
 	return v.AreScreenCoordsVisible(x, y)
 }
+
+// MapBounds returns bounds of the drawn map (in map coordinates).
+func (v *MapView) MapBounds() (minX, minY, maxX, maxY int) {
+	return v.minMapX, v.minMapY, v.maxMapX, v.maxMapY
+}
 func (v *MapView) AreScreenCoordsVisible(x, y float64) bool {
 	return x >= v.x && x < v.x+v.width && y >= v.y && y < v.y+v.height
 }
@@ -171,6 +198,12 @@ func (v *MapView) Draw(screen *ebiten.Image) {
 	options.GeoM.Scale(v.zoomX, v.zoomY)
 	options.GeoM.Translate(v.x, v.y)
 	screen.DrawImage(mapSubImage, &options)
+	if v.localWeather != nil {
+		v.drawLocalWeather(screen)
+	}
+	if v.influenceMaps != nil {
+		v.drawInfluenceMaps(screen)
+	}
 	for _, sideUnits := range v.units {
 		for _, unit := range sideUnits {
 			if !unit.IsInGame || !v.terrainTypeMap.ContainsUnit(unit.XY) {
@@ -184,6 +217,12 @@ func (v *MapView) Draw(screen *ebiten.Image) {
 			v.drawSpriteAtCoords(sprite, x, y, screen)
 		}
 	}
+	if v.showObjectives {
+		v.drawObjectives(screen)
+	}
+	if v.objectiveDecision != nil {
+		v.drawObjectiveDecision(screen)
+	}
 	if v.cursorImage == nil {
 		cursorSprite := v.GetSpriteFromIcon(lib.Cursor)
 		cursorBounds := cursorSprite.Bounds()
@@ -204,6 +243,142 @@ func (v *MapView) Draw(screen *ebiten.Image) {
 		v.iconAnimationStep++
 	}
 }
+
+// drawLocalWeather darkens regions of the map where the weather is worse
+// than the global weather and brightens the ones where it's better.
+func (v *MapView) drawLocalWeather(screen *ebiten.Image) {
+	viewRect := image.Rect(int(v.x), int(v.y), int(v.x+v.width), int(v.y+v.height))
+	view := screen.SubImage(viewRect).(*ebiten.Image)
+	regionWidth := float32(4 * v.tileWidth * v.zoomX)
+	regionHeight := float32(4 * v.tileHeight * v.zoomY)
+	for x, column := range v.localWeather {
+		for y, difference := range column {
+			if difference == 0 {
+				continue
+			}
+			regionX, regionY := v.MapCoordsToScreenCoords(lib.MapCoords{X: 4 * x, Y: 4 * y})
+			var tint color.Color
+			if difference > 0 {
+				tint = color.NRGBA{R: 0x20, G: 0x20, B: 0x50, A: uint8(lib.Min(difference, 4) * 32)}
+			} else {
+				tint = color.NRGBA{R: 0xff, G: 0xf0, B: 0xa0, A: uint8(lib.Min(-difference, 4) * 24)}
+			}
+			vector.DrawFilledRect(view, float32(regionX), float32(regionY), regionWidth, regionHeight, tint, false)
+		}
+	}
+}
+
+// drawInfluenceMaps tints every square of the map with colors of both sides,
+// the more intense the higher the value on the side's map.
+func (v *MapView) drawInfluenceMaps(screen *ebiten.Image) {
+	viewRect := image.Rect(int(v.x), int(v.y), int(v.x+v.width), int(v.y+v.height))
+	view := screen.SubImage(viewRect).(*ebiten.Image)
+	regionWidth := float32(4 * v.tileWidth * v.zoomX)
+	regionHeight := float32(4 * v.tileHeight * v.zoomY)
+	maxValue := 1
+	for _, sideMap := range v.influenceMaps {
+		for _, column := range sideMap {
+			for _, value := range column {
+				maxValue = lib.Max(maxValue, value)
+			}
+		}
+	}
+	sideColors := [2]color.NRGBA{{R: 0x30, G: 0x60, B: 0xff}, {R: 0xff, G: 0x30, B: 0x30}}
+	for side, sideMap := range v.influenceMaps {
+		for x, column := range sideMap {
+			for y, value := range column {
+				if value <= 0 {
+					continue
+				}
+				regionX, regionY := v.MapCoordsToScreenCoords(lib.MapCoords{X: 4 * x, Y: 4 * y})
+				tint := sideColors[side]
+				tint.A = uint8(16 + value*112/maxValue)
+				vector.DrawFilledRect(view, float32(regionX), float32(regionY), regionWidth, regionHeight, tint, false)
+			}
+		}
+	}
+}
+
+// orderColor returns color marking the order of a unit when objectives are shown.
+func orderColor(order lib.OrderType) color.Color {
+	switch order {
+	case lib.Reserve:
+		return color.NRGBA{R: 0xa0, G: 0xa0, B: 0xa0, A: 0xff}
+	case lib.Defend:
+		return color.NRGBA{R: 0x30, G: 0x60, B: 0xff, A: 0xff}
+	case lib.Attack:
+		return color.NRGBA{R: 0xff, G: 0x30, B: 0x30, A: 0xff}
+	default:
+		return color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	}
+}
+
+// drawObjectives marks the order of every shown unit and draws a line from the unit to its objective.
+func (v *MapView) drawObjectives(screen *ebiten.Image) {
+	viewRect := image.Rect(int(v.x), int(v.y), int(v.x+v.width), int(v.y+v.height))
+	view := screen.SubImage(viewRect).(*ebiten.Image)
+	centerDx, centerDy := v.tileWidth*v.zoomX/2, v.tileHeight*v.zoomY/2
+	for _, sideUnits := range v.units {
+		for _, unit := range sideUnits {
+			if !unit.IsInGame || !v.terrainTypeMap.ContainsUnit(unit.XY) {
+				continue
+			}
+			x, y := v.MapCoordsToScreenCoords(unit.XY.ToMapCoords())
+			x, y = x+centerDx, y+centerDy
+			color := orderColor(unit.Order)
+			if unit.Objective.X != 0 && unit.Objective != unit.XY {
+				objectiveX, objectiveY := v.MapCoordsToScreenCoords(unit.Objective.ToMapCoords())
+				objectiveX, objectiveY = objectiveX+centerDx, objectiveY+centerDy
+				vector.StrokeLine(view, float32(x), float32(y), float32(objectiveX), float32(objectiveY), 1, color, false)
+				vector.DrawFilledRect(view, float32(objectiveX-1), float32(objectiveY-1), 3, 3, color, false)
+			}
+			vector.DrawFilledRect(view, float32(x-2), float32(y-1), 4, 2, color, false)
+		}
+	}
+}
+
+// DrawFrontLine draws every segment of the front line across the edge between the two tiles it separates.
+func (v *MapView) DrawFrontLine(segments []lib.FrontLineSegment, screen *ebiten.Image) {
+	viewRect := image.Rect(int(v.x), int(v.y), int(v.x+v.width), int(v.y+v.height))
+	view := screen.SubImage(viewRect).(*ebiten.Image)
+	centerDx, centerDy := v.tileWidth*v.zoomX/2, v.tileHeight*v.zoomY/2
+	halfLength := v.tileHeight * v.zoomY / 2
+	lineColor := color.NRGBA{R: 0xff, G: 0xe0, B: 0x40, A: 0xc0}
+	for _, segment := range segments {
+		x0, y0 := v.MapCoordsToScreenCoords(segment.XY0)
+		x1, y1 := v.MapCoordsToScreenCoords(segment.XY1)
+		dx, dy := x1-x0, y1-y0
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		// Perpendicular to the line connecting centers of the tiles, through its middle.
+		x, y := (x0+x1)/2+centerDx, (y0+y1)/2+centerDy
+		px, py := -dy/length*halfLength, dx/length*halfLength
+		vector.StrokeLine(view, float32(x-px), float32(y-py), float32(x+px), float32(y+py), 2, lineColor, false)
+	}
+}
+
+// drawObjectiveDecision marks locations considered by the computer commander as the objective
+// of a unit, the more preferred the brighter, and frames the chosen objective.
+func (v *MapView) drawObjectiveDecision(screen *ebiten.Image) {
+	viewRect := image.Rect(int(v.x), int(v.y), int(v.x+v.width), int(v.y+v.height))
+	view := screen.SubImage(viewRect).(*ebiten.Image)
+	tileWidth, tileHeight := v.tileWidth*v.zoomX, v.tileHeight*v.zoomY
+	candidates := v.objectiveDecision.SortedCandidates()
+	for rank, candidate := range candidates {
+		x, y := v.MapCoordsToScreenCoords(candidate.XY.ToMapCoords())
+		intensity := uint8(255 - rank*192/len(candidates))
+		vector.DrawFilledRect(view, float32(x+tileWidth/2-3), float32(y+tileHeight/2-2), 6, 4,
+			color.NRGBA{R: intensity, G: intensity, B: 0x40, A: 0xd0}, false)
+	}
+	yellow := color.NRGBA{R: 0xff, G: 0xff, B: 0x40, A: 0xff}
+	x, y := v.MapCoordsToScreenCoords(v.objectiveDecision.XY.ToMapCoords())
+	objectiveX, objectiveY := v.MapCoordsToScreenCoords(v.objectiveDecision.Objective.ToMapCoords())
+	vector.StrokeLine(view, float32(x+tileWidth/2), float32(y+tileHeight/2),
+		float32(objectiveX+tileWidth/2), float32(objectiveY+tileHeight/2), 1, yellow, false)
+	vector.StrokeRect(view, float32(objectiveX), float32(objectiveY), float32(tileWidth), float32(tileHeight), 1, yellow, false)
+}
 func (v *MapView) ShowIcon(icon lib.IconType, xy lib.MapCoords, dx, dy float64) {
 	v.shownIcons = append(v.shownIcons[:0], v.GetSpriteFromIcon(icon))
 	v.iconAnimationStep = 0
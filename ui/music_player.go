@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"sync"
+
+	"github.com/ebitengine/oto/v3"
+	"github.com/pwiecz/command_series/sid"
+)
+
+// A player of Commodore 64 tunes, played on the intro and the ending screens.
+type MusicPlayer struct {
+	player *oto.Player
+	source *musicSource
+}
+
+type musicSource struct {
+	mutex   sync.Mutex
+	player  *sid.Player
+	samples []float32
+}
+
+func (s *musicSource) Read(buf []byte) (int, error) {
+	// Two bytes of an unsigned 8-bit stereo sample.
+	n := len(buf) / 2
+	if cap(s.samples) < n {
+		s.samples = make([]float32, n)
+	}
+	samples := s.samples[:n]
+	s.mutex.Lock()
+	if s.player != nil {
+		if err := s.player.Generate(samples); err != nil {
+			// The tune crashed, stop playing it.
+			s.player = nil
+		}
+	}
+	if s.player == nil {
+		clear(samples)
+	}
+	s.mutex.Unlock()
+	for i, level := range samples {
+		sample := byte(128 + int(max(-1, min(1, level))*127))
+		buf[2*i] = sample
+		buf[2*i+1] = sample
+	}
+	return 2 * n, nil
+}
+
+func NewMusicPlayer(context *oto.Context) *MusicPlayer {
+	if context == nil {
+		return &MusicPlayer{}
+	}
+	s := &musicSource{}
+	p := &MusicPlayer{
+		source: s,
+		player: context.NewPlayer(s),
+	}
+	p.player.Play()
+	return p
+}
+
+// Play starts playing the song of the tune (counting from 1) from the beginning.
+func (p *MusicPlayer) Play(tune *sid.Tune, song int) error {
+	if p == nil || p.source == nil || tune == nil {
+		return nil
+	}
+	player, err := sid.NewPlayer(tune, song, sampleRate)
+	if err != nil {
+		return err
+	}
+	p.source.mutex.Lock()
+	defer p.source.mutex.Unlock()
+	p.source.player = player
+	return nil
+}
+
+func (p *MusicPlayer) Stop() {
+	if p == nil || p.source == nil {
+		return
+	}
+	p.source.mutex.Lock()
+	defer p.source.mutex.Unlock()
+	p.source.player = nil
+}
+
+func (p *MusicPlayer) Close() {
+	if p == nil || p.player == nil {
+		return
+	}
+	p.player.Close()
+}
@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/pwiecz/command_series/lib"
+)
+
+// NetworkOptions configure a game played by two players over the network.
+type NetworkOptions struct {
+	// If not empty, wait for the other player on this address (e.g. ":4000").
+	HostAddress string
+	// If not empty, join a game hosted on this address (e.g. "example.com:4000").
+	JoinAddress string
+}
+
+// NetworkConnection is shown while connection with the other player is being established.
+type NetworkConnection struct {
+	connect     func() error
+	onConnected func()
+	done        chan error
+	text        *Label
+}
+
+var _ SubGame = (*NetworkConnection)(nil)
+
+func NewNetworkConnection(text string, font *lib.Font, connect func() error, onConnected func()) *NetworkConnection {
+	c := &NetworkConnection{
+		connect:     connect,
+		onConnected: onConnected,
+		text:        NewLabel(text, 0, 0, 336, 8, font)}
+	c.text.SetBackgroundColor(15)
+	return c
+}
+func (c *NetworkConnection) Update() error {
+	if c.done == nil {
+		c.done = make(chan error)
+		go func() {
+			c.done <- c.connect()
+		}()
+	} else {
+		select {
+		case err := <-c.done:
+			if err != nil {
+				return err
+			}
+			c.onConnected()
+		default:
+		}
+	}
+	return nil
+}
+func (c *NetworkConnection) Draw(screen *ebiten.Image) {
+	screen.Fill(lib.RGBPalette[15])
+	c.text.Draw(screen)
+}
@@ -23,6 +23,10 @@ type OptionSelection struct {
 	unitDisplayButton  *Button
 	balanceButton      *Button
 	speedButton        *Button
+	calendarButton     *Button
+	weatherButton      *Button
+	side0AIButton      *Button
+	side1AIButton      *Button
 
 	cursorImage *ebiten.Image
 	cursorRow   int
@@ -45,21 +49,19 @@ func NewOptionSelection(game lib.Game, font *lib.Font, onOptionsSelected func(*l
 		onOptionsSelected: onOptionsSelected,
 		options:           &options}
 
-	var side0Command, side1Command string
+	var sidesStrings [2]string
 	switch game {
 	case lib.Crusade:
-		side0Command = crusadeSidesStrings[0] + " Command:"
-		side1Command = crusadeSidesStrings[1] + " Command:"
+		sidesStrings = crusadeSidesStrings
 	case lib.Decision:
-		side0Command = decisionSidesStrings[0] + " Command:"
-		side1Command = decisionSidesStrings[1] + " Command:"
+		sidesStrings = decisionSidesStrings
 	case lib.Conflict:
-		side0Command = conflictSidesStrings[0] + " Command:"
-		side1Command = conflictSidesStrings[1] + " Command:"
+		sidesStrings = conflictSidesStrings
 	}
+	side0Command, side1Command := sidesStrings[0]+" Command:", sidesStrings[1]+" Command:"
 
 	s.labels = []*Label{NewLabel("OPTION SELECTION", 24, 32, 300, 8, font)}
-	labelTexts := []string{side0Command, side1Command, "Intelligence:", "Unit Display:", "Play Balance:", "Speed:"}
+	labelTexts := []string{side0Command, side1Command, "Intelligence:", "Unit Display:", "Play Balance:", "Speed:", "Calendar:", "Weather:", sidesStrings[0] + " AI:", sidesStrings[1] + " AI:"}
 	maxLabelLength := 0
 	fontHeight := float64(font.Size().Y)
 	y := 48.0
@@ -93,6 +95,10 @@ func NewOptionSelection(game lib.Game, font *lib.Font, onOptionsSelected func(*l
 	s.unitDisplayButton = NewButton(s.options.UnitDisplay.String(), buttonX, 72, 300, 8, font)
 	s.balanceButton = NewButton(s.balanceStrings[s.options.GameBalance], buttonX, 80, 300, 8, font)
 	s.speedButton = NewButton(s.options.Speed.String(), buttonX, 88, 300, 8, font)
+	s.calendarButton = NewButton(s.options.Calendar.String(), buttonX, 96, 300, 8, font)
+	s.weatherButton = NewButton(s.options.Weather.String(), buttonX, 104, 300, 8, font)
+	s.side0AIButton = NewButton(s.options.AlliedAILevel.String(), buttonX, 112, 300, 8, font)
+	s.side1AIButton = NewButton(s.options.GermanAILevel.String(), buttonX, 120, 300, 8, font)
 
 	return s
 }
@@ -135,6 +141,22 @@ func (s *OptionSelection) changeGameSpeed(forward bool) {
 	}
 	s.speedButton.SetText(s.options.Speed.String())
 }
+func (s *OptionSelection) changeCalendar() {
+	s.options.Calendar = s.options.Calendar.Other()
+	s.calendarButton.SetText(s.options.Calendar.String())
+}
+func (s *OptionSelection) changeWeather() {
+	s.options.Weather = s.options.Weather.Next()
+	s.weatherButton.SetText(s.options.Weather.String())
+}
+func (s *OptionSelection) changeAlliedAILevel() {
+	s.options.AlliedAILevel = s.options.AlliedAILevel.Next()
+	s.side0AIButton.SetText(s.options.AlliedAILevel.String())
+}
+func (s *OptionSelection) changeGermanAILevel() {
+	s.options.GermanAILevel = s.options.GermanAILevel.Next()
+	s.side1AIButton.SetText(s.options.GermanAILevel.String())
+}
 func (s *OptionSelection) Update() error {
 	if s.side0Button.Update() {
 		s.changeAlliedCommander()
@@ -154,13 +176,25 @@ func (s *OptionSelection) Update() error {
 	if s.speedButton.Update() {
 		s.changeGameSpeed(true)
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyDown) && s.cursorRow < 5 {
+	if s.calendarButton.Update() {
+		s.changeCalendar()
+	}
+	if s.weatherButton.Update() {
+		s.changeWeather()
+	}
+	if s.side0AIButton.Update() {
+		s.changeAlliedAILevel()
+	}
+	if s.side1AIButton.Update() {
+		s.changeGermanAILevel()
+	}
+	if isKeyJustPressed(ebiten.KeyDown) && s.cursorRow < 9 {
 		s.cursorRow++
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyUp) && s.cursorRow > 0 {
+	if isKeyJustPressed(ebiten.KeyUp) && s.cursorRow > 0 {
 		s.cursorRow--
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyLeft) {
+	if isKeyJustPressed(ebiten.KeyLeft) {
 		switch s.cursorRow {
 		case 0:
 			s.changeAlliedCommander()
@@ -174,9 +208,17 @@ func (s *OptionSelection) Update() error {
 			s.changeGameBalance(false)
 		case 5:
 			s.changeGameSpeed(false)
+		case 6:
+			s.changeCalendar()
+		case 7:
+			s.changeWeather()
+		case 8:
+			s.changeAlliedAILevel()
+		case 9:
+			s.changeGermanAILevel()
 		}
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyRight) {
+	if isKeyJustPressed(ebiten.KeyRight) {
 		switch s.cursorRow {
 		case 0:
 			s.changeAlliedCommander()
@@ -190,9 +232,17 @@ func (s *OptionSelection) Update() error {
 			s.changeGameBalance(true)
 		case 5:
 			s.changeGameSpeed(true)
+		case 6:
+			s.changeCalendar()
+		case 7:
+			s.changeWeather()
+		case 8:
+			s.changeAlliedAILevel()
+		case 9:
+			s.changeGermanAILevel()
 		}
 	}
-	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
+	if isKeyJustPressed(ebiten.KeyEnter) {
 		s.onOptionsSelected(s.options)
 	}
 	s.pressedTouchIDs = s.pressedTouchIDs[:0]
@@ -216,6 +266,10 @@ func (s *OptionSelection) Draw(screen *ebiten.Image) {
 	s.unitDisplayButton.Draw(screen)
 	s.balanceButton.Draw(screen)
 	s.speedButton.Draw(screen)
+	s.calendarButton.Draw(screen)
+	s.weatherButton.Draw(screen)
+	s.side0AIButton.Draw(screen)
+	s.side1AIButton.Draw(screen)
 
 	if s.cursorImage == nil {
 		cursorImage := *s.font.Glyph(' ')
@@ -1,6 +1,8 @@
 package ui
 
 import (
+	"bufio"
+	"encoding/binary"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +16,14 @@ func saveDir(scenario string) (string, error) {
 	return filepath.Join(homeDir, ".command_series", "saves", scenario), nil
 }
 
+func turnDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".command_series", "turns"), nil
+}
+
 func listSaveFiles(scenario string) []string {
 	saveDir, err := saveDir(scenario)
 	if err != nil {
@@ -32,3 +42,22 @@ func listSaveFiles(scenario string) []string {
 	}
 	return saveFiles
 }
+
+// readSaveHeader reads the scenario file prefix, the scenario number and the player's side
+// written at the beginning of a saved game.
+func readSaveHeader(reader *bufio.Reader) (prefix string, scenario, playerSide int, err error) {
+	prefix, err = reader.ReadString(0)
+	if err != nil {
+		return
+	}
+	// strip the 0 delimiter from the end of the prefix
+	prefix = prefix[:len(prefix)-1]
+	var scenarioData, playerSideData uint8
+	if err = binary.Read(reader, binary.LittleEndian, &scenarioData); err != nil {
+		return
+	}
+	if err = binary.Read(reader, binary.LittleEndian, &playerSideData); err != nil {
+		return
+	}
+	return prefix, int(scenarioData), int(playerSideData), nil
+}
@@ -0,0 +1,73 @@
+package ui
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// scenarioFilesDir returns the directory with user-provided scenario extensions,
+// e.g. scenario scripts.
+func scenarioFilesDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".command_series", "scenarios"), nil
+}
+
+// findScenarioFile returns file system and name of the given file from
+// the scenario files directory, or ok=false if there is no such file.
+func findScenarioFile(filename string) (fsys fs.FS, ok bool, err error) {
+	dir, err := scenarioFilesDir()
+	if err != nil {
+		return nil, false, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, filename)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return os.DirFS(dir), true, nil
+}
+
+// readScenarioScript reads {scenario}.star script from the scenario files directory.
+// Returns (nil, nil) if there is no script for the scenario.
+func readScenarioScript(scenario string) (*lib.ScenarioScript, error) {
+	filename := scenario + ".star"
+	fsys, ok, err := findScenarioFile(filename)
+	if !ok {
+		return nil, err
+	}
+	return lib.ReadScenarioScript(fsys, filename)
+}
+
+// readVictoryRules reads {scenario}.victory.json rules from the scenario files directory.
+// Returns (nil, nil) if there are no custom rules for the scenario.
+func readVictoryRules(scenario string) (*lib.ScenarioVictoryRules, error) {
+	filename := scenario + ".victory.json"
+	fsys, ok, err := findScenarioFile(filename)
+	if !ok {
+		return nil, err
+	}
+	return lib.ReadScenarioVictoryRules(fsys, filename)
+}
+
+// readWeatherTable reads {scenario}.weather.json table from the scenario files directory.
+// Returns (nil, nil) if there is no custom weather table for the scenario.
+func readWeatherTable(scenario string) (*lib.WeatherTable, error) {
+	filename := scenario + ".weather.json"
+	fsys, ok, err := findScenarioFile(filename)
+	if !ok {
+		return nil, err
+	}
+	table, err := lib.ReadWeatherTable(fsys, filename)
+	if err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
@@ -0,0 +1,329 @@
+package ui
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/pwiecz/command_series/atr"
+	"github.com/pwiecz/command_series/lib"
+)
+
+// The screenshot tests play the game with scripted keyboard input and compare rendered
+// frames with golden screenshots in testdata/screenshots/<disk image name>. They need
+// a disk image of the game, passed in the COMMAND_SERIES_ATR environment variable, and
+// a display (e.g. a virtual one, like Xvfb), otherwise they are skipped.
+// Fixtures in fixtures_test.go need only the display, they draw widgets with a test font
+// and check them against images made from the glyphs of the font.
+//
+// The golden screenshots show graphics of the original games, so like the disk images
+// they are not committed to the repository (and CI, which has no disk images, doesn't
+// run these tests). Write them with `go test ./ui -update` at a commit which renders
+// the game correctly, then run the tests without -update after changing the code.
+// Rewrite them the same way after an intended change of the look.
+
+var update = flag.Bool("update", false, "write rendered frames as golden screenshots")
+
+const diskImageEnv = "COMMAND_SERIES_ATR"
+
+// Maximum number of frames to wait for a screen to be shown (e.g. while data is being loaded).
+const maxWaitFrames = 10000
+
+// Functions to be called from the ebiten main loop (nil if tests don't run in the loop).
+var mainLoopCalls chan func()
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if !hasDisplay() {
+		os.Exit(m.Run())
+	}
+	mainLoopCalls = make(chan func())
+	code := 0
+	go func() {
+		code = m.Run()
+		close(mainLoopCalls)
+	}()
+	ebiten.SetWindowTitle("Screenshot tests")
+	if err := ebiten.RunGame(&testRunner{}); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot run tests in ebiten main loop,", err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+// hasDisplay returns false if there is certainly no display to run the ebiten main loop on.
+func hasDisplay() bool {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "netbsd", "openbsd":
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+	return true
+}
+
+// testRunner runs functions passed by the tests from inside the ebiten main loop,
+// where images can be drawn and read.
+type testRunner struct{}
+
+func (r *testRunner) Update() error {
+	select {
+	case call, ok := <-mainLoopCalls:
+		if !ok {
+			return ebiten.Termination
+		}
+		call()
+	default:
+	}
+	return nil
+}
+func (r *testRunner) Draw(screen *ebiten.Image) {}
+func (r *testRunner) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return 336, 240
+}
+
+// runInMainLoop calls the function from the ebiten main loop and waits for it to finish.
+func runInMainLoop(f func()) {
+	done := make(chan struct{})
+	mainLoopCalls <- func() {
+		defer close(done)
+		f()
+	}
+	<-done
+}
+
+// scriptedKeyInput is keyboard input pressing keys as told by the test.
+type scriptedKeyInput struct {
+	// Number of frames for which pressed keys have been held.
+	pressed  map[ebiten.Key]int
+	released map[ebiten.Key]bool
+}
+
+func (i *scriptedKeyInput) IsKeyPressed(key ebiten.Key) bool      { return i.pressed[key] > 0 }
+func (i *scriptedKeyInput) IsKeyJustPressed(key ebiten.Key) bool  { return i.pressed[key] == 1 }
+func (i *scriptedKeyInput) IsKeyJustReleased(key ebiten.Key) bool { return i.released[key] }
+func (i *scriptedKeyInput) KeyPressDuration(key ebiten.Key) int   { return i.pressed[key] }
+
+// nextFrame updates state of the keys before the next frame.
+func (i *scriptedKeyInput) nextFrame(press, release []ebiten.Key) {
+	i.released = make(map[ebiten.Key]bool)
+	for key := range i.pressed {
+		i.pressed[key]++
+	}
+	for _, key := range release {
+		delete(i.pressed, key)
+		i.released[key] = true
+	}
+	for _, key := range press {
+		i.pressed[key] = 1
+	}
+}
+
+// screenshotHarness drives the game frame by frame.
+type screenshotHarness struct {
+	t      *testing.T
+	game   *Game
+	input  *scriptedKeyInput
+	screen *ebiten.Image
+}
+
+func newScreenshotHarness(t *testing.T) *screenshotHarness {
+	if mainLoopCalls == nil {
+		t.Skip("Screenshot tests need a display")
+	}
+	if os.Getenv(diskImageEnv) == "" {
+		t.Skipf("Set %s to the disk image of the game to run screenshot tests", diskImageEnv)
+	}
+	fsys, err := openDiskImage(os.Getenv(diskImageEnv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	game, err := NewGame(fsys, lib.NewRandSource(1), NetworkOptions{}, TurnOptions{})
+	if err != nil {
+		t.Fatal("Cannot create game,", err)
+	}
+	game.audioDisabled = true
+	input := &scriptedKeyInput{pressed: make(map[ebiten.Key]int)}
+	keyInput = input
+	t.Cleanup(func() { keyInput = ebitenKeyInput{} })
+	h := &screenshotHarness{t: t, game: game, input: input}
+	runInMainLoop(func() { h.screen = ebiten.NewImage(game.Layout(0, 0)) })
+	return h
+}
+
+func openDiskImage(filename string) (fs.FS, error) {
+	fileStat, err := os.Stat(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat file %s (%v)", filename, err)
+	}
+	if fileStat.IsDir() {
+		return os.DirFS(filename), nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %s (%v)", filename, err)
+	}
+	fsys, err := atr.NewAtrFS(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open atr image file %s (%v)", filename, err)
+	}
+	return fsys, nil
+}
+
+// frame updates and draws the game once, pressing and releasing given keys before.
+func (h *screenshotHarness) frame(press, release []ebiten.Key) {
+	h.input.nextFrame(press, release)
+	var err error
+	runInMainLoop(func() {
+		if err = h.game.Update(); err != nil {
+			return
+		}
+		h.screen.Clear()
+		h.game.Draw(h.screen)
+	})
+	if err != nil {
+		h.t.Fatal("Unexpected error updating game,", err)
+	}
+}
+
+// frames runs given number of frames without pressing keys.
+func (h *screenshotHarness) frames(n int) {
+	for i := 0; i < n; i++ {
+		h.frame(nil, nil)
+	}
+}
+
+// tap presses the key for a single frame.
+func (h *screenshotHarness) tap(key ebiten.Key) {
+	h.frame([]ebiten.Key{key}, nil)
+	h.frame(nil, []ebiten.Key{key})
+}
+
+// waitFor runs frames until the condition is met.
+func (h *screenshotHarness) waitFor(description string, condition func(subGame SubGame) bool) {
+	for i := 0; i < maxWaitFrames; i++ {
+		if condition(h.game.subGame) {
+			// Let the screen draw itself at least once.
+			h.frame(nil, nil)
+			return
+		}
+		h.frame(nil, nil)
+	}
+	h.t.Fatalf("Timed out waiting for %s", description)
+}
+
+// compare checks the last drawn frame against the golden screenshot with given name.
+func (h *screenshotHarness) compare(name string) {
+	h.t.Helper()
+	var screenshot *image.RGBA
+	runInMainLoop(func() {
+		screenshot = image.NewRGBA(h.screen.Bounds())
+		h.screen.ReadPixels(screenshot.Pix)
+	})
+	diskImage := filepath.Base(os.Getenv(diskImageEnv))
+	goldenPath := filepath.Join("testdata", "screenshots", strings.TrimSuffix(diskImage, filepath.Ext(diskImage)), name+".png")
+	if *update {
+		if err := writeScreenshot(goldenPath, screenshot); err != nil {
+			h.t.Fatal(err)
+		}
+		return
+	}
+	golden, err := readScreenshot(goldenPath)
+	if err != nil {
+		h.t.Fatalf("Cannot read golden screenshot (%v), golden screenshots are not committed, write them by running the test with -update at a commit which renders the game correctly", err)
+	}
+	if golden.Bounds() != screenshot.Bounds() || !bytes.Equal(golden.Pix, screenshot.Pix) {
+		actualPath := filepath.Join(os.TempDir(), "command_series_"+name+".png")
+		if err := writeScreenshot(actualPath, screenshot); err != nil {
+			h.t.Error(err)
+		}
+		h.t.Errorf("Screen %s differs from the golden screenshot %s, the rendered screen is in %s", name, goldenPath, actualPath)
+	}
+}
+
+func readScreenshot(filename string) (*image.RGBA, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+			for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+				rgba.Set(x, y, img.At(x, y))
+			}
+		}
+	}
+	return rgba, nil
+}
+
+func writeScreenshot(filename string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func isScreen[T SubGame](subGame SubGame) bool {
+	_, ok := subGame.(T)
+	return ok
+}
+
+func TestScreenshots(t *testing.T) {
+	h := newScreenshotHarness(t)
+	h.waitFor("intro", isScreen[*Intro])
+	h.frames(introFrames / 2)
+	h.compare("intro")
+	h.tap(ebiten.KeySpace)
+	h.waitFor("scenario selection", isScreen[*ScenarioSelection])
+	h.compare("scenario_selection")
+
+	h.tap(ebiten.Key1)
+	h.waitFor("variant selection", isScreen[*VariantSelection])
+	h.compare("variant_selection")
+
+	h.tap(ebiten.Key1)
+	h.waitFor("option selection", isScreen[*OptionSelection])
+	h.compare("options")
+
+	h.tap(ebiten.KeyEnter)
+	h.waitFor("main screen", isScreen[*MainScreen])
+	h.frames(120)
+	h.compare("main_screen")
+
+	mainScreen := h.game.subGame.(*MainScreen)
+	h.tap(ebiten.KeyO)
+	h.waitFor("overview map", func(SubGame) bool { return mainScreen.overviewMap != nil })
+	h.frames(10)
+	h.compare("overview_map")
+	h.tap(ebiten.KeyEscape)
+	h.waitFor("overview map to be hidden", func(SubGame) bool { return mainScreen.overviewMap == nil })
+
+	h.tap(ebiten.KeyB)
+	h.waitFor("flashback", func(SubGame) bool { return mainScreen.flashback != nil })
+	h.frames(10)
+	h.compare("flashback")
+	h.tap(ebiten.KeyF4)
+	h.waitFor("flashback to be hidden", func(SubGame) bool { return mainScreen.flashback == nil })
+}
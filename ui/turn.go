@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pwiecz/command_series/lib"
+)
+
+// TurnOptions configure a play-by-email game, in which players take turns
+// giving orders and the game advances for a fixed number of game hours after each turn.
+type TurnOptions struct {
+	// If positive, start a new game in which the game advances this many hours after each turn.
+	Hours int
+	// If not empty, continue the game from this turn file.
+	TurnFile string
+	// Key used to sign and encrypt turn files, shared by the players.
+	// It protects turn files from being modified or read by others, but not from
+	// the other player, who knows it too.
+	Key string
+}
+
+func (o TurnOptions) enabled() bool {
+	return o.Hours > 0 || o.TurnFile != ""
+}
+
+// turn is the current turn of a play-by-email game.
+type turn struct {
+	lib.TurnHeader
+	key []byte
+	// Saved game read from the turn file, to be loaded when the game is initialized.
+	game []byte
+
+	givingOrders bool
+	hoursPlayed  int
+	// Set when enough hours have been played and the turn should be saved
+	// at the next update of a unit.
+	over bool
+	// Set after the turn file has been written.
+	ended bool
+}
+
+// readTurnFile reads and verifies the turn file.
+func readTurnFile(filename string, key []byte) (*turn, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open turn file %s (%v)", filename, err)
+	}
+	defer file.Close()
+	header, game, err := lib.ReadTurnFile(file, key)
+	if err != nil {
+		return nil, err
+	}
+	return &turn{TurnHeader: header, key: key, game: game}, nil
+}
+
+// writeNextTurn writes the turn file for the other side and returns its path.
+func (t *turn) writeNextTurn(scenarioFilePrefix string, game []byte) (string, error) {
+	dir, err := turnDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	next := t.TurnHeader
+	next.Number++
+	next.Side = 1 - t.Side
+	var buf bytes.Buffer
+	if err := lib.WriteTurnFile(&buf, t.key, next, game); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("%s_%d.trn", scenarioFilePrefix, next.Number))
+	if err := os.WriteFile(filename, buf.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	return filename, nil
+}